@@ -9,7 +9,7 @@ import (
 	"cli/internal/state"
 )
 
-func handleCreateBackup(backend *state.Backend, clusterName string) {
+func handleCreateBackup(backend state.StateStore, clusterName string) {
 	timestamp := time.Now().Format("20060102-150405")
 	backupName := fmt.Sprintf("manual-backup-debug-%s", timestamp)
 
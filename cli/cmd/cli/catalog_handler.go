@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"cli/internal/clo"
+)
+
+// handleListOSImages prints the OS images available to the project
+func handleListOSImages(ctx context.Context, client *clo.Client, jsonOutput bool) {
+	images, err := client.GetImages(ctx)
+	if err != nil {
+		fmt.Printf("[ERROR] API error: %v\n", err)
+		os.Exit(1)
+	}
+	if jsonOutput {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(images.Result)
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tOS\tVERSION\tSTATUS")
+	fmt.Fprintln(w, "--\t----\t--\t-------\t------")
+	for _, img := range images.Result {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", img.ID, img.Name, img.OSName, img.Version, img.Status)
+	}
+	w.Flush()
+}
+
+// handleListKeypairs prints the SSH keypairs of the project
+func handleListKeypairs(ctx context.Context, client *clo.Client, jsonOutput bool) {
+	keys, err := client.GetKeypairs(ctx)
+	if err != nil {
+		fmt.Printf("[ERROR] API error: %v\n", err)
+		os.Exit(1)
+	}
+	if jsonOutput {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(keys.Result)
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tFINGERPRINT")
+	fmt.Fprintln(w, "--\t----\t-----------")
+	for _, k := range keys.Result {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", k.ID, k.Name, k.Fingerprint)
+	}
+	w.Flush()
+}
+
+// handleCreateKeypair uploads a local public key under name. An existing keypair with the
+// same name and key is reported as is; one with a different key is an error.
+func handleCreateKeypair(ctx context.Context, client *clo.Client, name, publicKeyPath string) {
+	data, err := os.ReadFile(os.ExpandEnv(publicKeyPath))
+	if err != nil {
+		fmt.Printf("[ERROR] Public key read error: %v\n", err)
+		os.Exit(1)
+	}
+	publicKey := strings.TrimSpace(string(data))
+	if !strings.HasPrefix(publicKey, "ssh-") && !strings.HasPrefix(publicKey, "ecdsa-") {
+		fmt.Printf("[ERROR] %s does not look like an OpenSSH public key.\n", publicKeyPath)
+		os.Exit(1)
+	}
+
+	keys, err := client.GetKeypairs(ctx)
+	if err != nil {
+		fmt.Printf("[ERROR] API error: %v\n", err)
+		os.Exit(1)
+	}
+	for _, k := range keys.Result {
+		if k.Name != name {
+			continue
+		}
+		if sameKey(k.PublicKey, publicKey) {
+			fmt.Printf("[+OK+] Keypair '%s' already exists with this key. ID: %s\n", name, k.ID)
+			return
+		}
+		fmt.Printf("[ERROR] Keypair '%s' already exists (ID: %s) with a different key. Choose another name.\n", name, k.ID)
+		os.Exit(1)
+	}
+
+	kp, err := client.CreateKeypair(ctx, name, publicKey)
+	if err != nil {
+		fmt.Printf("[ERROR] Keypair creation failed: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("[+OK+] Keypair '%s' uploaded. ID: %s\n", name, kp.ID)
+	fmt.Printf("   Use it in the config as: keypairs: [%s]\n", name)
+}
+
+// sameKey compares two OpenSSH public keys ignoring the comment
+func sameKey(a, b string) bool {
+	fa, fb := strings.Fields(a), strings.Fields(b)
+	return len(fa) >= 2 && len(fb) >= 2 && fa[0] == fb[0] && fa[1] == fb[1]
+}
+
+// resolveNames replaces image and keypair names in the groups with their IDs.
+// IDs are kept as they are; the API is only asked when some name needs resolving.
+func resolveNames(ctx context.Context, client *clo.Client, cfg *Config) error {
+	var needImages, needKeys bool
+	for _, g := range cfg.Groups {
+		needImages = needImages || !clo.IsUUID(g.Image)
+		for _, k := range g.Keypairs {
+			needKeys = needKeys || !clo.IsUUID(k)
+		}
+	}
+
+	var images []catalogItem
+	if needImages {
+		list, err := client.GetImages(ctx)
+		if err != nil {
+			return fmt.Errorf("image list: %w", err)
+		}
+		for _, img := range list.Result {
+			images = append(images, catalogItem{img.ID, img.Name})
+		}
+	}
+	var keys []catalogItem
+	if needKeys {
+		list, err := client.GetKeypairs(ctx)
+		if err != nil {
+			return fmt.Errorf("keypair list: %w", err)
+		}
+		for _, k := range list.Result {
+			keys = append(keys, catalogItem{k.ID, k.Name})
+		}
+	}
+
+	resolved := make(map[string]string)
+	for i := range cfg.Groups {
+		g := &cfg.Groups[i]
+		if !clo.IsUUID(g.Image) {
+			id, err := lookupByName("image", g.Image, images)
+			if err != nil {
+				return fmt.Errorf("group '%s': %w", g.NamePrefix, err)
+			}
+			resolved["image "+g.Image] = id
+			g.Image = id
+		}
+		var ids []string
+		for _, k := range g.Keypairs {
+			if !clo.IsUUID(k) {
+				id, err := lookupByName("keypair", k, keys)
+				if err != nil {
+					return fmt.Errorf("group '%s': %w", g.NamePrefix, err)
+				}
+				resolved["keypair "+k] = id
+				k = id
+			}
+			ids = append(ids, k)
+		}
+		g.Keypairs = ids
+	}
+	var names []string
+	for name := range resolved {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Printf("[SEARCH] Resolved %s -> %s\n", name, resolved[name])
+	}
+	return nil
+}
+
+type catalogItem struct{ ID, Name string }
+
+// lookupByName finds exactly one item by name (case-insensitive) and lists the candidates otherwise
+func lookupByName(kind, name string, items []catalogItem) (string, error) {
+	var matches []catalogItem
+	for _, it := range items {
+		if strings.EqualFold(it.Name, name) {
+			matches = append(matches, it)
+		}
+	}
+	switch len(matches) {
+	case 1:
+		return matches[0].ID, nil
+	case 0:
+		var names []string
+		for _, it := range items {
+			names = append(names, it.Name)
+		}
+		if len(names) == 0 {
+			return "", fmt.Errorf("%s '%s' not found: the project has no %ss", kind, name, kind)
+		}
+		return "", fmt.Errorf("%s '%s' not found. Available: %s", kind, name, strings.Join(names, ", "))
+	default:
+		var ids []string
+		for _, m := range matches {
+			ids = append(ids, m.ID)
+		}
+		return "", fmt.Errorf("%s name '%s' is ambiguous, use one of the IDs: %s", kind, name, strings.Join(ids, ", "))
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"cli/internal/clo/clotest"
+)
+
+func TestResolveNames(t *testing.T) {
+	fake := clotest.NewServer(t)
+	key := fake.AddKeypair("ci-deploy", "ssh-ed25519 AAAA ci")
+	cfg := &Config{Groups: []NodeGroup{
+		{NamePrefix: "a", Image: "Ubuntu-22.04", Keypairs: []string{"ci-deploy", "dd6b1251-ceeb-4fba-afbc-aa34cc4a6990"}},
+		{NamePrefix: "b", Image: clotest.DebianImage},
+	}}
+	if err := resolveNames(t.Context(), fake.Client(), cfg); err != nil {
+		t.Fatal(err)
+	}
+	a, b := cfg.Groups[0], cfg.Groups[1]
+	if a.Image != clotest.UbuntuImage || a.Keypairs[0] != key || a.Keypairs[1] != "dd6b1251-ceeb-4fba-afbc-aa34cc4a6990" {
+		t.Fatalf("group a not resolved: %+v", a)
+	}
+	if b.Image != clotest.DebianImage {
+		t.Fatalf("ID changed: %s", b.Image)
+	}
+}
+
+func TestResolveNamesSkipsAPIForIDs(t *testing.T) {
+	fake := clotest.NewServer(t)
+	cfg := &Config{Groups: []NodeGroup{{NamePrefix: "a", Image: clotest.UbuntuImage, Keypairs: []string{"dd6b1251-ceeb-4fba-afbc-aa34cc4a6990"}}}}
+	if err := resolveNames(t.Context(), fake.Client(), cfg); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(fake.Calls()); n != 0 {
+		t.Fatalf("%d API calls, all values are IDs", n)
+	}
+}
+
+func TestResolveNamesListsCandidates(t *testing.T) {
+	fake := clotest.NewServer(t)
+	fake.AddImage("ubuntu-24.04")
+	fake.AddImage("ubuntu-24.04")
+
+	cfg := &Config{Groups: []NodeGroup{{NamePrefix: "a", Image: "ubuntu-20.04"}}}
+	err := resolveNames(t.Context(), fake.Client(), cfg)
+	if err == nil || !strings.Contains(err.Error(), "Available: ubuntu-22.04, debian-12, ubuntu-24.04") {
+		t.Fatalf("expected the list of images, got %v", err)
+	}
+
+	cfg = &Config{Groups: []NodeGroup{{NamePrefix: "a", Image: "ubuntu-24.04"}}}
+	if err := resolveNames(t.Context(), fake.Client(), cfg); err == nil || !strings.Contains(err.Error(), "ambiguous") {
+		t.Fatalf("expected an ambiguity error, got %v", err)
+	}
+
+	cfg = &Config{Groups: []NodeGroup{{NamePrefix: "a", Image: clotest.UbuntuImage, Keypairs: []string{"nobody"}}}}
+	if err := resolveNames(t.Context(), fake.Client(), cfg); err == nil || !strings.Contains(err.Error(), "project has no keypairs") {
+		t.Fatalf("expected a keypair error, got %v", err)
+	}
+}
+
+func TestPlanFailsOnUnknownImage(t *testing.T) {
+	e := newTestEnv(t, strings.Replace(testConfig, "image: ubuntu-22.04", "image: ubuntu-18.04", 1))
+	cfg, err := GetClusterConfig(e.config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := computePlan(t.Context(), e.client, e.backend, cfg, testCluster, false, false); err == nil || !strings.Contains(err.Error(), "ubuntu-18.04") {
+		t.Fatalf("expected an image error, got %v", err)
+	}
+}
+
+func TestCreateKeypairIsIdempotent(t *testing.T) {
+	fake := clotest.NewServer(t)
+	path := filepath.Join(t.TempDir(), "id.pub")
+	os.WriteFile(path, []byte("ssh-ed25519 AAAAC3Nz laptop\n"), 0644)
+
+	handleCreateKeypair(t.Context(), fake.Client(), "laptop", path)
+	handleCreateKeypair(t.Context(), fake.Client(), "laptop", path)
+
+	keys := fake.Keypairs()
+	if len(keys) != 1 || keys[0].Name != "laptop" || keys[0].PublicKey != "ssh-ed25519 AAAAC3Nz laptop" {
+		t.Fatalf("keypairs: %+v", keys)
+	}
+}
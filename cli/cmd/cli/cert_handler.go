@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"strings"
@@ -10,7 +11,7 @@ import (
 	"cli/internal/state"
 )
 
-func handleWaitCert(client *clo.Client, backend *state.Backend, clusterName, inputStr string) {
+func handleWaitCert(ctx context.Context, client *clo.Client, backend state.StateStore, clusterName, inputStr string) {
 	parts := strings.SplitN(inputStr, ":", 2)
 	if len(parts) != 2 {
 		fmt.Println("[ERROR] Format error. Use: -wait-cert 'NAMESPACE:CERT_NAME'")
@@ -20,7 +21,7 @@ func handleWaitCert(client *clo.Client, backend *state.Backend, clusterName, inp
 	certName := parts[1]
 
 	if backend == nil {
-		fmt.Println("[ERROR] S3 Backend unavailable.")
+		fmt.Println("[ERROR] State backend unavailable.")
 		os.Exit(1)
 	}
 
@@ -2,16 +2,17 @@ package main
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"html/template"
 	"os"
+	"slices"
 	"sort"
 	"strings"
 	"sync"
 	"time"
 
 	"cli/internal/clo"
-	"cli/internal/local"
 	"cli/internal/state"
 )
 
@@ -30,6 +31,13 @@ type NodeResult struct {
 	Created   string
 }
 
+// Pauses of the create and attach flow; tests against the fake API shorten them
+var (
+	createRetryPause   = 10 * time.Second // before the next attempt to create a node
+	statusPollInterval = 10 * time.Second // between server status polls
+	attachSettleTime   = 15 * time.Second // after attach/delete calls, until the API reflects them
+)
+
 type InventoryData struct {
 	User  string
 	Nodes []NodeResult
@@ -43,8 +51,8 @@ func askForConfirmation(msg string) bool {
 	return input == "yes"
 }
 
-// --- UPDATED SIGNATURE: added noCheck bool ---
-func handleClusterCreateFromCode(client *clo.Client, inventoryPath string, s3Backend *state.Backend, clusterName string, force bool, manualPassword string, configPath string, deleteFromCloud bool, attachDisks bool, noCheck bool) {
+// handleClusterCreateFromCode reconciles the cluster with the config: computes the plan, prints it and executes it
+func handleClusterCreateFromCode(ctx context.Context, client *clo.Client, inventoryPath string, s3Backend state.StateStore, clusterName string, force bool, manualPassword string, configPath string, deleteFromCloud bool, noCheck bool) error {
 	startTime := time.Now()
 	defer func() {
 		duration := time.Since(startTime).Round(time.Second)
@@ -52,181 +60,21 @@ func handleClusterCreateFromCode(client *clo.Client, inventoryPath string, s3Bac
 	}()
 	cfg, err := GetClusterConfig(configPath)
 	if err != nil {
-		fmt.Printf("[ERROR] Configuration error: %v\n", err)
-		return
-	}
-
-	fmt.Println("[CLOUD] Getting list of servers (API)...")
-	allServersList, err := client.GetServersList()
-	cloudServerMap := make(map[string]string)
-	if err == nil {
-		for _, srv := range allServersList.Result {
-			cloudServerMap[srv.Name] = srv.ID
-		}
-	} else {
-		fmt.Printf("[WARNING] API Error: %v\n", err)
-	}
-
-	aliveNodesMap := make(map[string]state.NodeState)
-	var existingState *state.ClusterState
-
-	if s3Backend != nil {
-		fmt.Printf("[SEARCH] Loading State '%s'...\n", clusterName)
-		existingState, err = s3Backend.LoadState()
-		if err == nil && existingState != nil && len(existingState.Nodes) > 0 {
-			if force {
-				fmt.Println("[WARNING] -force: Ignoring state.")
-			} else {
-				fmt.Println("[+++] Verifying State against Cloud API...")
-				for _, n := range existingState.Nodes {
-					ip, addrID, disks, created, fetchErr := fetchNodeDetails(client, n.ID, n.Disks, nil)
-					if fetchErr != nil {
-						fmt.Printf("   [x] Node '%s' lost in the cloud.\n", n.Name)
-						continue
-					}
-
-					finalIP := ip
-					if n.SSHPort != 0 && n.IP != ip {
-						finalIP = n.IP
-					}
-
-					aliveNodesMap[n.Name] = state.NodeState{
-						Name: n.Name, Role: n.Role, ID: n.ID, IP: finalIP, SSHPort: n.SSHPort, AddressID: addrID, Labels: n.Labels, Taints: n.Taints, Disks: disks, Created: created, Updated: time.Now().Format(time.RFC3339),
-					}
-				}
-			}
-		}
-	}
-
-	currentPassword := manualPassword
-	if currentPassword == "" {
-		if loaded, err := local.LoadPassword(clusterName); err == nil && loaded != "" {
-			currentPassword = loaded
-			fmt.Println("[KEY] Found locally saved password.")
-		}
-	}
-	if currentPassword == "" {
-		genPass, _ := clo.GenerateRandomPassword()
-		currentPassword = genPass
-	}
-
-	var finalNodes []NodeResult
-	var nodesToCreate []struct {
-		Name         string
-		Group        NodeGroup
-		OldDisks     []state.DiskState
-		MergedLabels map[string]string
-	}
-
-	for _, group := range cfg.Groups {
-		for i, instCfg := range group.Instances {
-			if !instCfg.Enabled {
-				continue
-			}
-			mergedLabels := make(map[string]string)
-			for k, v := range group.Labels {
-				mergedLabels[k] = v
-			}
-			for k, v := range instCfg.Labels {
-				mergedLabels[k] = v
-			}
-			nodeName := fmt.Sprintf("%s-%s-%d", clusterName, group.NamePrefix, i)
-			if existing, ok := aliveNodesMap[nodeName]; ok {
-				finalNodes = append(finalNodes, NodeResult{
-					Name: existing.Name, Role: group.Role, Labels: mergedLabels, Taints: group.Taints,
-					ID: existing.ID, IP: existing.IP, SSHPort: existing.SSHPort, AddressID: existing.AddressID, Disks: existing.Disks, IsNew: false, Created: existing.Created,
-				})
-				continue
-			}
-			if realID, exists := cloudServerMap[nodeName]; exists {
-				fmt.Printf("[WARNING] Adopting '%s' (Import)...\n", nodeName)
-				ip, addrID, disks, created, err := fetchNodeDetails(client, realID, nil, group.Disks)
-				if err != nil {
-					continue
-				}
-				finalNodes = append(finalNodes, NodeResult{
-					Name: nodeName, Role: group.Role, ID: realID, IP: ip, AddressID: addrID,
-					Labels: mergedLabels, Taints: group.Taints, Disks: disks, IsNew: false, Created: created,
-				})
-				continue
-			}
-			var oldDisks []state.DiskState
-			if existingState != nil {
-				for _, n := range existingState.Nodes {
-					if n.Name == nodeName {
-						oldDisks = n.Disks
-						break
-					}
-				}
-			}
-			nodesToCreate = append(nodesToCreate, struct {
-				Name         string
-				Group        NodeGroup
-				OldDisks     []state.DiskState
-				MergedLabels map[string]string
-			}{nodeName, group, oldDisks, mergedLabels})
-		}
-	}
-
-	if len(nodesToCreate) > 0 {
-		maxConcurrency := 5
-		fmt.Printf("\n[T] Creating nodes: %d (concurrency: %d)...\n", len(nodesToCreate), maxConcurrency)
-		results := make(chan NodeResult, len(nodesToCreate))
-		var wg sync.WaitGroup
-		sem := make(chan struct{}, maxConcurrency)
-		for _, item := range nodesToCreate {
-			wg.Add(1)
-			go func(itm struct {
-				Name         string
-				Group        NodeGroup
-				OldDisks     []state.DiskState
-				MergedLabels map[string]string
-			}) {
-				sem <- struct{}{}
-				defer func() { <-sem }()
-				createNodeAsync(&wg, client, itm.Name, itm.Group, itm.OldDisks, itm.MergedLabels, currentPassword, attachDisks, results)
-			}(item)
-		}
-		go func() { wg.Wait(); close(results) }()
-		for res := range results {
-			if res.Err != nil {
-				fmt.Printf("[ERROR] [%s] Error: %v\n", res.Name, res.Err)
-			} else {
-				fmt.Printf("[+OK+] [%s] Created (%s)\n", res.Name, res.IP)
-				finalNodes = append(finalNodes, res)
-			}
-		}
-	} else {
-		fmt.Println("\n[CELEBRATION] All nodes (from config) are in order.")
+		return fmt.Errorf("configuration error: %w", err)
 	}
 
-	for name, existing := range aliveNodesMap {
-		foundInConfig := false
-		for _, fn := range finalNodes {
-			if fn.Name == name {
-				foundInConfig = true
-				break
-			}
-		}
-		if !foundInConfig {
-			fmt.Printf("\n[TRASH_CAN] EXTRA NODE DETECTED: '%s' (ID: %s)\n", name, existing.ID)
-			if deleteFromCloud {
-				if askForConfirmation(fmt.Sprintf("[WARNING] Delete server '%s'?", name)) {
-					fmt.Printf("   [EXPLOSION] DELETING SERVER...\n")
-					handleDelete(client, existing.ID)
-				}
-			} else {
-				fmt.Println("   [INFO] -delnodes disabled. State only.")
-			}
-		}
+	plan, err := computePlan(ctx, client, s3Backend, cfg, clusterName, force, deleteFromCloud)
+	if err != nil {
+		return err
 	}
-
-	// UPDATED: Pass noCheck flag
-	runPostActions(s3Backend, inventoryPath, cfg.SSHUser, clusterName, currentPassword, finalNodes, noCheck)
+	printPlan(plan)
+	executePlan(ctx, client, s3Backend, plan, inventoryPath, manualPassword, noCheck, true)
+	return nil
 }
 
-// --- UPDATED SIGNATURE: added noCheck bool ---
-func runPostActions(s3Backend *state.Backend, inventoryPath, sshUser, clusterName, password string, nodes []NodeResult, noCheck bool) {
+// runPostActions saves the nodes of the run to the state (dropped are the nodes it removed),
+// writes the inventory and checks SSH unless noCheck
+func runPostActions(s3Backend state.StateStore, inventoryPath, sshUser, clusterName, password string, nodes []NodeResult, dropped []string, noCheck bool) {
 	if s3Backend != nil {
 		fmt.Printf("\n[CLOUD] Syncing State to S3...\n")
 		var stateNodes []state.NodeState
@@ -241,8 +89,19 @@ func runPostActions(s3Backend *state.Backend, inventoryPath, sshUser, clusterNam
 				Disks: n.Disks, Created: cr, Updated: now,
 			})
 		}
-		newState := state.ClusterState{Version: "1.9", LastUpdated: time.Now(), SSHUser: sshUser, Nodes: stateNodes}
-		if err := s3Backend.SaveState(newState); err != nil {
+		newState := state.ClusterState{Version: state.CurrentVersion, LastUpdated: time.Now(), SSHUser: sshUser, Nodes: stateNodes}
+		err := s3Backend.SaveState(newState)
+		if state.IsConflict(err) {
+			// Another run saved in the meantime: reload and merge our nodes into what it wrote
+			fmt.Printf("[WARNING] %v\n", err)
+			err = s3Backend.UpdateState(func(st *state.ClusterState) error {
+				st.Nodes = mergeNodes(st.Nodes, stateNodes, nodes, dropped)
+				st.SSHUser = sshUser
+				st.LastUpdated = time.Now()
+				return nil
+			})
+		}
+		if err != nil {
 			fmt.Printf("[ERROR] Save error: %v\n", err)
 		} else {
 			fmt.Println("[SAVE] State updated.")
@@ -282,129 +141,108 @@ func runPostActions(s3Backend *state.Backend, inventoryPath, sshUser, clusterNam
 	}
 }
 
-func createNodeAsync(wg *sync.WaitGroup, client *clo.Client, name string, group NodeGroup, oldDisks []state.DiskState, labels map[string]string, password string, attachDisks bool, ch chan<- NodeResult) {
+func createNodeAsync(ctx context.Context, wg *sync.WaitGroup, client *clo.Client, name string, group NodeGroup, oldDisks []state.DiskState, disks []PlannedDisk, labels map[string]string, password string, ch chan<- NodeResult) {
 	defer wg.Done()
 	const MaxRetries = 3
 	var lastErr error
 
-	allProjectVolumes, _ := client.GetProjectVolumes()
-	var availableVolumes []clo.DiskResult
-	if allProjectVolumes != nil {
-		for _, v := range allProjectVolumes.Result {
-			if v.Status == "AVAILABLE" {
-				availableVolumes = append(availableVolumes, v)
-			}
+	// Lost data volumes come back from their snapshot before the server is created;
+	// a failed restore fails the node rather than giving it an empty disk
+	var restored []string
+	for _, d := range disks {
+		if d.Action != "restore" {
+			continue
 		}
+		fmt.Printf("... [%s] Restoring %s from snapshot %s...\n", name, d.MountPoint, d.SnapshotID)
+		volID, err := client.RestoreSnapshot(ctx, d.SnapshotID, volumeName(name, d.Name), d.Size, d.Tags)
+		if err == nil {
+			err = client.WaitForVolumeStatus(ctx, volID, "AVAILABLE", 60, statusPollInterval)
+		}
+		if err != nil {
+			ch <- NodeResult{Name: name, Err: fmt.Errorf("restore of %s from snapshot %s: %w", d.MountPoint, d.SnapshotID, err)}
+			return
+		}
+		restored = append(restored, volID)
+		critical := false
+		for _, old := range oldDisks {
+			critical = critical || (old.MountPoint == d.MountPoint && old.Critical)
+		}
+		oldDisks = append(append([]state.DiskState{}, oldDisks...), state.DiskState{ID: volID, Name: d.Name, Size: d.Size, MountPoint: d.MountPoint, Critical: critical})
 	}
 
 	for attempt := 1; attempt <= MaxRetries; attempt++ {
+		if ctx.Err() != nil {
+			lastErr = ctx.Err()
+			break
+		}
 		if attempt > 1 {
-			time.Sleep(10 * time.Second)
+			time.Sleep(createRetryPause)
 		}
 		fmt.Printf("... [%s] (Attempt %d) Preparation...\n", name, attempt)
 
-		var storagesPayload []map[string]interface{}
-		var disksToAttach []string
-		usedVolIDs := make(map[string]bool)
-
-		for _, dConf := range group.Disks {
-			if dConf.Bootable {
-				t := dConf.Type
-				if t == "" {
-					t = "storage"
-				}
-				storagesPayload = append(storagesPayload, map[string]interface{}{"bootable": true, "storage_type": t, "size": dConf.Size})
-				continue
-			}
-			foundDiskID := ""
-			for _, oldD := range oldDisks {
-				if oldD.Bootable || oldD.Size != dConf.Size {
-					continue
-				}
-				for _, av := range availableVolumes {
-					if av.ID == oldD.ID && !usedVolIDs[av.ID] {
-						foundDiskID = av.ID
-						usedVolIDs[av.ID] = true
-						break
-					}
-				}
-				if foundDiskID != "" {
-					break
-				}
-			}
-			if foundDiskID == "" {
-				for _, av := range availableVolumes {
-					if !usedVolIDs[av.ID] && av.Size == dConf.Size {
-						foundDiskID = av.ID
-						usedVolIDs[av.ID] = true
-						break
-					}
-				}
-			}
-			if foundDiskID != "" {
-				disksToAttach = append(disksToAttach, foundDiskID)
-			} else {
-				t := dConf.Type
-				if t == "" {
-					t = "storage"
-				}
-				storagesPayload = append(storagesPayload, map[string]interface{}{"bootable": false, "storage_type": t, "size": dConf.Size})
+		disksToAttach := append([]string(nil), restored...)
+		for _, d := range disks {
+			if d.Action == "reattach" {
+				disksToAttach = append(disksToAttach, d.VolumeID)
 			}
 		}
 
-		var addressesPayload []map[string]interface{}
+		address := clo.NewAddress(group.ExternalIP)
 		if group.ExternalIP {
 			useIP := group.StaticIP
 			if useIP == "" {
-				useIP, _ = client.FindAvailableExternalIP()
+				useIP, _ = client.FindAvailableExternalIP(ctx)
 			}
 			if useIP != "" {
-				addressesPayload = []map[string]interface{}{{"address_id": useIP}}
-			} else {
-				addressesPayload = []map[string]interface{}{{"ddos_protection": false, "external": true, "version": 4, "bandwidth_max_mbps": 1024}}
+				address = clo.ExistingAddress(useIP)
 			}
-		} else {
-			addressesPayload = []map[string]interface{}{{"ddos_protection": false, "external": false, "version": 4, "bandwidth_max_mbps": 1024}}
 		}
 
-		payload := map[string]interface{}{
-			"name":      name,
-			"flavor":    map[string]interface{}{"ram": group.Flavor.RAM, "vcpus": group.Flavor.VCPUs, "cpu_type": "SHARED"},
-			"storages":  storagesPayload,
-			"addresses": addressesPayload,
-			"image":     "389d732c-a53c-4566-984e-e01a7617ff25",
-			"keypairs":  []string{"dd6b1251-ceeb-4fba-afbc-aa34cc4a6990", "92fb9ff8-9396-455f-9aed-a6482fc54ce6"},
+		// Not cancelled: an interrupt must not lose the ID of a server the API already accepted
+		id, err := client.CreateServer(context.WithoutCancel(ctx), group.ServerRequest(name, disks, address))
+		if clo.IsQuota(err) {
+			// Retrying cannot help until resources are freed or the project limit is raised
+			fmt.Printf("   [ERROR] [%s] Project quota exhausted (%dGB RAM / %d vCPU requested): %v\n", name, group.Flavor.RAM, group.Flavor.VCPUs, err)
+			ch <- NodeResult{Name: name, Err: fmt.Errorf("quota exhausted, free resources or raise the project limit: %w", err)}
+			return
 		}
-
-		id, err := client.CreateServer(payload)
 		if err != nil {
 			fmt.Printf("   [ERROR] [%s] Create API Error: %v\n", name, err)
 			lastErr = err
 			continue
 		}
 
-		status, _, volIDs, err := client.WaitForStatus(id, []string{"ACTIVE", "RUNNING"}, 60, 10*time.Second)
+		status, _, volIDs, err := client.WaitForStatus(ctx, id, []string{"ACTIVE", "RUNNING"}, 60, statusPollInterval)
+		if err != nil && ctx.Err() != nil {
+			// Interrupted while building: delete the half-built server rather than leave it unrecorded
+			fmt.Printf("   [WARNING] [%s] Interrupted (Status: %s). Deleting...\n", name, status)
+			client.DeleteServer(context.WithoutCancel(ctx), id, clo.DeleteServerPayload{ClearFstab: true, DeleteVolumes: volIDs})
+			ch <- NodeResult{Name: name, Err: fmt.Errorf("interrupted: %w", ctx.Err())}
+			return
+		}
 		if err != nil {
 			fmt.Printf("   [WARNING] [%s] Failed (Status: %s). Deleting...\n", name, status)
-			client.DeleteServer(id, clo.DeleteServerPayload{ClearFstab: true, DeleteVolumes: volIDs})
+			client.DeleteServer(ctx, id, clo.DeleteServerPayload{ClearFstab: true, DeleteVolumes: volIDs})
 			lastErr = err
-			time.Sleep(15 * time.Second)
+			time.Sleep(attachSettleTime)
 			continue
 		}
+		// The server is up: finish it even if the run is interrupted, so the state records it whole
+		ctx := context.WithoutCancel(ctx)
 
 		if len(disksToAttach) > 0 {
 			for _, volID := range disksToAttach {
-				client.AttachVolume(volID, id)
+				client.AttachVolume(ctx, volID, id)
 			}
-			time.Sleep(15 * time.Second)
+			time.Sleep(attachSettleTime)
 		}
 
-		finalIP, finalAddrID, createdDisks, createdDate, err := fetchNodeDetails(client, id, oldDisks, group.Disks)
+		finalIP, finalAddrID, createdDisks, createdDate, err := fetchNodeDetails(ctx, client, id, oldDisks, group.Disks)
 		if err != nil {
 			fmt.Printf("[WARNING] Details error: %v\n", err)
 		}
 
-		client.SetServerPassword(id, password)
+		client.SetServerPassword(ctx, id, password)
 
 		ch <- NodeResult{
 			Name: name, Role: group.Role, ID: id, IP: finalIP, AddressID: finalAddrID,
@@ -415,15 +253,52 @@ func createNodeAsync(wg *sync.WaitGroup, client *clo.Client, name string, group
 	ch <- NodeResult{Name: name, Err: fmt.Errorf("failed after %d attempts: %v", MaxRetries, lastErr)}
 }
 
-func fetchNodeDetails(client *clo.Client, serverID string, oldDisks []state.DiskState, configDisks []Disk) (string, string, []state.DiskState, string, error) {
-	detail, err := client.GetServerDetail(serverID)
+// listsCoverServer reports whether the address and volume lists know every address and
+// attached storage of the server detail
+func listsCoverServer(detail *clo.ServerDetailResponse, serverID string, addrs map[string]clo.AddressDetail, vols *clo.DiskListResponse) bool {
+	for _, id := range detail.Result.Addresses {
+		if _, ok := addrs[id]; !ok {
+			return false
+		}
+	}
+	if vols == nil {
+		return false
+	}
+	attached := make(map[string]bool)
+	for _, v := range vols.Result {
+		if v.AttachedToServer != nil && v.AttachedToServer.ID == serverID {
+			attached[v.ID] = true
+		}
+	}
+	for _, st := range detail.Result.Storages {
+		if !attached[st.ID] {
+			return false
+		}
+	}
+	return true
+}
+
+func fetchNodeDetails(ctx context.Context, client *clo.Client, serverID string, oldDisks []state.DiskState, configDisks []Disk) (string, string, []state.DiskState, string, error) {
+	detail, err := client.GetServerDetail(ctx, serverID)
 	if err != nil {
 		return "", "", nil, "", err
 	}
+	ip, addrID, disks, created := nodeDetails(ctx, client, detail, serverID, oldDisks, configDisks)
+	return ip, addrID, disks, created, nil
+}
 
+// nodeDetails resolves the address and disks of a server from its detail and the project lists
+func nodeDetails(ctx context.Context, client *clo.Client, detail *clo.ServerDetailResponse, serverID string, oldDisks []state.DiskState, configDisks []Disk) (string, string, []state.DiskState, string) {
 	finalIP := "unknown"
 	finalAddressID := ""
-	allAddrs, _ := client.GetProjectAddressesMap()
+	allAddrs, _ := client.GetProjectAddressesMap(ctx)
+	allVolumes, _ := client.GetProjectVolumes(ctx)
+	if !listsCoverServer(detail, serverID, allAddrs, allVolumes) {
+		// The lists come from the run cache and predate this server's build or attach
+		clo.InvalidateListCache(ctx)
+		allAddrs, _ = client.GetProjectAddressesMap(ctx)
+		allVolumes, _ = client.GetProjectVolumes(ctx)
+	}
 
 	for _, addrID := range detail.Result.Addresses {
 		if info, ok := allAddrs[addrID]; ok {
@@ -442,7 +317,6 @@ func fetchNodeDetails(client *clo.Client, serverID string, oldDisks []state.Disk
 	}
 
 	var disks []state.DiskState
-	allVolumes, _ := client.GetProjectVolumes()
 	criticalMap := make(map[string]bool)
 	for _, d := range oldDisks {
 		if d.Critical {
@@ -451,21 +325,20 @@ func fetchNodeDetails(client *clo.Client, serverID string, oldDisks []state.Disk
 	}
 
 	if allVolumes != nil {
+		matched := make(map[string]bool)
 		for _, vol := range allVolumes.Result {
 			if vol.AttachedToServer != nil && vol.AttachedToServer.ID == serverID {
-				mountPoint := ""
+				mountPoint, name := "", ""
 				for _, old := range oldDisks {
 					if old.ID == vol.ID && old.MountPoint != "" {
-						mountPoint = old.MountPoint
+						mountPoint, name = old.MountPoint, old.Name
 						break
 					}
 				}
 				if mountPoint == "" && !vol.Bootable && configDisks != nil {
-					for _, cd := range configDisks {
-						if !cd.Bootable && cd.MountPoint != "" && cd.Size == vol.Size {
-							mountPoint = cd.MountPoint
-							break
-						}
+					if cd, ok := configDiskOfVolume(configDisks, vol, matched); ok {
+						mountPoint, name = cd.MountPoint, cd.Name
+						matched[cd.Name] = true
 					}
 				}
 				if mountPoint == "" && !vol.Bootable {
@@ -473,7 +346,7 @@ func fetchNodeDetails(client *clo.Client, serverID string, oldDisks []state.Disk
 					mountPoint = fmt.Sprintf("/mnt/disks/%s", parts[len(parts)-1])
 				}
 				disks = append(disks, state.DiskState{
-					ID: vol.ID, Size: vol.Size, Type: vol.Type, Bootable: vol.Bootable, Device: vol.AttachedToServer.Device,
+					ID: vol.ID, Name: name, Size: vol.Size, Type: vol.Type, Bootable: vol.Bootable, Device: vol.AttachedToServer.Device,
 					MountPoint: mountPoint, Critical: criticalMap[vol.ID], Created: vol.Created, Updated: time.Now().Format(time.RFC3339),
 				})
 			}
@@ -493,7 +366,7 @@ func fetchNodeDetails(client *clo.Client, serverID string, oldDisks []state.Disk
 				for _, vol := range allVolumes.Result {
 					if vol.ID == old.ID {
 						disks = append(disks, state.DiskState{
-							ID: vol.ID, Size: vol.Size, Type: vol.Type, Bootable: false, Device: "",
+							ID: vol.ID, Name: old.Name, Size: vol.Size, Type: vol.Type, Bootable: false, Device: "",
 							MountPoint: old.MountPoint, Critical: old.Critical, Created: vol.Created, Updated: time.Now().Format(time.RFC3339),
 						})
 						break
@@ -502,7 +375,26 @@ func fetchNodeDetails(client *clo.Client, serverID string, oldDisks []state.Disk
 			}
 		}
 	}
-	return finalIP, finalAddressID, disks, detail.Result.Created, nil
+	return finalIP, finalAddressID, disks, detail.Result.Created
+}
+
+// configDiskOfVolume finds the config disk of a volume by its disk tag; volumes from before
+// the tags fall back to the first unmatched config disk of the same size
+func configDiskOfVolume(configDisks []Disk, vol clo.DiskResult, matched map[string]bool) (Disk, bool) {
+	for _, cd := range configDisks {
+		if !cd.Bootable && vol.Tags[volTagDisk] != "" && cd.Name == vol.Tags[volTagDisk] {
+			return cd, true
+		}
+	}
+	if vol.Tags[volTagDisk] != "" {
+		return Disk{}, false
+	}
+	for _, cd := range configDisks {
+		if !cd.Bootable && cd.MountPoint != "" && cd.Size == vol.Size && !matched[cd.Name] {
+			return cd, true
+		}
+	}
+	return Disk{}, false
 }
 
 func saveToAnsibleInventory(filename, user string, nodes []NodeResult, sinkNode string) {
@@ -631,91 +523,159 @@ func saveToAnsibleInventory(filename, user string, nodes []NodeResult, sinkNode
 	t.Execute(f, data)
 }
 
-func handleSync(client *clo.Client, s3Backend *state.Backend, clusterName string) {
+func handleSync(ctx context.Context, client *clo.Client, s3Backend state.StateStore, clusterName string) {
 	if s3Backend == nil {
 		return
 	}
 	fmt.Printf("[REFRESH] Loading state '%s' from S3...\n", clusterName)
-	st, err := s3Backend.LoadState()
-	if err != nil || st == nil {
-		return
-	}
-	fmt.Println("[CLOUD] Updating data from API...")
-	var updatedNodes []state.NodeState
-	hasChanges := false
-	for _, node := range st.Nodes {
-		fmt.Printf("   Checking %s... ", node.Name)
-		newIP, newAddrID, newDisks, createdDate, err := fetchNodeDetails(client, node.ID, node.Disks, nil)
-		if err != nil {
-			fmt.Printf("[ERROR] Deleted\n")
-			hasChanges = true
-			continue
-		}
-		node.Created = createdDate
-		node.Updated = time.Now().Format(time.RFC3339)
-		nodeChanges := false
-		if node.IP != newIP {
-			node.IP = newIP
-			nodeChanges = true
-		}
-		if node.AddressID != newAddrID {
-			node.AddressID = newAddrID
-			nodeChanges = true
-		}
-		if len(node.Disks) != len(newDisks) {
-			node.Disks = newDisks
-			nodeChanges = true
-		} else {
-			for i := range node.Disks {
-				if node.Disks[i].ID == newDisks[i].ID && node.Disks[i].Device != newDisks[i].Device {
-					node.Disks = newDisks
-					nodeChanges = true
-					break
+	confirmed := false
+	saved := false
+	err := s3Backend.UpdateState(func(st *state.ClusterState) error {
+		fmt.Println("[CLOUD] Updating data from API...")
+		var updatedNodes []state.NodeState
+		hasChanges := false
+		for _, node := range st.Nodes {
+			fmt.Printf("   Checking %s... ", node.Name)
+			newIP, newAddrID, newDisks, createdDate, err := fetchNodeDetails(ctx, client, node.ID, node.Disks, nil)
+			if err != nil && (ctx.Err() != nil || !clo.IsNotFound(err)) {
+				// Only a server the API reports missing is dropped, an outage must not empty the state
+				fmt.Printf("[ERROR] %v\n", err)
+				return fmt.Errorf("sync aborted, state unchanged: %s: %w", node.Name, err)
+			}
+			if err != nil {
+				fmt.Printf("[ERROR] Deleted\n")
+				hasChanges = true
+				continue
+			}
+			node.Created = createdDate
+			node.Updated = time.Now().Format(time.RFC3339)
+			nodeChanges := false
+			if node.IP != newIP {
+				node.IP = newIP
+				nodeChanges = true
+			}
+			if node.AddressID != newAddrID {
+				node.AddressID = newAddrID
+				nodeChanges = true
+			}
+			if len(node.Disks) != len(newDisks) {
+				node.Disks = newDisks
+				nodeChanges = true
+			} else {
+				for i := range node.Disks {
+					if node.Disks[i].ID == newDisks[i].ID && node.Disks[i].Device != newDisks[i].Device {
+						node.Disks = newDisks
+						nodeChanges = true
+						break
+					}
 				}
 			}
+			if nodeChanges {
+				hasChanges = true
+			}
+			fmt.Printf("[+OK+]\n")
+			updatedNodes = append(updatedNodes, node)
 		}
-		if nodeChanges {
-			hasChanges = true
+		if !hasChanges {
+			fmt.Println("\n[STAR] No changes.")
+			return state.ErrNoChanges
+		}
+		if !confirmed {
+			if !askForConfirmation("[WARNING] Save changes to State?") {
+				return state.ErrNoChanges
+			}
+			confirmed = true
 		}
-		fmt.Printf("[+OK+]\n")
-		updatedNodes = append(updatedNodes, node)
-	}
-	if !hasChanges {
-		fmt.Println("\n[STAR] No changes.")
-		return
-	}
-	if askForConfirmation("[WARNING] Save changes to State?") {
 		st.Nodes = updatedNodes
 		st.LastUpdated = time.Now()
-		s3Backend.SaveState(*st)
+		saved = true
+		return nil
+	})
+	if err != nil {
+		fmt.Printf("[ERROR] Sync error: %v\n", err)
+		return
+	}
+	if saved {
 		fmt.Println("[SAVE] Saved.")
 	}
 }
 
-func handleDeleteNodeFromState(s3Backend *state.Backend, clusterName, nodeName string, inventoryPath string) {
-	if s3Backend == nil {
-		return
+// mergeNodes merges the nodes of this run (local, from results) into a state another run saved
+// concurrently (remote). Remote nodes this run did not touch are kept, the dropped ones removed.
+// A node is taken from this run, except one that another run gave a new server while this run
+// only kept it. Disk settings made by other commands (critical, owner/group/mode) and an LB SSH
+// port are never set here, so the remote values of those stay.
+func mergeNodes(remote, local []state.NodeState, results []NodeResult, dropped []string) []state.NodeState {
+	created := make(map[string]bool)
+	for _, r := range results {
+		created[r.Name] = r.IsNew
+	}
+	byName := make(map[string]state.NodeState)
+	for _, n := range remote {
+		byName[n.Name] = n
+	}
+	var out []state.NodeState
+	seen := make(map[string]bool)
+	for _, n := range local {
+		seen[n.Name] = true
+		r, ok := byName[n.Name]
+		switch {
+		case !ok:
+		case r.ID != n.ID && !created[n.Name]:
+			fmt.Printf("[WARNING] %s was replaced by another run (server %s), keeping its record.\n", n.Name, r.ID)
+			n = r
+		case r.ID == n.ID:
+			if n.SSHPort == 0 {
+				n.SSHPort = r.SSHPort
+			}
+			remoteDisks := make(map[string]state.DiskState)
+			for _, d := range r.Disks {
+				remoteDisks[d.ID] = d
+			}
+			n.Disks = append([]state.DiskState(nil), n.Disks...)
+			for i, d := range n.Disks {
+				if rd, ok := remoteDisks[d.ID]; ok {
+					n.Disks[i].Critical, n.Disks[i].Owner, n.Disks[i].Group, n.Disks[i].Mode = rd.Critical, rd.Owner, rd.Group, rd.Mode
+				}
+			}
+		}
+		out = append(out, n)
 	}
-	st, err := s3Backend.LoadState()
-	if err != nil || st == nil {
+	for _, r := range remote {
+		if !seen[r.Name] && !slices.Contains(dropped, r.Name) {
+			out = append(out, r)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+func handleDeleteNodeFromState(s3Backend state.StateStore, clusterName, nodeName string, inventoryPath string) {
+	if s3Backend == nil {
 		return
 	}
 	if !askForConfirmation(fmt.Sprintf("Delete node '%s' from state?", nodeName)) {
 		return
 	}
-	newNodes := []state.NodeState{}
-	for _, n := range st.Nodes {
-		if n.Name != nodeName {
-			newNodes = append(newNodes, n)
+	err := s3Backend.UpdateState(func(st *state.ClusterState) error {
+		newNodes := []state.NodeState{}
+		for _, n := range st.Nodes {
+			if n.Name != nodeName {
+				newNodes = append(newNodes, n)
+			}
 		}
+		st.Nodes = newNodes
+		st.LastUpdated = time.Now()
+		return nil
+	})
+	if err != nil {
+		fmt.Printf("[ERROR] Save error: %v\n", err)
+		return
 	}
-	st.Nodes = newNodes
-	st.LastUpdated = time.Now()
-	s3Backend.SaveState(*st)
 	fmt.Println("[SAVE] State updated.")
 }
 
-func handleResetPassword(client *clo.Client, s3Backend *state.Backend, clusterName string) {
+func handleResetPassword(ctx context.Context, client *clo.Client, s3Backend state.StateStore, clusterName string) {
 	st, _ := s3Backend.LoadState()
 	if st == nil {
 		return
@@ -723,15 +683,15 @@ func handleResetPassword(client *clo.Client, s3Backend *state.Backend, clusterNa
 	newPass, _ := clo.GenerateRandomPassword()
 	for _, node := range st.Nodes {
 		fmt.Printf("Updating %s... ", node.Name)
-		client.SetServerPassword(node.ID, newPass)
+		client.SetServerPassword(ctx, node.ID, newPass)
 		fmt.Printf("[+OK+]\n")
 		time.Sleep(100 * time.Millisecond)
 	}
 }
 
-func handleDeploy(client *clo.Client, backend *state.Backend, clusterName, outputFile string, forks int, runnerMode string, extraArgs string, targetNode string) {
+func handleDeploy(ctx context.Context, client *clo.Client, backend state.StateStore, clusterName, outputFile string, forks int, runnerMode string, extraArgs string, targetNode string) {
 	if backend == nil {
-		fmt.Println("[ERROR] S3 unavailable")
+		fmt.Println("[ERROR] State backend unavailable")
 		os.Exit(1)
 	}
 	fmt.Printf("[REFRESH] Loading state '%s'...\n", clusterName)
@@ -775,7 +735,7 @@ func handleDeploy(client *clo.Client, backend *state.Backend, clusterName, outpu
 	DeployAndRunKubespray(bastionIP, bastionPort, st.SSHUser, keyPath, invPath, forks, runnerMode, extraArgs, backend)
 }
 
-func handleRemoveK8sNode(client *clo.Client, backend *state.Backend, clusterName, outputFile string, forks int, nodeName string) {
+func handleRemoveK8sNode(ctx context.Context, client *clo.Client, backend state.StateStore, clusterName, outputFile string, forks int, nodeName string) {
 	runnerArgs := fmt.Sprintf("-node %s", nodeName)
-	handleDeploy(client, backend, clusterName, outputFile, forks, "remove-node", runnerArgs, nodeName)
+	handleDeploy(ctx, client, backend, clusterName, outputFile, forks, "remove-node", runnerArgs, nodeName)
 }
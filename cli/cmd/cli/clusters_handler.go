@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"cli/internal/clo"
+	"cli/internal/state"
+)
+
+// handleListClusters prints every cluster stored in the state backend
+func handleListClusters(backend state.StateStore, jsonOutput bool) {
+	clusters, err := backend.ListClusters()
+	if err != nil {
+		fmt.Printf("[ERROR] Cluster listing error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if jsonOutput {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(clusters)
+		return
+	}
+
+	if len(clusters) == 0 {
+		fmt.Println("[ENVELOPE] No clusters found.")
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "CLUSTER\tNODES\tROLES\tLAST UPDATE\tLOCK\tDISKS")
+	fmt.Fprintln(w, "-------\t-----\t-----\t-----------\t----\t-----")
+	for _, c := range clusters {
+		lock := "-"
+		if c.Locked() {
+			lock = fmt.Sprintf("locked by %s (%s)", c.Lock.Owner, c.Lock.Command)
+		} else if c.Lock != nil {
+			lock = "expired"
+		}
+		if c.Error != "" {
+			fmt.Fprintf(w, "%s\t?\t?\t%s\t%s\t? (%s)\n", c.Name, c.LastUpdated.Local().Format(time.RFC1123), lock, c.Error)
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\t%d GB\n", c.Name, c.Nodes, formatRoles(c.Roles), c.LastUpdated.Local().Format(time.RFC1123), lock, c.DiskGB)
+	}
+	w.Flush()
+}
+
+// formatRoles renders role counts as "master:3,worker:5"
+func formatRoles(roles map[string]int) string {
+	if len(roles) == 0 {
+		return "-"
+	}
+	var parts []string
+	for r, n := range roles {
+		parts = append(parts, fmt.Sprintf("%s:%d", r, n))
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ",")
+}
+
+// checkLBNameFree refuses an LB name that already exists in the project or that another
+// registered cluster would use (names differing only in case collide in the CLO panel and DNS)
+func checkLBNameFree(ctx context.Context, client *clo.Client, backend state.StateStore, clusterName, lbName string) error {
+	if backend != nil {
+		clusters, err := backend.ListClusters()
+		if err != nil {
+			fmt.Printf("[WARNING] Cluster registry unavailable: %v\n", err)
+		}
+		for _, c := range clusters {
+			if c.Name != clusterName && strings.EqualFold(c.Name+"-main-lb", lbName) {
+				return fmt.Errorf("load balancer name '%s' is also used by cluster '%s'", lbName, c.Name)
+			}
+		}
+	}
+
+	lbList, err := client.GetLoadBalancers(ctx)
+	if err != nil {
+		return fmt.Errorf("load balancer list: %w", err)
+	}
+	for _, lb := range lbList.Result {
+		if lb.Name == lbName {
+			return fmt.Errorf("load balancer '%s' already exists (ID: %s)", lbName, lb.ID)
+		}
+	}
+	return nil
+}
@@ -12,7 +12,7 @@ import (
 )
 
 // handleCreateConfigMap manages the creation of a ConfigMap from JSON
-func handleCreateConfigMap(backend *state.Backend, clusterName, ns, name, jsonData string) {
+func handleCreateConfigMap(backend state.StateStore, clusterName, ns, name, jsonData string) {
 	fmt.Printf("[GEAR] Processing ConfigMap '%s/%s'...\n", ns, name)
 
 	normalizedJSON := strings.ReplaceAll(jsonData, "'", "\"")
@@ -36,7 +36,7 @@ func handleCreateConfigMap(backend *state.Backend, clusterName, ns, name, jsonDa
 
 	// 2. Load state
 	if backend == nil {
-		fmt.Println("[ERROR] S3 Backend unavailable.")
+		fmt.Println("[ERROR] State backend unavailable.")
 		os.Exit(1)
 	}
 	fmt.Printf("[REFRESH] Loading state for cluster '%s'...\n", clusterName)
@@ -1,8 +1,19 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"maps"
+	"net"
 	"os"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"cli/internal/clo"
+	"cli/internal/state"
 
 	"gopkg.in/yaml.v3"
 )
@@ -11,12 +22,31 @@ type Config struct {
 	SSHUser        string      `yaml:"ssh_user"`
 	Groups         []NodeGroup `yaml:"groups"`
 	LoadBalancerIP string      `yaml:"load_balancer_ip,omitempty"`
+	Image          string      `yaml:"image,omitempty"`         // Default image (name or ID) for groups without one
+	Keypairs       []string    `yaml:"keypairs,omitempty"`      // Default keypairs (names or IDs) for groups without them
+	InternalCIDR   string      `yaml:"internal_cidr,omitempty"` // Private network, always allowed by group firewalls (default 10.0.0.0/8)
 }
 
-// InstanceConfig - settings for a specific node
+// InstanceConfig - settings for a specific node. Unset fields inherit from the group (see ForInstance):
+// flavor and cpu_type replace the group values field by field, data disks are merged by name
+// (or by mount_point when unnamed, one of them is required) and bootable: true overrides the boot disk,
+// external_ip/static_ip replace the group values, taints and lb_rules replace the group lists
+// when given (an empty list clears them), labels are added to the group labels.
 type InstanceConfig struct {
-	Enabled bool              `yaml:"enabled"`
-	Labels  map[string]string `yaml:"labels,omitempty"` // Additional labels
+	Enabled    bool              `yaml:"enabled"`
+	Labels     map[string]string `yaml:"labels,omitempty"` // Additional labels
+	Flavor     *Flavor           `yaml:"flavor,omitempty"`
+	CPUType    string            `yaml:"cpu_type,omitempty"`
+	Disks      []Disk            `yaml:"disks,omitempty"`
+	ExternalIP *bool             `yaml:"external_ip,omitempty"`
+	StaticIP   string            `yaml:"static_ip,omitempty"`
+	Taints     []string          `yaml:"taints,omitempty"`
+	LBRules    []LBRuleConfig    `yaml:"lb_rules,omitempty"`
+}
+
+// changesServer reports whether the instance overrides settings of its server request
+func (inst InstanceConfig) changesServer() bool {
+	return inst.Flavor != nil || inst.CPUType != "" || inst.Disks != nil || inst.ExternalIP != nil || inst.StaticIP != ""
 }
 
 type LBRuleConfig struct {
@@ -24,17 +54,45 @@ type LBRuleConfig struct {
 	IntPort int `yaml:"int_port"`
 }
 
+// LBHealthCheck - how the load balancer probes the targets of a group; zero fields take the defaults
+type LBHealthCheck struct {
+	Type       string `yaml:"type,omitempty"`        // TCP (default), HTTP, HTTPS or PING
+	Delay      int    `yaml:"delay,omitempty"`       // Seconds between probes (default 80)
+	Timeout    int    `yaml:"timeout,omitempty"`     // Seconds to wait for an answer (default 15)
+	MaxRetries int    `yaml:"max_retries,omitempty"` // Failed probes before a target is out (default 5)
+}
+
+// FirewallRule - one kind of ingress traffic a group accepts
+type FirewallRule struct {
+	Port     string   `yaml:"port,omitempty"`     // "22" or a range "30000-32767"; empty for icmp
+	Protocol string   `yaml:"protocol,omitempty"` // tcp (default), udp or icmp
+	CIDRs    []string `yaml:"cidrs,omitempty"`    // Allowed sources (default 0.0.0.0/0)
+}
+
+// Firewall - cloud-level ingress policy of a group; traffic not listed (or from internal_cidr) is dropped
+type Firewall struct {
+	Ingress []FirewallRule `yaml:"ingress"`
+}
+
 type NodeGroup struct {
-	NamePrefix string                 `yaml:"name_prefix"`
-	Role       string                 `yaml:"role"`
-	Instances  map[int]InstanceConfig `yaml:"instances"`
-	Flavor     Flavor                 `yaml:"flavor"`
-	Disks      []Disk                 `yaml:"disks"`
-	ExternalIP bool                   `yaml:"external_ip"`
-	StaticIP   string                 `yaml:"static_ip,omitempty"`
-	Labels     map[string]string      `yaml:"labels"`
-	Taints     []string               `yaml:"taints"`
-	LBRules    []LBRuleConfig         `yaml:"lb_rules,omitempty"`
+	NamePrefix    string                 `yaml:"name_prefix"`
+	Role          string                 `yaml:"role"`
+	Instances     map[int]InstanceConfig `yaml:"instances"`
+	Flavor        Flavor                 `yaml:"flavor"`
+	Disks         []Disk                 `yaml:"disks"`
+	ExternalIP    bool                   `yaml:"external_ip"`
+	StaticIP      string                 `yaml:"static_ip,omitempty"`
+	Labels        map[string]string      `yaml:"labels"`
+	Taints        []string               `yaml:"taints"`
+	LBRules       []LBRuleConfig         `yaml:"lb_rules,omitempty"`
+	LBAlgorithm   string                 `yaml:"lb_algorithm,omitempty"` // ROUND_ROBIN (default), LEAST_CONNECTIONS or SOURCE_IP
+	LBHealthCheck *LBHealthCheck         `yaml:"lb_health_check,omitempty"`
+	Firewall      *Firewall              `yaml:"firewall,omitempty"`
+	ResizeInPlace bool                   `yaml:"resize_in_place,omitempty"` // resize existing servers on a flavor change instead of leaving them to -roll
+	Image         string                 `yaml:"image,omitempty"`
+	Keypairs      []string               `yaml:"keypairs,omitempty"`
+	CPUType       string                 `yaml:"cpu_type,omitempty"`  // SHARED (default) or DEDICATED
+	UserData      string                 `yaml:"user_data,omitempty"` // cloud-init user-data
 }
 
 type Flavor struct {
@@ -42,7 +100,12 @@ type Flavor struct {
 	VCPUs int `yaml:"vcpus"`
 }
 
+func (f Flavor) String() string {
+	return fmt.Sprintf("%dGB/%d vCPU", f.RAM, f.VCPUs)
+}
+
 type Disk struct {
+	Name       string `yaml:"name,omitempty"` // stable identity of a data disk within the group, e.g. "pgdata"
 	Size       int    `yaml:"size"`
 	Bootable   bool   `yaml:"bootable"`
 	Type       string `yaml:"type"`
@@ -53,6 +116,185 @@ type Disk struct {
 
 }
 
+var (
+	diskNameRe   = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]*[a-z0-9])?$`)
+	nonNameChars = regexp.MustCompile(`[^a-z0-9]+`)
+)
+
+// diskConfigs finds the config disk of each data disk of a node, keyed by the index in disks:
+// by name, then by mount point, and by size for disks recorded before they had names.
+// A config disk is matched once, so same-size disks of a node do not take the same one.
+func diskConfigs(configDisks []Disk, disks []state.DiskState) map[int]Disk {
+	out := make(map[int]Disk)
+	matched := make(map[string]bool)
+	for _, match := range []func(Disk, state.DiskState) bool{
+		func(cd Disk, d state.DiskState) bool { return d.Name != "" && cd.Name == d.Name },
+		func(cd Disk, d state.DiskState) bool {
+			return d.Name == "" && d.MountPoint != "" && cd.MountPoint == d.MountPoint
+		},
+		func(cd Disk, d state.DiskState) bool { return d.Name == "" && cd.Size == d.Size },
+	} {
+		for i, d := range disks {
+			if _, done := out[i]; done || d.Bootable {
+				continue
+			}
+			for _, cd := range configDisks {
+				if !cd.Bootable && !matched[cd.Name] && match(cd, d) {
+					out[i] = cd
+					matched[cd.Name] = true
+					break
+				}
+			}
+		}
+	}
+	return out
+}
+
+// ForInstance returns the group as instance i sees it, with the overrides of its InstanceConfig applied.
+// Flavor fields and cpu_type that are set replace the group ones. A disk with the name of a group disk
+// (a bootable one matches the boot disk) replaces its set fields, a disk with a new name is added;
+// group disks cannot be removed. external_ip and static_ip replace the group values. Taints and lb_rules,
+// when present, replace the group lists. Labels are merged by DesiredNodes.
+func (g NodeGroup) ForInstance(i int) NodeGroup {
+	inst := g.Instances[i]
+	if inst.Flavor != nil {
+		if inst.Flavor.RAM != 0 {
+			g.Flavor.RAM = inst.Flavor.RAM
+		}
+		if inst.Flavor.VCPUs != 0 {
+			g.Flavor.VCPUs = inst.Flavor.VCPUs
+		}
+	}
+	if inst.CPUType != "" {
+		g.CPUType = inst.CPUType
+	}
+	if inst.Disks != nil {
+		g.Disks = mergeDisks(g.Disks, inst.Disks)
+	}
+	if inst.ExternalIP != nil {
+		g.ExternalIP = *inst.ExternalIP
+	}
+	if inst.StaticIP != "" {
+		g.StaticIP = inst.StaticIP
+	}
+	if inst.Taints != nil {
+		g.Taints = inst.Taints
+	}
+	if inst.LBRules != nil {
+		g.LBRules = inst.LBRules
+	}
+	return g
+}
+
+// mergeDisks applies instance disks over the group disks, matching data disks by name, or by
+// mount point when unnamed. Unmatched disks are added and named by their place in the merged list.
+func mergeDisks(group, inst []Disk) []Disk {
+	out := append([]Disk(nil), group...)
+	for _, d := range inst {
+		j := slices.IndexFunc(out, func(o Disk) bool {
+			switch {
+			case d.Bootable || o.Bootable:
+				return o.Bootable == d.Bootable
+			case d.Name != "":
+				return o.Name == d.Name
+			}
+			return o.MountPoint == d.MountPoint
+		})
+		if j < 0 {
+			if d.Name == "" && !d.Bootable {
+				d.Name = defaultDiskName(d, len(out))
+			}
+			out = append(out, d)
+			continue
+		}
+		o := &out[j]
+		if d.Size != 0 {
+			o.Size = d.Size
+		}
+		if d.Type != "" {
+			o.Type = d.Type
+		}
+		if d.MountPoint != "" {
+			o.MountPoint = d.MountPoint
+		}
+		if d.Owner != "" {
+			o.Owner = d.Owner
+		}
+		if d.Group != "" {
+			o.Group = d.Group
+		}
+		if d.Mode != "" {
+			o.Mode = d.Mode
+		}
+	}
+	return out
+}
+
+// lbRules lists the LB rules any enabled instance of the group serves, group rules first
+func (g NodeGroup) lbRules() []LBRuleConfig {
+	out := append([]LBRuleConfig(nil), g.LBRules...)
+	for _, i := range slices.Sorted(maps.Keys(g.Instances)) {
+		for _, r := range g.Instances[i].LBRules {
+			if g.Instances[i].Enabled && !slices.Contains(out, r) {
+				out = append(out, r)
+			}
+		}
+	}
+	return out
+}
+
+// GroupOf returns the group of a node named <cluster>-<prefix>-<n> with the overrides of instance n applied
+func (c *Config) GroupOf(clusterName, nodeName string) (NodeGroup, bool) {
+	for _, g := range c.Groups {
+		if i, ok := nodeIndex(clusterName, g.NamePrefix, nodeName); ok {
+			return g.ForInstance(i), true
+		}
+	}
+	return NodeGroup{}, false
+}
+
+// nodeIndex returns the instance index of a node named <cluster>-<prefix>-<n>.
+// Nodes of other groups do not match, also when their prefix starts with this one (web and web-api).
+func nodeIndex(clusterName, groupPrefix, nodeName string) (int, bool) {
+	idx, ok := strings.CutPrefix(nodeName, fmt.Sprintf("%s-%s-", clusterName, groupPrefix))
+	i, err := strconv.Atoi(idx)
+	return i, ok && err == nil
+}
+
+// DesiredNode is one enabled instance of a group, with group and instance labels merged
+// and the instance overrides applied to Group
+type DesiredNode struct {
+	Name   string
+	Group  NodeGroup
+	Labels map[string]string
+}
+
+// DesiredNodes lists the nodes the config asks for, named <cluster>-<prefix>-<n>, sorted by name
+func (c *Config) DesiredNodes(clusterName string) []DesiredNode {
+	var out []DesiredNode
+	for _, group := range c.Groups {
+		for i, instCfg := range group.Instances {
+			if !instCfg.Enabled {
+				continue
+			}
+			mergedLabels := make(map[string]string)
+			for k, v := range group.Labels {
+				mergedLabels[k] = v
+			}
+			for k, v := range instCfg.Labels {
+				mergedLabels[k] = v
+			}
+			out = append(out, DesiredNode{
+				Name:   fmt.Sprintf("%s-%s-%d", clusterName, group.NamePrefix, i),
+				Group:  group.ForInstance(i),
+				Labels: mergedLabels,
+			})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
 // GetClusterConfig loads the config from file (if specified) or returns the default
 func GetClusterConfig(configPath string) (*Config, error) {
 	if configPath != "" {
@@ -66,9 +308,296 @@ func GetClusterConfig(configPath string) (*Config, error) {
 		if err := yaml.Unmarshal(data, &cfg); err != nil {
 			return nil, fmt.Errorf("YAML parsing error: %w", err)
 		}
+		cfg.applyDefaults()
+		if err := cfg.Validate(); err != nil {
+			return nil, err
+		}
 		return &cfg, nil
 	}
-	return getDefaultConfig(), nil
+	cfg := getDefaultConfig()
+	cfg.applyDefaults()
+	return cfg, cfg.Validate()
+}
+
+// applyDefaults fills group image, keypairs and cpu_type from the cluster-wide values
+func (c *Config) applyDefaults() {
+	for i := range c.Groups {
+		g := &c.Groups[i]
+		if g.Image == "" {
+			g.Image = c.Image
+		}
+		if len(g.Keypairs) == 0 {
+			g.Keypairs = c.Keypairs
+		}
+		if g.CPUType == "" {
+			g.CPUType = clo.CPUShared
+		}
+		nameDisks(g.Disks)
+	}
+}
+
+// nameDisks gives the data disks without a name their default name
+func nameDisks(disks []Disk) {
+	for j := range disks {
+		if d := &disks[j]; d.Name == "" && !d.Bootable {
+			d.Name = defaultDiskName(*d, j)
+		}
+	}
+}
+
+// defaultDiskName names a data disk after its mount point (/var/lib/data -> var-lib-data),
+// or after its position when it has none
+func defaultDiskName(d Disk, index int) string {
+	name := strings.Trim(nonNameChars.ReplaceAllString(strings.ToLower(mountSlug(d.MountPoint)), "-"), "-")
+	if d.MountPoint == "" || name == "" {
+		return fmt.Sprintf("disk%d", index)
+	}
+	return name
+}
+
+// Load balancer settings used when no group sets them
+var (
+	defaultLBAlgorithm     = "ROUND_ROBIN"
+	defaultLBHealthMonitor = clo.LBHealthMonitor{Delay: 80, MaxRetries: 5, Timeout: 15, Type: "TCP"}
+	lbAlgorithms           = map[string]bool{"ROUND_ROBIN": true, "LEAST_CONNECTIONS": true, "SOURCE_IP": true}
+	lbCheckTypes           = map[string]bool{"TCP": true, "HTTP": true, "HTTPS": true, "PING": true}
+)
+
+// LBSettings returns the algorithm and health check of the cluster load balancer.
+// The cluster has one LB for all groups, so Validate makes sure the groups with lb_rules agree.
+func (c *Config) LBSettings() (string, clo.LBHealthMonitor) {
+	algorithm, monitor := defaultLBAlgorithm, defaultLBHealthMonitor
+	for _, g := range c.Groups {
+		if len(g.lbRules()) == 0 {
+			continue
+		}
+		if g.LBAlgorithm != "" {
+			algorithm = g.LBAlgorithm
+		}
+		if g.LBHealthCheck != nil {
+			monitor = g.LBHealthCheck.monitor()
+		}
+	}
+	return algorithm, monitor
+}
+
+// monitor fills the unset fields of the health check with the defaults
+func (h LBHealthCheck) monitor() clo.LBHealthMonitor {
+	m := defaultLBHealthMonitor
+	if h.Type != "" {
+		m.Type = h.Type
+	}
+	if h.Delay != 0 {
+		m.Delay = h.Delay
+	}
+	if h.Timeout != 0 {
+		m.Timeout = h.Timeout
+	}
+	if h.MaxRetries != 0 {
+		m.MaxRetries = h.MaxRetries
+	}
+	return m
+}
+
+// validateLB checks the LB settings of the groups and that the groups with lb_rules agree on them
+func (c *Config) validateLB() []error {
+	var errs []error
+	var algoGroup, checkGroup string
+	var algo string
+	var check clo.LBHealthMonitor
+	for _, g := range c.Groups {
+		if g.LBAlgorithm != "" && !lbAlgorithms[g.LBAlgorithm] {
+			errs = append(errs, fmt.Errorf("group '%s': lb_algorithm %q is not one of ROUND_ROBIN, LEAST_CONNECTIONS, SOURCE_IP", g.NamePrefix, g.LBAlgorithm))
+		}
+		if h := g.LBHealthCheck; h != nil {
+			m := h.monitor()
+			if !lbCheckTypes[m.Type] {
+				errs = append(errs, fmt.Errorf("group '%s': lb_health_check type %q is not one of TCP, HTTP, HTTPS, PING", g.NamePrefix, m.Type))
+			}
+			if h.Delay < 0 || h.Timeout < 0 || h.MaxRetries < 0 || m.Timeout > m.Delay {
+				errs = append(errs, fmt.Errorf("group '%s': lb_health_check needs positive values and timeout <= delay", g.NamePrefix))
+			}
+		}
+		if len(g.lbRules()) == 0 {
+			continue
+		}
+		for _, r := range g.lbRules() {
+			if r.ExtPort < 1 || r.ExtPort > 65535 || r.IntPort < 1 || r.IntPort > 65535 {
+				errs = append(errs, fmt.Errorf("group '%s': lb rule %d -> %d has a port out of 1-65535", g.NamePrefix, r.ExtPort, r.IntPort))
+			}
+		}
+		if g.LBAlgorithm != "" {
+			if algoGroup != "" && g.LBAlgorithm != algo {
+				errs = append(errs, fmt.Errorf("groups '%s' and '%s' ask for different lb_algorithm, but the cluster has one load balancer", algoGroup, g.NamePrefix))
+			}
+			algoGroup, algo = g.NamePrefix, g.LBAlgorithm
+		}
+		if g.LBHealthCheck != nil {
+			if checkGroup != "" && g.LBHealthCheck.monitor() != check {
+				errs = append(errs, fmt.Errorf("groups '%s' and '%s' ask for different lb_health_check, but the cluster has one load balancer", checkGroup, g.NamePrefix))
+			}
+			checkGroup, check = g.NamePrefix, g.LBHealthCheck.monitor()
+		}
+	}
+	return errs
+}
+
+const defaultInternalCIDR = "10.0.0.0/8"
+
+// FirewallRules builds the security group rules of the group: the internal network first, then the config rules
+func (g NodeGroup) FirewallRules(internalCIDR string) ([]clo.SecurityGroupRule, error) {
+	if internalCIDR == "" {
+		internalCIDR = defaultInternalCIDR
+	}
+	if _, _, err := net.ParseCIDR(internalCIDR); err != nil {
+		return nil, fmt.Errorf("internal_cidr %q is not a CIDR", internalCIDR)
+	}
+	rules := []clo.SecurityGroupRule{{Direction: "ingress", CIDR: internalCIDR}}
+	var errs []error
+	for _, r := range g.Firewall.Ingress {
+		proto := strings.ToLower(r.Protocol)
+		if proto == "" {
+			proto = "tcp"
+		}
+		var portMin, portMax int
+		switch proto {
+		case "tcp", "udp":
+			lo, hi, found := strings.Cut(r.Port, "-")
+			if !found {
+				hi = lo
+			}
+			var errLo, errHi error
+			portMin, errLo = strconv.Atoi(strings.TrimSpace(lo))
+			portMax, errHi = strconv.Atoi(strings.TrimSpace(hi))
+			if errLo != nil || errHi != nil || portMin < 1 || portMax > 65535 || portMin > portMax {
+				errs = append(errs, fmt.Errorf("port %q is not a port or a range like 30000-32767", r.Port))
+				continue
+			}
+		case "icmp":
+			if r.Port != "" {
+				errs = append(errs, fmt.Errorf("icmp rule cannot have a port"))
+				continue
+			}
+		default:
+			errs = append(errs, fmt.Errorf("protocol %q is not one of tcp, udp, icmp", r.Protocol))
+			continue
+		}
+		cidrs := r.CIDRs
+		if len(cidrs) == 0 {
+			cidrs = []string{"0.0.0.0/0"}
+		}
+		for _, c := range cidrs {
+			if _, _, err := net.ParseCIDR(c); err != nil {
+				errs = append(errs, fmt.Errorf("%q is not a CIDR", c))
+				continue
+			}
+			rules = append(rules, clo.SecurityGroupRule{Direction: "ingress", Protocol: proto, PortMin: portMin, PortMax: portMax, CIDR: c})
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return rules, nil
+}
+
+// validateNode checks the settings a node takes from the group, or from the group with instance overrides
+func (g NodeGroup) validateNode(label string) []error {
+	var errs []error
+	if g.StaticIP != "" && !g.ExternalIP {
+		errs = append(errs, fmt.Errorf("%s: static_ip requires external_ip: true", label))
+	}
+	diskNames := make(map[string]bool)
+	for _, d := range g.Disks {
+		switch {
+		case d.Name == "":
+		case d.Bootable:
+			errs = append(errs, fmt.Errorf("%s: disk name %q is for data disks only", label, d.Name))
+		case !diskNameRe.MatchString(d.Name):
+			errs = append(errs, fmt.Errorf("%s: disk name %q must be lowercase letters, digits and dashes", label, d.Name))
+		case diskNames[d.Name]:
+			errs = append(errs, fmt.Errorf("%s: disk name %q is used twice", label, d.Name))
+		}
+		diskNames[d.Name] = true
+	}
+	req := g.ServerRequest("validate", planNodeDisks("", "validate", g, nil, nil, map[string]bool{}), clo.NewAddress(g.ExternalIP))
+	// Image and keypairs may still be names: resolveNames turns them into IDs at plan time
+	req.Image, req.Keypairs = unresolvedID, nil
+	if err := req.Validate(); err != nil {
+		errs = append(errs, fmt.Errorf("%s: %w", label, errors.Unwrap(err)))
+	}
+	return errs
+}
+
+// unresolvedID stands in for image and keypair names during config validation
+const unresolvedID = "00000000-0000-0000-0000-000000000000"
+
+// Validate checks every group and the server request it would produce, before any API call
+func (c *Config) Validate() error {
+	var errs []error
+	seen := make(map[string]bool)
+	for _, g := range c.Groups {
+		if g.NamePrefix == "" {
+			errs = append(errs, fmt.Errorf("group with role '%s' has no name_prefix", g.Role))
+			continue
+		}
+		if seen[g.NamePrefix] {
+			errs = append(errs, fmt.Errorf("group '%s' is defined twice", g.NamePrefix))
+		}
+		seen[g.NamePrefix] = true
+		if g.Image == "" {
+			errs = append(errs, fmt.Errorf("group '%s': no image (set image on the group or cluster-wide)", g.NamePrefix))
+		}
+		for _, k := range g.Keypairs {
+			if strings.TrimSpace(k) == "" {
+				errs = append(errs, fmt.Errorf("group '%s': empty keypair name", g.NamePrefix))
+			}
+		}
+		if g.Firewall != nil {
+			if _, err := g.FirewallRules(c.InternalCIDR); err != nil {
+				errs = append(errs, fmt.Errorf("group '%s': firewall: %w", g.NamePrefix, err))
+			}
+		}
+		errs = append(errs, g.validateNode(fmt.Sprintf("group '%s'", g.NamePrefix))...)
+		for _, i := range slices.Sorted(maps.Keys(g.Instances)) {
+			for j, d := range g.Instances[i].Disks {
+				if !d.Bootable && d.Name == "" && d.MountPoint == "" {
+					errs = append(errs, fmt.Errorf("group '%s' instance %d: disk %d needs a name or mount_point to match a group disk (bootable: true overrides the boot disk)", g.NamePrefix, i, j))
+				}
+			}
+			if g.Instances[i].changesServer() {
+				errs = append(errs, g.ForInstance(i).validateNode(fmt.Sprintf("group '%s' instance %d", g.NamePrefix, i))...)
+			}
+		}
+	}
+	errs = append(errs, c.validateLB()...)
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid configuration:\n%w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// ServerRequest builds the creation request of one node of the group.
+// Disks planned for reattach or restore are not part of it, they are attached after the server is up.
+func (g NodeGroup) ServerRequest(name string, disks []PlannedDisk, addr clo.ServerAddress) clo.CreateServerRequest {
+	req := clo.CreateServerRequest{
+		Name:      name,
+		Flavor:    clo.ServerFlavor{RAM: g.Flavor.RAM, VCPUs: g.Flavor.VCPUs, CPUType: g.CPUType},
+		Addresses: []clo.ServerAddress{addr},
+		Image:     g.Image,
+		Keypairs:  g.Keypairs,
+		UserData:  g.UserData,
+	}
+	for _, d := range disks {
+		if d.Action != "create" {
+			continue
+		}
+		st := clo.ServerStorage{Bootable: d.Bootable, StorageType: d.Type, Size: d.Size, Tags: d.Tags}
+		if d.Name != "" {
+			st.Name = volumeName(name, d.Name)
+		}
+		req.Storages = append(req.Storages, st)
+	}
+	return req
 }
 
 // getDefaultConfig returns the hardcoded configuration
@@ -77,6 +606,8 @@ func getDefaultConfig() *Config {
 	return &Config{
 		SSHUser:        "root",
 		LoadBalancerIP: lbIP,
+		Image:          "389d732c-a53c-4566-984e-e01a7617ff25",
+		Keypairs:       []string{"dd6b1251-ceeb-4fba-afbc-aa34cc4a6990", "92fb9ff8-9396-455f-9aed-a6482fc54ce6"},
 		Groups: []NodeGroup{
 			// --- MASTERS ---
 			{
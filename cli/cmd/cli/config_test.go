@@ -0,0 +1,273 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"cli/internal/state"
+)
+
+func TestConfigValidation(t *testing.T) {
+	cfg := `
+groups:
+  - name_prefix: web
+    instances: {1: {enabled: true}}
+    flavor: {ram: 2, vcpus: 1}
+    cpu_type: TURBO
+    disks:
+      - {size: 10}
+  - name_prefix: db
+    instances: {1: {enabled: true}}
+    flavor: {ram: 0, vcpus: 1}
+    image: ubuntu-22.04
+    keypairs: [my-key, ""]
+    static_ip: addr-1
+    disks:
+      - {size: 10, bootable: true}
+      - {size: 20, bootable: true}
+  - name_prefix: web
+    flavor: {ram: 2, vcpus: 1}
+    disks:
+      - {size: 10, bootable: true}
+`
+	path := filepath.Join(t.TempDir(), "cluster.yaml")
+	os.WriteFile(path, []byte(cfg), 0644)
+
+	_, err := GetClusterConfig(path)
+	if err == nil {
+		t.Fatal("expected validation errors")
+	}
+	if strings.Contains(err.Error(), "group 'db': no image") || strings.Contains(err.Error(), "my-key") {
+		t.Errorf("image and keypair names are valid until resolved at plan time:\n%v", err)
+	}
+	for _, want := range []string{
+		"group 'web'", "cpu_type \"TURBO\"", "exactly one bootable storage required, got 0",
+		"group 'web': no image", "group 'db'", "flavor 0GB/1 vCPU", "got 2", "static_ip requires external_ip",
+		"group 'web' is defined twice", "empty keypair name",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error does not mention %q:\n%v", want, err)
+		}
+	}
+}
+
+func TestConfigDefaults(t *testing.T) {
+	cfg := &Config{
+		Image:    "389d732c-a53c-4566-984e-e01a7617ff25",
+		Keypairs: []string{"dd6b1251-ceeb-4fba-afbc-aa34cc4a6990"},
+		Groups: []NodeGroup{
+			{NamePrefix: "a", Flavor: Flavor{RAM: 2, VCPUs: 1}, Disks: []Disk{{Size: 10, Bootable: true}}},
+			{NamePrefix: "b", Flavor: Flavor{RAM: 2, VCPUs: 1}, Disks: []Disk{{Size: 10, Bootable: true}},
+				Image: "45705da5-3b52-4e75-a0c6-7d4cd0911830", CPUType: "DEDICATED"},
+		},
+	}
+	cfg.applyDefaults()
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	a, b := cfg.Groups[0], cfg.Groups[1]
+	if a.Image != cfg.Image || len(a.Keypairs) != 1 || a.CPUType != "SHARED" {
+		t.Errorf("defaults not applied: %+v", a)
+	}
+	if b.Image != "45705da5-3b52-4e75-a0c6-7d4cd0911830" || b.CPUType != "DEDICATED" {
+		t.Errorf("group values overridden: %+v", b)
+	}
+}
+
+func TestLBSettings(t *testing.T) {
+	group := func(name, algo string, check *LBHealthCheck) NodeGroup {
+		return NodeGroup{NamePrefix: name, Image: "389d732c-a53c-4566-984e-e01a7617ff25", Flavor: Flavor{RAM: 2, VCPUs: 1},
+			Disks: []Disk{{Size: 10, Bootable: true}}, LBRules: []LBRuleConfig{{ExtPort: 80, IntPort: 8080}},
+			LBAlgorithm: algo, LBHealthCheck: check}
+	}
+	cfg := &Config{Groups: []NodeGroup{group("web", "", &LBHealthCheck{Type: "HTTP", Delay: 20}), group("api", "SOURCE_IP", nil)}}
+	cfg.applyDefaults()
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	algo, m := cfg.LBSettings()
+	if algo != "SOURCE_IP" || m.Type != "HTTP" || m.Delay != 20 || m.Timeout != 15 || m.MaxRetries != 5 {
+		t.Fatalf("merged settings: %s %+v", algo, m)
+	}
+
+	cfg = &Config{Groups: []NodeGroup{
+		group("web", "ROUND_ROBIN", &LBHealthCheck{Delay: 5}), group("api", "SOURCE_IP", &LBHealthCheck{Type: "UDP"}), group("ws", "RANDOM", nil),
+	}}
+	cfg.applyDefaults()
+	err := cfg.Validate()
+	for _, want := range []string{
+		"different lb_algorithm", "different lb_health_check", "timeout <= delay", "type \"UDP\"", "lb_algorithm \"RANDOM\"",
+	} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("error does not mention %q:\n%v", want, err)
+		}
+	}
+}
+
+func TestFirewallValidation(t *testing.T) {
+	g := NodeGroup{NamePrefix: "web", Firewall: &Firewall{Ingress: []FirewallRule{
+		{Port: "443"}, {Port: "0"}, {Port: "80-70"}, {Port: "53", Protocol: "sctp"}, {Port: "22", CIDRs: []string{"10.0.0.300/8"}},
+	}}}
+	_, err := g.FirewallRules(defaultInternalCIDR)
+	for _, want := range []string{`"0"`, `"80-70"`, `"sctp"`, `"10.0.0.300/8"`} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("error does not mention %s:\n%v", want, err)
+		}
+	}
+	if err != nil && strings.Contains(err.Error(), "443") {
+		t.Errorf("valid rule reported: %v", err)
+	}
+}
+
+func TestDiskNames(t *testing.T) {
+	g := NodeGroup{NamePrefix: "db", Image: "389d732c-a53c-4566-984e-e01a7617ff25", Flavor: Flavor{RAM: 2, VCPUs: 1}, Disks: []Disk{
+		{Name: "boot", Size: 10, Bootable: true}, {Name: "pgdata", Size: 50}, {Name: "pgdata", Size: 20}, {Name: "WAL", Size: 20},
+	}}
+	cfg := &Config{Groups: []NodeGroup{g}}
+	cfg.applyDefaults()
+	err := cfg.Validate()
+	for _, want := range []string{`"boot" is for data disks only`, `"pgdata" is used twice`, `"WAL" must be lowercase`} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("error does not mention %s:\n%v", want, err)
+		}
+	}
+
+	disks := []Disk{{Size: 10, Bootable: true}, {Name: "a", Size: 20, MountPoint: "/a"}, {Name: "b", Size: 20, MountPoint: "/b"}}
+	for _, tc := range []struct {
+		disk state.DiskState
+		want string
+	}{
+		{state.DiskState{Name: "b", Size: 30, MountPoint: "/a"}, "b"},
+		{state.DiskState{Size: 30, MountPoint: "/b"}, "b"},
+		{state.DiskState{Size: 20}, "a"},
+		{state.DiskState{Name: "c", Size: 20}, ""},
+	} {
+		if cd := diskConfigs(disks, []state.DiskState{tc.disk})[0]; cd.Name != tc.want {
+			t.Errorf("%+v matched %q, want %q", tc.disk, cd.Name, tc.want)
+		}
+	}
+
+	// Two unnamed legacy disks of the same size take one config disk each, named ones first
+	legacy := []state.DiskState{{Size: 20}, {Size: 10, Bootable: true}, {Size: 20}}
+	if got := diskConfigs(disks, legacy); len(got) != 2 || got[0].Name != "a" || got[2].Name != "b" {
+		t.Errorf("same-size disks matched %+v", got)
+	}
+	if got := diskConfigs(disks, []state.DiskState{{Size: 20}, {Name: "a", Size: 20}}); got[0].Name != "b" || got[1].Name != "a" {
+		t.Errorf("a size match took the disk of a named one: %+v", got)
+	}
+}
+
+func TestInstanceOverrides(t *testing.T) {
+	cfg := `
+image: ubuntu-22.04
+groups:
+  - name_prefix: db
+    instances:
+      1:
+        enabled: true
+        labels: {primary: "true"}
+        flavor: {ram: 16}
+        cpu_type: DEDICATED
+        disks:
+          - {size: 40, bootable: true}
+          - {size: 200, mount_point: /var/lib/postgresql}
+          - {name: wal, size: 20, mount_point: /wal}
+        external_ip: true
+        static_ip: addr-1
+        taints: []
+        lb_rules: [{ext_port: 5432, int_port: 5432}]
+      2: {enabled: true}
+      3: {enabled: false, lb_rules: [{ext_port: 5433, int_port: 5432}]}
+    flavor: {ram: 4, vcpus: 2}
+    labels: {app: pg}
+    disks:
+      - {size: 20, bootable: true}
+      - {size: 50, mount_point: /var/lib/postgresql, owner: postgres}
+    taints: [dedicated=db:NoSchedule]
+    lb_rules: [{ext_port: 6432, int_port: 6432}]
+`
+	path := filepath.Join(t.TempDir(), "cluster.yaml")
+	os.WriteFile(path, []byte(cfg), 0644)
+	c, err := GetClusterConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nodes := c.DesiredNodes("prod")
+	if len(nodes) != 2 {
+		t.Fatalf("desired nodes: %+v", nodes)
+	}
+	one, two := nodes[0].Group, nodes[1].Group
+
+	if one.Flavor != (Flavor{RAM: 16, VCPUs: 2}) || one.CPUType != "DEDICATED" {
+		t.Errorf("flavor fields are replaced one by one: %s %s", one.Flavor, one.CPUType)
+	}
+	want := []Disk{
+		{Size: 40, Bootable: true},
+		{Name: "var-lib-postgresql", Size: 200, MountPoint: "/var/lib/postgresql", Owner: "postgres"},
+		{Name: "wal", Size: 20, MountPoint: "/wal"},
+	}
+	if !slices.Equal(one.Disks, want) {
+		t.Errorf("disks are merged by name:\n%+v\nwant\n%+v", one.Disks, want)
+	}
+	if !one.ExternalIP || one.StaticIP != "addr-1" || one.Taints == nil || len(one.Taints) != 0 {
+		t.Errorf("external ip %v %q, taints %v", one.ExternalIP, one.StaticIP, one.Taints)
+	}
+	if len(one.LBRules) != 1 || one.LBRules[0].ExtPort != 5432 || nodes[0].Labels["app"] != "pg" || nodes[0].Labels["primary"] != "true" {
+		t.Errorf("lb rules %v, labels %v", one.LBRules, nodes[0].Labels)
+	}
+
+	if two.Flavor != (Flavor{RAM: 4, VCPUs: 2}) || two.CPUType != "SHARED" || len(two.Disks) != 2 || two.Disks[1].Size != 50 ||
+		two.ExternalIP || len(two.Taints) != 1 || len(two.LBRules) != 1 || two.LBRules[0].ExtPort != 6432 {
+		t.Errorf("instance without overrides differs from the group: %+v", two)
+	}
+	os.WriteFile(path, []byte(strings.Replace(cfg, "{size: 50, mount_point: /var/lib/postgresql, owner: postgres}", "{name: pgdata, size: 50, mount_point: /var/lib/postgresql}", 1)), 0644)
+	named, err := GetClusterConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := named.Groups[0].ForInstance(1).Disks; len(d) != 3 || d[1].Name != "pgdata" || d[1].Size != 200 || d[2].Name != "wal" {
+		t.Errorf("an unnamed instance disk overrides the group disk with its mount point: %+v", d)
+	}
+	if c.Groups[0].Disks[1].Size != 50 || c.Groups[0].Flavor.RAM != 4 {
+		t.Error("overrides leaked into the group")
+	}
+	if g, ok := c.GroupOf("prod", "prod-db-1"); !ok || g.Flavor.RAM != 16 {
+		t.Errorf("GroupOf: %v %+v", ok, g)
+	}
+	var ports []int
+	for _, r := range c.Groups[0].lbRules() {
+		ports = append(ports, r.ExtPort)
+	}
+	if !slices.Equal(ports, []int{6432, 5432}) {
+		t.Errorf("the LB serves the rules of enabled instances only: %v", ports)
+	}
+
+	os.WriteFile(path, []byte(strings.Replace(cfg, "2: {enabled: true}", "2: {enabled: true, static_ip: addr-2, cpu_type: TURBO, disks: [{size: 30}]}", 1)), 0644)
+	_, err = GetClusterConfig(path)
+	for _, want := range []string{
+		"group 'db' instance 2: static_ip requires external_ip",
+		`group 'db' instance 2: cpu_type "TURBO"`,
+		"group 'db' instance 2: disk 0 needs a name or mount_point",
+	} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("error does not mention %q:\n%v", want, err)
+		}
+	}
+}
+
+func TestInvalidConfigMakesNoAPICalls(t *testing.T) {
+	e := newTestEnv(t, strings.Replace(testConfig, "cpu_type: DEDICATED", "cpu_type: dedicated", 1))
+	e.reconcile()
+	if calls := e.fake.Calls(); len(calls) != 0 {
+		t.Fatalf("API called with an invalid config: %v", calls)
+	}
+}
+
+func TestDefaultConfigIsValid(t *testing.T) {
+	if _, err := GetClusterConfig(""); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,353 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"cli/internal/clo"
+	"cli/internal/state"
+)
+
+// DriftItem is one difference between config, state and the cloud
+type DriftItem struct {
+	Kind     string `json:"kind"`
+	Resource string `json:"resource"`
+	Detail   string `json:"detail"`
+}
+
+// DriftReport is the result of -drift
+type DriftReport struct {
+	Cluster string      `json:"cluster"`
+	Checked time.Time   `json:"checked_at"`
+	Drift   bool        `json:"drift"`
+	Items   []DriftItem `json:"items"`
+}
+
+// liveInventory is a read-only snapshot of the CLO project
+type liveInventory struct {
+	Servers   map[string]string                    // name -> ID
+	Details   map[string]*clo.ServerDetailResponse // ID -> detail
+	Volumes   []clo.DiskResult
+	Addresses map[string]clo.AddressDetail
+	LBs       []clo.LoadBalancerDetail
+
+	SecurityGroups      []clo.SecurityGroup
+	FirewallUnsupported bool // the project has no security groups
+}
+
+// handleDrift compares config, state and live resources without changing anything.
+// Exits with 2 when drift is found, so CI can alert on it.
+func handleDrift(ctx context.Context, client *clo.Client, backend state.StateStore, clusterName, configPath string, jsonOutput bool) {
+	cfg, err := GetClusterConfig(configPath)
+	if err != nil {
+		fmt.Printf("[ERROR] Configuration error: %v\n", err)
+		os.Exit(1)
+	}
+	st, err := backend.LoadState()
+	if err != nil {
+		fmt.Printf("[ERROR] State load error: %v\n", err)
+		os.Exit(1)
+	}
+	if st == nil {
+		st = &state.ClusterState{}
+	}
+
+	live, err := fetchLiveInventory(ctx, client)
+	if err != nil {
+		fmt.Printf("[ERROR] Cloud API error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if live.FirewallUnsupported && len(firewallGroups(cfg)) > 0 && !jsonOutput {
+		fmt.Println("[WARNING] Security groups are not supported in this project, firewall sections are not checked.")
+	}
+	items := computeDrift(cfg, clusterName, st, live)
+	report := DriftReport{Cluster: clusterName, Checked: time.Now(), Drift: len(items) > 0, Items: items}
+
+	if jsonOutput {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(report)
+	} else if len(items) == 0 {
+		fmt.Printf("[+OK+] No drift: config, state and cloud agree for '%s'.\n", clusterName)
+	} else {
+		fmt.Printf("[WARNING] Drift detected for '%s': %d item(s)\n\n", clusterName, len(items))
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+		fmt.Fprintln(w, "KIND\tRESOURCE\tDETAIL")
+		fmt.Fprintln(w, "----\t--------\t------")
+		for _, it := range items {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", it.Kind, it.Resource, it.Detail)
+		}
+		w.Flush()
+	}
+
+	if report.Drift {
+		os.Exit(2)
+	}
+}
+
+// fetchLiveInventory reads servers, volumes, addresses, load balancers and security groups of the project
+func fetchLiveInventory(ctx context.Context, client *clo.Client) (*liveInventory, error) {
+	live := &liveInventory{Servers: map[string]string{}, Details: map[string]*clo.ServerDetailResponse{}}
+
+	servers, err := client.GetServersList(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range servers.Result {
+		live.Servers[s.Name] = s.ID
+		detail, err := client.GetServerDetail(ctx, s.ID)
+		if err != nil {
+			return nil, fmt.Errorf("server %s: %w", s.Name, err)
+		}
+		live.Details[s.ID] = detail
+	}
+
+	vols, err := client.GetProjectVolumes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	live.Volumes = vols.Result
+
+	if live.Addresses, err = client.GetProjectAddressesMap(ctx); err != nil {
+		return nil, err
+	}
+
+	lbs, err := client.GetLoadBalancers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	live.LBs = lbs.Result
+
+	sgs, err := client.GetSecurityGroups(ctx)
+	switch {
+	case clo.IsUnsupported(err) || clo.IsNotFound(err):
+		live.FirewallUnsupported = true
+	case err != nil:
+		return nil, err
+	default:
+		live.SecurityGroups = sgs.Result
+	}
+	return live, nil
+}
+
+// computeDrift lists every difference between the desired config, the stored state and the cloud
+func computeDrift(cfg *Config, clusterName string, st *state.ClusterState, live *liveInventory) []DriftItem {
+	var items []DriftItem
+	add := func(kind, res, format string, args ...any) {
+		items = append(items, DriftItem{Kind: kind, Resource: res, Detail: fmt.Sprintf(format, args...)})
+	}
+
+	stateNodes := make(map[string]state.NodeState)
+	stateServerIDs := make(map[string]bool)
+	for _, n := range st.Nodes {
+		stateNodes[n.Name] = n
+		stateServerIDs[n.ID] = true
+	}
+	liveIDs := make(map[string]bool)
+	for _, id := range live.Servers {
+		liveIDs[id] = true
+	}
+
+	// --- Desired nodes vs state vs cloud ---
+	desired := make(map[string]bool)
+	for _, dn := range cfg.DesiredNodes(clusterName) {
+		desired[dn.Name] = true
+		sn, inState := stateNodes[dn.Name]
+		liveID, inCloud := live.Servers[dn.Name]
+
+		switch {
+		case !inState && !inCloud:
+			add("missing_node", dn.Name, "in config (group '%s'), not created", dn.Group.NamePrefix)
+			continue
+		case !inState:
+			add("unrecorded_node", dn.Name, "server %s exists but is not in state (run -cluster to adopt)", liveID)
+		case !liveIDs[sn.ID]:
+			add("lost_node", dn.Name, "server %s from state no longer exists", sn.ID)
+			continue
+		}
+
+		id := sn.ID
+		if !inState {
+			id = liveID
+		}
+		if d, ok := live.Details[id]; ok {
+			f := d.Result.Flavor
+			if f.RAM != dn.Group.Flavor.RAM || f.VCPUs != dn.Group.Flavor.VCPUs {
+				add("flavor_mismatch", dn.Name, "live %dGB/%d vCPU, config %dGB/%d vCPU", f.RAM, f.VCPUs, dn.Group.Flavor.RAM, dn.Group.Flavor.VCPUs)
+			}
+		}
+		if !inState {
+			continue
+		}
+		if diff := diffLabels(sn.Labels, dn.Labels); diff != "" {
+			add("label_mismatch", dn.Name, "%s", diff)
+		}
+		if diff := diffTaints(sn.Taints, dn.Group.Taints); diff != "" {
+			add("taint_mismatch", dn.Name, "%s", diff)
+		}
+		if sn.AddressID != "" {
+			if _, ok := live.Addresses[sn.AddressID]; !ok {
+				add("missing_address", dn.Name, "address %s from state no longer exists", sn.AddressID)
+			}
+		}
+	}
+
+	for _, n := range st.Nodes {
+		if !desired[n.Name] {
+			add("extra_node", n.Name, "in state (server %s) but not in config", n.ID)
+		}
+	}
+
+	var names []string
+	for name := range live.Servers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		id := live.Servers[name]
+		if !stateServerIDs[id] && !desired[name] {
+			add("unmanaged_server", name, "server %s is not in state or config", id)
+		}
+	}
+
+	// --- Volumes ---
+	volumes := make(map[string]clo.DiskResult)
+	for _, v := range live.Volumes {
+		volumes[v.ID] = v
+	}
+	known := make(map[string]bool)
+	for _, n := range st.Nodes {
+		for _, d := range n.Disks {
+			known[d.ID] = true
+			v, ok := volumes[d.ID]
+			switch {
+			case !ok && d.Critical:
+				add("missing_critical_disk", n.Name, "critical volume %s (%s) no longer exists", d.ID, d.MountPoint)
+			case !ok:
+				add("missing_volume", n.Name, "volume %s (%dGB) no longer exists", d.ID, d.Size)
+			case d.Critical && (v.AttachedToServer == nil || v.AttachedToServer.ID != n.ID):
+				add("detached_critical_disk", n.Name, "critical volume %s (%s) is %s, not attached to the node", d.ID, d.MountPoint, strings.ToLower(v.Status))
+			}
+		}
+	}
+	for _, v := range live.Volumes {
+		if known[v.ID] {
+			continue
+		}
+		if v.AttachedToServer == nil {
+			add("orphaned_volume", v.ID, "%dGB %s volume '%s' is %s and not in state", v.Size, v.Type, v.Name, strings.ToLower(v.Status))
+		} else if stateServerIDs[v.AttachedToServer.ID] {
+			add("unrecorded_volume", v.ID, "%dGB volume attached to a cluster node as %s, not in state", v.Size, v.AttachedToServer.Device)
+		}
+	}
+
+	// --- Load balancer ---
+	wantLB := false
+	for _, g := range cfg.Groups {
+		if len(g.lbRules()) > 0 {
+			wantLB = true
+		}
+	}
+	lbName := fmt.Sprintf("%s-main-lb", clusterName)
+	hasLB := false
+	for _, lb := range live.LBs {
+		if lb.Name == lbName {
+			hasLB = true
+		}
+	}
+	if wantLB && !hasLB {
+		add("missing_lb", lbName, "config has lb_rules but the load balancer does not exist (run -create-lb)")
+	}
+	if !wantLB && hasLB {
+		add("extra_lb", lbName, "load balancer exists but no group has lb_rules")
+	}
+
+	// --- Firewalls ---
+	if !live.FirewallUnsupported {
+		sgs := make(map[string]clo.SecurityGroup)
+		for _, sg := range live.SecurityGroups {
+			sgs[sg.Name] = sg
+		}
+		for _, g := range cfg.Groups {
+			name := firewallName(clusterName, g.NamePrefix)
+			sg, exists := sgs[name]
+			if g.Firewall == nil {
+				if exists {
+					add("extra_firewall", name, "security group exists but group '%s' has no firewall section", g.NamePrefix)
+				}
+				continue
+			}
+			if !exists {
+				add("missing_firewall", name, "group '%s' has a firewall section but the security group does not exist", g.NamePrefix)
+				continue
+			}
+			if rules, err := g.FirewallRules(cfg.InternalCIDR); err == nil {
+				if diff := diffFirewallRules(sg.Rules, rules); len(diff) > 0 {
+					add("firewall_rules", name, "%s", strings.Join(diff, ", "))
+				}
+			}
+			attached := make(map[string]bool)
+			for _, id := range sg.Servers {
+				attached[id] = true
+			}
+			for _, n := range st.Nodes {
+				if _, ok := nodeIndex(clusterName, g.NamePrefix, n.Name); ok && liveIDs[n.ID] && !attached[n.ID] {
+					add("firewall_not_attached", n.Name, "security group %s is not applied to server %s", name, n.ID)
+				}
+			}
+		}
+	}
+
+	return items
+}
+
+// diffLabels describes label differences as "+k=v -k=v ~k=old->new"; state is compared against config
+func diffLabels(have, want map[string]string) string {
+	var parts []string
+	for k, v := range want {
+		if old, ok := have[k]; !ok {
+			parts = append(parts, fmt.Sprintf("+%s=%s", k, v))
+		} else if old != v {
+			parts = append(parts, fmt.Sprintf("~%s=%s->%s", k, old, v))
+		}
+	}
+	for k, v := range have {
+		if _, ok := want[k]; !ok {
+			parts = append(parts, fmt.Sprintf("-%s=%s", k, v))
+		}
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, " ")
+}
+
+// diffTaints describes taints missing from (+) or extra in (-) the state
+func diffTaints(have, want []string) string {
+	h := make(map[string]bool)
+	for _, t := range have {
+		h[t] = true
+	}
+	w := make(map[string]bool)
+	for _, t := range want {
+		w[t] = true
+	}
+	var parts []string
+	for _, t := range want {
+		if !h[t] {
+			parts = append(parts, "+"+t)
+		}
+	}
+	for _, t := range have {
+		if !w[t] {
+			parts = append(parts, "-"+t)
+		}
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"cli/internal/clo"
+)
+
+// firewallName is the security group of a node group: <cluster>-<prefix>-fw
+func firewallName(clusterName, prefix string) string {
+	return fmt.Sprintf("%s-%s-fw", clusterName, prefix)
+}
+
+// firewallGroups returns the groups with a firewall section
+func firewallGroups(cfg *Config) []NodeGroup {
+	var out []NodeGroup
+	for _, g := range cfg.Groups {
+		if g.Firewall != nil {
+			out = append(out, g)
+		}
+	}
+	return out
+}
+
+// reconcileFirewalls creates or updates the security group of every group with a firewall section
+// and attaches it to the nodes of the group. Projects without security groups only get a warning.
+func reconcileFirewalls(ctx context.Context, client *clo.Client, clusterName string, cfg *Config, nodes []NodeResult) {
+	groups := firewallGroups(cfg)
+	if len(groups) == 0 {
+		return
+	}
+	fmt.Println("\n[SHIELD] Reconciling firewalls...")
+	list, err := client.GetSecurityGroups(ctx)
+	// A project without the feature may also answer 404 for the whole collection
+	if clo.IsUnsupported(err) || clo.IsNotFound(err) {
+		fmt.Printf("[WARNING] Security groups are not supported in this project, firewall sections are ignored: %v\n", err)
+		return
+	}
+	if err != nil {
+		fmt.Printf("[ERROR] Security groups API error: %v\n", err)
+		return
+	}
+	byName := make(map[string]clo.SecurityGroup)
+	for _, sg := range list.Result {
+		byName[sg.Name] = sg
+	}
+
+	for _, g := range groups {
+		name := firewallName(clusterName, g.NamePrefix)
+		rules, err := g.FirewallRules(cfg.InternalCIDR)
+		if err != nil {
+			fmt.Printf("[ERROR] [%s] %v\n", name, err)
+			continue
+		}
+
+		sg, exists := byName[name]
+		if !exists {
+			id, err := client.CreateSecurityGroup(ctx, clo.CreateSecurityGroupRequest{
+				Name: name, Description: fmt.Sprintf("Ingress of group '%s' of cluster '%s'", g.NamePrefix, clusterName), Rules: rules,
+			})
+			if err != nil {
+				fmt.Printf("[ERROR] [%s] Create error: %v\n", name, err)
+				continue
+			}
+			fmt.Printf("[+OK+] [%s] Created with %d rule(s). ID: %s\n", name, len(rules), id)
+			sg = clo.SecurityGroup{ID: id, Name: name, Rules: rules}
+		} else if changes := diffFirewallRules(sg.Rules, rules); len(changes) > 0 {
+			fmt.Printf("[GEAR] [%s] Updating rules:\n", name)
+			for _, c := range changes {
+				fmt.Printf("   %s\n", c)
+			}
+			if err := client.SetSecurityGroupRules(ctx, sg.ID, rules); err != nil {
+				fmt.Printf("[ERROR] [%s] Update error: %v\n", name, err)
+				continue
+			}
+		} else {
+			fmt.Printf("[STAR] [%s] Rules are up to date.\n", name)
+		}
+
+		attached := make(map[string]bool)
+		for _, id := range sg.Servers {
+			attached[id] = true
+		}
+		for _, n := range nodes {
+			if _, ok := nodeIndex(clusterName, g.NamePrefix, n.Name); !ok || n.ID == "" || attached[n.ID] {
+				continue
+			}
+			if err := client.AttachSecurityGroup(ctx, sg.ID, n.ID); err != nil {
+				fmt.Printf("   [ERROR] [%s] Attach to %s failed: %v\n", name, n.Name, err)
+				continue
+			}
+			fmt.Printf("   [+OK+] [%s] Attached to %s\n", name, n.Name)
+		}
+	}
+}
+
+// diffFirewallRules lists the rules to add (+) and remove (-) to go from have to want
+func diffFirewallRules(have, want []clo.SecurityGroupRule) []string {
+	h := make(map[clo.SecurityGroupRule]bool)
+	for _, r := range have {
+		h[r] = true
+	}
+	w := make(map[clo.SecurityGroupRule]bool)
+	for _, r := range want {
+		w[r] = true
+	}
+	var out []string
+	for _, r := range want {
+		if !h[r] {
+			out = append(out, "+ allow "+describeFirewallRule(r))
+		}
+	}
+	for _, r := range have {
+		if !w[r] {
+			out = append(out, "- allow "+describeFirewallRule(r))
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// describeFirewallRule prints a rule as "tcp 30000-32767 from 0.0.0.0/0"
+func describeFirewallRule(r clo.SecurityGroupRule) string {
+	switch {
+	case r.Protocol == "":
+		return "all from " + r.CIDR
+	case r.PortMin == 0:
+		return fmt.Sprintf("%s from %s", r.Protocol, r.CIDR)
+	case r.PortMin == r.PortMax:
+		return fmt.Sprintf("%s %d from %s", r.Protocol, r.PortMin, r.CIDR)
+	default:
+		return fmt.Sprintf("%s %d-%d from %s", r.Protocol, r.PortMin, r.PortMax, r.CIDR)
+	}
+}
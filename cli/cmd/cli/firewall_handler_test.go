@@ -0,0 +1,129 @@
+package main
+
+import (
+	"os"
+	"slices"
+	"strings"
+	"testing"
+
+	"cli/internal/clo"
+)
+
+var firewallConfig = strings.Replace(testConfig, `    lb_rules:
+      - {ext_port: 2222, int_port: 22}`, `    lb_rules:
+      - {ext_port: 2222, int_port: 22}
+    firewall:
+      ingress:
+        - {port: 22, cidrs: [203.0.113.0/24]}
+        - {port: 30000-32767}`, 1)
+
+func TestFirewallIsCreatedAndAttached(t *testing.T) {
+	e := newTestEnv(t, firewallConfig)
+	e.fake.SecurityGroupsEnabled = true
+	e.reconcile()
+
+	sgs := e.fake.SecurityGroups()
+	if len(sgs) != 1 || sgs[0].Name != "test-bastion-fw" {
+		t.Fatalf("security groups: %+v", sgs)
+	}
+	want := []clo.SecurityGroupRule{
+		{Direction: "ingress", CIDR: "10.0.0.0/8"},
+		{Direction: "ingress", Protocol: "tcp", PortMin: 22, PortMax: 22, CIDR: "203.0.113.0/24"},
+		{Direction: "ingress", Protocol: "tcp", PortMin: 30000, PortMax: 32767, CIDR: "0.0.0.0/0"},
+	}
+	if diff := diffFirewallRules(sgs[0].Rules, want); len(diff) != 0 {
+		t.Fatalf("rules differ: %v", diff)
+	}
+	if bastion := e.node("test-bastion-1"); len(sgs[0].Servers) != 1 || sgs[0].Servers[0] != bastion.ID {
+		t.Fatalf("attached to %v, want only %s", sgs[0].Servers, bastion.ID)
+	}
+
+	e.reconcile()
+	if n := e.fake.CountCalls("PUT", "/security_groups/") + e.fake.CountCalls("POST", "/security_groups/"); n != 1 {
+		t.Fatalf("%d writes to security groups over two runs, want only the attach", n)
+	}
+}
+
+func TestFirewallRulesFollowConfig(t *testing.T) {
+	e := newTestEnv(t, firewallConfig)
+	e.fake.SecurityGroupsEnabled = true
+	e.reconcile()
+
+	cfg := strings.Replace(firewallConfig, "203.0.113.0/24", "198.51.100.0/24", 1)
+	os.WriteFile(e.config, []byte(cfg), 0644)
+	e.reconcile()
+
+	rules := e.fake.SecurityGroups()[0].Rules
+	for _, r := range rules {
+		if r.CIDR == "203.0.113.0/24" {
+			t.Fatalf("old rule kept: %+v", rules)
+		}
+	}
+	if n := e.fake.CountCalls("PUT", "/security_groups/"); n != 1 {
+		t.Fatalf("%d rule updates, want 1", n)
+	}
+}
+
+func TestFirewallDrift(t *testing.T) {
+	e := newTestEnv(t, firewallConfig)
+	e.fake.SecurityGroupsEnabled = true
+	e.reconcile()
+
+	sg := e.fake.SecurityGroups()[0]
+	open := append(sg.Rules, clo.SecurityGroupRule{Direction: "ingress", Protocol: "tcp", PortMin: 5432, PortMax: 5432, CIDR: "0.0.0.0/0"})
+	if err := e.client.SetSecurityGroupRules(t.Context(), sg.ID, open); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, _ := GetClusterConfig(e.config)
+	live, err := fetchLiveInventory(t.Context(), e.client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	items := computeDrift(cfg, testCluster, e.state(), live)
+	if len(items) != 1 || items[0].Kind != "firewall_rules" || !strings.Contains(items[0].Detail, "- allow tcp 5432 from 0.0.0.0/0") {
+		t.Fatalf("drift: %+v", items)
+	}
+}
+
+func TestFirewallIgnoredWhenUnsupported(t *testing.T) {
+	e := newTestEnv(t, firewallConfig)
+	e.reconcile()
+
+	if len(e.state().Nodes) != 3 {
+		t.Fatalf("cluster not created: %+v", e.state().Nodes)
+	}
+	for _, c := range e.fake.Calls() {
+		if strings.Contains(c, "security_groups") && !strings.HasPrefix(c, "GET ") {
+			t.Fatalf("security group write on a project without the feature: %s", c)
+		}
+	}
+
+	cfg, _ := GetClusterConfig(e.config)
+	live, err := fetchLiveInventory(t.Context(), e.client)
+	if err != nil || !live.FirewallUnsupported {
+		t.Fatalf("inventory: %v, unsupported=%v", err, live.FirewallUnsupported)
+	}
+	if items := computeDrift(cfg, testCluster, e.state(), live); len(items) != 0 {
+		t.Fatalf("unexpected drift: %+v", items)
+	}
+}
+
+func TestFirewallIsNotAttachedToGroupsWithTheSamePrefix(t *testing.T) {
+	cfg := firewallConfig + `  - name_prefix: bastion-extra
+    role: worker
+    instances:
+      1: {enabled: true}
+    flavor: {ram: 2, vcpus: 1}
+    disks:
+      - {size: 10, bootable: true}
+`
+	e := newTestEnv(t, cfg)
+	e.fake.SecurityGroupsEnabled = true
+	e.reconcile()
+
+	sgs := e.fake.SecurityGroups()
+	if bastion := e.node("test-bastion-1"); len(sgs) != 1 || !slices.Equal(sgs[0].Servers, []string{bastion.ID}) {
+		t.Fatalf("security groups %+v, want only %s attached", sgs, bastion.ID)
+	}
+}
@@ -15,9 +15,9 @@ import (
 )
 
 // handleFlux - entry point for -flux command
-func handleFlux(backend *state.Backend, clusterName, token, s3Access, s3Secret, ageKey, acmeEmail, domain string) {
+func handleFlux(backend state.StateStore, clusterName, token, s3Access, s3Secret, ageKey, acmeEmail, domain string) {
 	if backend == nil {
-		fmt.Println("[ERROR] S3 Backend unavailable.")
+		fmt.Println("[ERROR] State backend unavailable.")
 		os.Exit(1)
 	}
 
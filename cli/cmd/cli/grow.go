@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+
+	"cli/internal/clo"
+	"cli/internal/state"
+)
+
+// growDisks extends the planned volumes and records their new size on the nodes.
+// It returns the names of the nodes with a grown disk, whose filesystems still need growing.
+func growDisks(ctx context.Context, client *clo.Client, items []PlanGrow, nodes []NodeResult) []string {
+	var grown []string
+	for _, g := range items {
+		fmt.Printf("\n[DISK/STORAGE] [%s] Extending %s (%s) %dGB -> %dGB...\n", g.Node, g.MountPoint, g.VolumeID, g.From, g.To)
+		err := client.ExtendVolume(ctx, g.VolumeID, g.To)
+		if err == nil {
+			err = client.WaitForVolumeStatus(ctx, g.VolumeID, "IN_USE", 60, statusPollInterval)
+		}
+		if err != nil {
+			fmt.Printf("[ERROR] [%s] Extend error: %v\n", g.Node, err)
+			continue
+		}
+		for i := range nodes {
+			if nodes[i].Name != g.Node {
+				continue
+			}
+			disks := append([]state.DiskState(nil), nodes[i].Disks...)
+			for j := range disks {
+				if disks[j].ID == g.VolumeID {
+					disks[j].Size, disks[j].Updated = g.To, time.Now().Format(time.RFC3339)
+				}
+			}
+			nodes[i].Disks = disks
+		}
+		if len(grown) == 0 || grown[len(grown)-1] != g.Node {
+			grown = append(grown, g.Node)
+		}
+		fmt.Printf("[+OK+] [%s] Volume extended.\n", g.Node)
+	}
+	return grown
+}
+
+// growFilesystems runs the grow-fs playbook of the runner on the nodes through the bastion.
+// With noCheck (no SSH from here) it only tells how to run it.
+func growFilesystems(backend state.StateStore, inventoryPath, sshUser string, nodes []NodeResult, names []string, noCheck bool) {
+	limit := strings.Join(names, ",")
+	if noCheck {
+		fmt.Printf("\n[SKIP] Filesystems not grown (-nocheck). Run -grow-fs -l %s\n", limit)
+		return
+	}
+	err := func() error {
+		bastionIP, bastionPort := "", 22
+		for _, n := range nodes {
+			if n.Role == "BASTION" {
+				bastionIP = n.IP
+				if n.SSHPort != 0 {
+					bastionPort = n.SSHPort
+				}
+			}
+		}
+		if bastionIP == "" {
+			return fmt.Errorf("bastion missing")
+		}
+		if inventoryPath == "" {
+			inventoryPath = "inventory.gen.yaml"
+			saveToAnsibleInventory(inventoryPath, sshUser, nodes, "")
+		}
+		keyPath := os.ExpandEnv("${HOME}/.ssh/clo")
+		sshConfig := &ssh.ClientConfig{
+			User:            sshUser,
+			Auth:            getAuthMethods("", keyPath),
+			HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+			Timeout:         30 * time.Second,
+		}
+		client, err := ssh.Dial("tcp", net.JoinHostPort(bastionIP, fmt.Sprintf("%d", bastionPort)), sshConfig)
+		if err != nil {
+			return fmt.Errorf("ssh dial: %w", err)
+		}
+		defer client.Close()
+		fmt.Printf("\n[DISK/STORAGE] Growing filesystems on %s...\n", limit)
+		return runRunnerStep(client, inventoryPath, keyPath, runnerEnv(backend), "grow-fs", "-l", limit)
+	}()
+	if err != nil {
+		fmt.Printf("[ERROR] Filesystem grow error: %v\n", err)
+		fmt.Printf("   [INFO] The volumes are extended. Run -grow-fs -l %s to retry.\n", limit)
+		return
+	}
+	fmt.Println("[+OK+] Filesystems grown.")
+}
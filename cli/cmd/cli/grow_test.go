@@ -0,0 +1,102 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+// namedDiskConfig is testConfig with the master data disk named
+var namedDiskConfig = strings.Replace(testConfig, "{size: 50, mount_point: /data}", "{name: data, size: 50, mount_point: /data}", 1)
+
+func TestDiskGrowsInPlace(t *testing.T) {
+	e := newTestEnv(t, namedDiskConfig)
+	e.reconcile()
+	before, _ := dataDisk(e.node("test-master-1"))
+	if before.Name != "data" {
+		t.Fatalf("disk name not recorded: %+v", before)
+	}
+
+	os.WriteFile(e.config, []byte(strings.Replace(namedDiskConfig, "size: 50", "size: 80", 1)), 0644)
+	p := e.plan()
+	if len(p.Grow) != 2 || p.Grow[0].From != 50 || p.Grow[0].To != 80 || !p.HasChanges() {
+		t.Fatalf("want 2 disks to grow 50 -> 80, got %+v", p.Grow)
+	}
+	if len(p.Create) != 0 || len(p.Keep) != 3 {
+		t.Errorf("nodes must be kept, got keep %d create %d", len(p.Keep), len(p.Create))
+	}
+
+	e.reconcile()
+	for _, name := range []string{"test-master-1", "test-master-2"} {
+		d, _ := dataDisk(e.node(name))
+		if d.Size != 80 || d.Name != "data" {
+			t.Errorf("%s: state disk %+v", name, d)
+		}
+		if v, _ := e.fake.Volume(d.ID); v.Size != 80 || v.ServerID == "" {
+			t.Errorf("%s: volume %+v", name, v)
+		}
+	}
+	after, _ := dataDisk(e.node("test-master-1"))
+	if after.ID != before.ID {
+		t.Errorf("volume replaced: %s -> %s", before.ID, after.ID)
+	}
+	if p := e.plan(); len(p.Grow) != 0 || p.HasChanges() {
+		t.Errorf("growth not converged: %+v", p.Grow)
+	}
+}
+
+func TestUnnamedDiskIsNamedByMountPoint(t *testing.T) {
+	e := newTestEnv(t, testConfig)
+	e.reconcile()
+
+	os.WriteFile(e.config, []byte(strings.Replace(namedDiskConfig, "size: 50", "size: 60", 1)), 0644)
+	e.reconcile()
+	d, _ := dataDisk(e.node("test-master-2"))
+	if d.Name != "data" || d.Size != 60 {
+		t.Errorf("legacy disk not matched by mount point: %+v", d)
+	}
+}
+
+func TestDiskIsNotShrunk(t *testing.T) {
+	e := newTestEnv(t, namedDiskConfig)
+	e.reconcile()
+
+	os.WriteFile(e.config, []byte(strings.Replace(namedDiskConfig, "size: 50", "size: 30", 1)), 0644)
+	if p := e.plan(); len(p.Grow) != 0 || p.HasChanges() {
+		t.Fatalf("shrink planned: %+v", p.Grow)
+	}
+	e.reconcile()
+	if n := e.fake.CountCalls("POST", "/volumes/"); n != 0 {
+		t.Errorf("volume calls on shrink: %d", n)
+	}
+	if d, _ := dataDisk(e.node("test-master-1")); d.Size != 50 {
+		t.Errorf("state disk %+v", d)
+	}
+}
+
+func TestRecreatedNodeKeepsNamedDiskAfterResize(t *testing.T) {
+	e := newTestEnv(t, namedDiskConfig)
+	e.reconcile()
+	old, _ := dataDisk(e.node("test-master-1"))
+
+	os.WriteFile(e.config, []byte(strings.Replace(namedDiskConfig, "size: 50", "size: 80", 1)), 0644)
+	e.fake.DeleteServerOutOfBand("test-master-1")
+	p := e.plan()
+	if len(p.Create) != 1 {
+		t.Fatalf("want master-1 recreated, got %+v", p.Create)
+	}
+	var reattached bool
+	for _, d := range p.Create[0].NewDisks {
+		reattached = reattached || (d.Action == "reattach" && d.VolumeID == old.ID)
+	}
+	if !reattached {
+		t.Fatalf("old data volume not reattached: %+v", p.Create[0].NewDisks)
+	}
+
+	e.reconcile()
+	e.reconcile()
+	d, _ := dataDisk(e.node("test-master-1"))
+	if d.ID != old.ID || d.Size != 80 || d.MountPoint != "/data" {
+		t.Errorf("disk after recreate and grow: %+v", d)
+	}
+}
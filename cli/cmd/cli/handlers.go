@@ -2,8 +2,11 @@ package main
 
 import (
 	"bufio"
+	"context"
+	"errors"
 	"fmt"
 	"os"
+	"sort"
 	"strings"
 	"time"
 
@@ -11,50 +14,44 @@ import (
 	"cli/internal/state"
 )
 
-func handleMarkDiskCritical(backend *state.Backend, clusterName, diskID string) {
+func handleMarkDiskCritical(backend state.StateStore, clusterName, diskID string) {
 	fmt.Printf("[SEARCH] Searching for disk %s in state '%s'...\n", diskID, clusterName)
 
-	st, err := backend.LoadState()
-	if err != nil || st == nil {
-		fmt.Printf("[ERROR] State loading error: %v\n", err)
-		return
-	}
-
-	found := false
-	for i := range st.Nodes {
-		for j := range st.Nodes[i].Disks {
-			if st.Nodes[i].Disks[j].ID == diskID {
-				st.Nodes[i].Disks[j].Critical = true
-				st.Nodes[i].Disks[j].Updated = time.Now().Format(time.RFC3339)
-				fmt.Printf("   [LOCK] Disk %s (Node: %s) marked as CRITICAL (protected from deletion).\n", diskID, st.Nodes[i].Name)
-				found = true
-				break
+	errDiskNotFound := errors.New("disk not found in state")
+	err := backend.UpdateState(func(st *state.ClusterState) error {
+		for i := range st.Nodes {
+			for j := range st.Nodes[i].Disks {
+				if st.Nodes[i].Disks[j].ID == diskID {
+					st.Nodes[i].Disks[j].Critical = true
+					st.Nodes[i].Disks[j].Updated = time.Now().Format(time.RFC3339)
+					st.LastUpdated = time.Now()
+					fmt.Printf("   [LOCK] Disk %s (Node: %s) marked as CRITICAL (protected from deletion).\n", diskID, st.Nodes[i].Name)
+					return nil
+				}
 			}
 		}
-		if found {
-			break
-		}
-	}
+		return errDiskNotFound
+	})
 
-	if !found {
+	if errors.Is(err, errDiskNotFound) {
 		fmt.Println("[ERROR] Disk not found in state.")
-		return
-	}
-
-	st.LastUpdated = time.Now()
-	if err := backend.SaveState(*st); err != nil {
+	} else if err != nil {
 		fmt.Printf("[ERROR] Error saving state: %v\n", err)
 	} else {
 		fmt.Println("[SAVE] State successfully updated.")
 	}
 }
 
-func handleCleanDisks(client *clo.Client, s3Backend *state.Backend, clusterName string) {
+func handleCleanDisks(ctx context.Context, client *clo.Client, s3Backend state.StateStore, clusterName string) {
 	criticalDisks := make(map[string]bool)
 	if s3Backend != nil {
 		fmt.Printf("[REFRESH] Loading state '%s' to check disk protection...\n", clusterName)
 		st, err := s3Backend.LoadState()
-		if err == nil && st != nil {
+		if err != nil {
+			fmt.Printf("[ERROR] State load error, cannot verify disk protection: %v\n", err)
+			return
+		}
+		if st != nil {
 			for _, n := range st.Nodes {
 				for _, d := range n.Disks {
 					if d.Critical {
@@ -62,15 +59,13 @@ func handleCleanDisks(client *clo.Client, s3Backend *state.Backend, clusterName
 					}
 				}
 			}
-		} else {
-			fmt.Printf("[WARNING] State not found or loading error (%v). Disk protection might not work!\n", err)
 		}
 	} else {
-		fmt.Println("[WARNING] S3 Backend not connected. CRITICAL disk protection IS NOT WORKING.")
+		fmt.Println("[WARNING] State backend not connected. CRITICAL disk protection IS NOT WORKING.")
 	}
 
 	fmt.Println("[SEARCH] Getting Volumes list...")
-	list, err := client.GetProjectVolumes()
+	list, err := client.GetProjectVolumes(ctx)
 	if err != nil {
 		fmt.Printf("Error getting volumes list: %v\n", err)
 		return
@@ -110,7 +105,11 @@ func handleCleanDisks(client *clo.Client, s3Backend *state.Backend, clusterName
 			return
 		}
 		fmt.Printf("Deleting volume %s (%s)... ", name, id)
-		if err := client.DeleteVolume(id); err != nil {
+		if err := client.DeleteVolume(ctx, id); clo.IsNotFound(err) {
+			fmt.Printf("[STAR] Already deleted.\n")
+		} else if clo.IsConflict(err) {
+			fmt.Printf("[WARNING] Volume is busy (attached or in progress): %v\n", err)
+		} else if err != nil {
 			fmt.Printf("[ERROR] Error: %v\n", err)
 		} else {
 			fmt.Printf("[+OK+] Success.\n")
@@ -137,22 +136,54 @@ func handleCleanDisks(client *clo.Client, s3Backend *state.Backend, clusterName
 	}
 }
 
-func handleCreateLB(client *clo.Client, s3Backend *state.Backend, clusterName string, configPath string) {
+func handleCreateLB(ctx context.Context, client *clo.Client, s3Backend state.StateStore, clusterName string, configPath string) {
 	fmt.Printf("[REFRESH] [LB Mode] Loading State '%s'...\n", clusterName)
 
+	cfg, err := GetClusterConfig(configPath)
+	if err != nil {
+		fmt.Printf("[ERROR] Configuration error: %v\n", err)
+		return
+	}
+	reconcileLB(ctx, client, s3Backend, clusterName, cfg)
+}
+
+// findLB returns the load balancer with this name, nil if there is none
+func findLB(ctx context.Context, client *clo.Client, lbName string) (*clo.LoadBalancerDetail, error) {
+	lbs, err := client.GetLoadBalancers(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("load balancer list: %w", err)
+	}
+	for _, lb := range lbs.Result {
+		if lb.Name == lbName {
+			return &lb, nil
+		}
+	}
+	return nil, nil
+}
+
+// reconcileLB makes the unified load balancer match the lb_rules of all groups and the live node addresses.
+// A missing LB is created; an existing one gets its settings and targets updated in place.
+func reconcileLB(ctx context.Context, client *clo.Client, s3Backend state.StateStore, clusterName string, cfg *Config) {
 	st, err := s3Backend.LoadState()
 	if err != nil || st == nil {
 		fmt.Println("[ERROR] State not found or loading error.")
 		return
 	}
 
-	cfg, err := GetClusterConfig(configPath)
+	lbName := fmt.Sprintf("%s-main-lb", clusterName)
+	existing, err := findLB(ctx, client, lbName)
 	if err != nil {
-		fmt.Printf("[ERROR] Configuration error: %v\n", err)
+		fmt.Printf("[ERROR] %v\n", err)
 		return
 	}
+	if existing == nil {
+		if err := checkLBNameFree(ctx, client, s3Backend, clusterName, lbName); err != nil {
+			fmt.Printf("[ERROR] %v\n", err)
+			return
+		}
+	}
 
-	allAddrs, err := client.GetProjectAddressesMap()
+	allAddrs, err := client.GetProjectAddressesMap(ctx)
 	if err != nil {
 		fmt.Printf("[ERROR] Addresses API error: %v\n", err)
 		return
@@ -164,124 +195,150 @@ func handleCreateLB(client *clo.Client, s3Backend *state.Backend, clusterName st
 	fmt.Println("[GEAR] Aggregating LB rules from all groups...")
 
 	for _, group := range cfg.Groups {
-		if len(group.LBRules) == 0 {
+		if len(group.lbRules()) == 0 {
 			continue
 		}
 		groupsWithLB++
 
-		var targetAddressIDs []string
-		prefix := fmt.Sprintf("%s-%s-", clusterName, group.NamePrefix)
-
+		// Each node is a target of the rules of its instance: the group rules unless it has its own
+		targets := make(map[LBRuleConfig][]string)
 		for _, n := range st.Nodes {
-			if strings.HasPrefix(n.Name, prefix) {
-				detail, err := client.GetServerDetail(n.ID)
-				if err != nil {
-					fmt.Printf("   [WARNING] Error getting details for %s: %v\n", n.Name, err)
-					continue
-				}
-				for _, addrID := range detail.Result.Addresses {
-					if info, ok := allAddrs[addrID]; ok && !info.External {
-						targetAddressIDs = append(targetAddressIDs, addrID)
-						break
+			idx, ok := nodeIndex(clusterName, group.NamePrefix, n.Name)
+			if !ok {
+				continue
+			}
+			nodeGroup := group.ForInstance(idx)
+			if len(nodeGroup.LBRules) == 0 {
+				continue
+			}
+			detail, err := client.GetServerDetail(ctx, n.ID)
+			if err != nil {
+				fmt.Printf("   [WARNING] Error getting details for %s: %v\n", n.Name, err)
+				continue
+			}
+			for _, addrID := range detail.Result.Addresses {
+				if info, ok := allAddrs[addrID]; ok && !info.External {
+					for _, rule := range nodeGroup.LBRules {
+						targets[rule] = append(targets[rule], addrID)
 					}
+					break
 				}
 			}
 		}
 
-		if len(targetAddressIDs) == 0 {
+		if len(targets) == 0 {
 			fmt.Printf("   [WARNING] Group '%s' has LB rules defined but no active nodes found. Skipping rules.\n", group.NamePrefix)
 			continue
 		}
 
-		for _, rule := range group.LBRules {
-			for _, addrID := range targetAddressIDs {
+		for _, rule := range group.lbRules() {
+			if len(targets[rule]) == 0 {
+				continue
+			}
+			for _, addrID := range targets[rule] {
 				aggregatedRules = append(aggregatedRules, clo.LBRule{
 					ExtPort: rule.ExtPort,
 					IntPort: rule.IntPort,
 					AddrID:  addrID,
 				})
 			}
-			fmt.Printf("   + Rule Group '%s': :%d -> :%d (Targets: %d)\n", group.NamePrefix, rule.ExtPort, rule.IntPort, len(targetAddressIDs))
+			fmt.Printf("   + Rule Group '%s': :%d -> :%d (Targets: %d)\n", group.NamePrefix, rule.ExtPort, rule.IntPort, len(targets[rule]))
 		}
 	}
 
 	if len(aggregatedRules) == 0 {
-		fmt.Println("[STAR] No LB rules found in config or no active nodes. Nothing to create.")
+		// An existing LB is left as it is: without targets there is nothing sensible to point it at
+		fmt.Println("[STAR] No LB rules found in config or no active nodes. Nothing to do.")
 		return
 	}
 
 	fmt.Println("---------------------------------------------------")
 
-	var lbAddressSettings clo.LBAddressSettings
-
-	// --- FIX: SEARCH FOR SPECIFIC IP ---
-	if cfg.LoadBalancerIP != "" {
-		fmt.Printf("[SEARCH] Looking for specific IP: %s...\n", cfg.LoadBalancerIP)
-		foundSpecific := false
-		for id, addr := range allAddrs {
-			if addr.Address == cfg.LoadBalancerIP {
-				fmt.Printf("   [RECYCLE] Found requested IP. ID: %s (Status: %s)\n", id, addr.Status)
-				lbAddressSettings.ID = id
-				foundSpecific = true
-				break
+	algorithm, monitor := cfg.LBSettings()
+	var lbAddressID string
+	if existing != nil {
+		lbAddressID = updateLB(ctx, client, existing.ID, lbName, algorithm, monitor, aggregatedRules, allAddrs)
+		if lbAddressID == "" {
+			return
+		}
+	} else {
+		var lbAddressSettings clo.LBAddressSettings
+
+		// --- FIX: SEARCH FOR SPECIFIC IP ---
+		if cfg.LoadBalancerIP != "" {
+			fmt.Printf("[SEARCH] Looking for specific IP: %s...\n", cfg.LoadBalancerIP)
+			foundSpecific := false
+			for id, addr := range allAddrs {
+				if addr.Address == cfg.LoadBalancerIP {
+					fmt.Printf("   [RECYCLE] Found requested IP. ID: %s (Status: %s)\n", id, addr.Status)
+					lbAddressSettings.ID = id
+					foundSpecific = true
+					break
+				}
+			}
+
+			if !foundSpecific {
+				fmt.Printf("[ERROR] Requested IP %s NOT FOUND in project addresses!\n", cfg.LoadBalancerIP)
+				fmt.Println("   Please check the IP or remove 'load_balancer_ip' from config to auto-allocate.")
+				return
 			}
+		} else {
+			fmt.Println("[SEARCH] Searching for ANY free external IP...")
+			freeIP, err := client.FindAvailableExternalIP(ctx)
+			if err != nil {
+				fmt.Printf("   [WARNING] Search error: %v. New IP will be created.\n", err)
+				ddos := false
+				lbAddressSettings.DDOSProtection = &ddos
+			} else if freeIP != "" {
+				fmt.Printf("   [RECYCLE] Found FREE address ID: %s.\n", freeIP)
+				lbAddressSettings.ID = freeIP
+			} else {
+				fmt.Println("   [STAR] NO free addresses. A NEW IP will be created.")
+				ddos := false
+				lbAddressSettings.DDOSProtection = &ddos
+			}
+		}
+		// -----------------------------------
+
+		req := clo.CreateLBRequest{
+			Name:               lbName,
+			Algorithm:          algorithm,
+			Address:            lbAddressSettings,
+			HealthMonitor:      monitor,
+			SessionPersistence: false,
+			Rules:              aggregatedRules,
 		}
 
-		if !foundSpecific {
-			fmt.Printf("[ERROR] Requested IP %s NOT FOUND in project addresses!\n", cfg.LoadBalancerIP)
-			fmt.Println("   Please check the IP or remove 'load_balancer_ip' from config to auto-allocate.")
+		fmt.Printf("[GO] Creating Unified Load Balancer '%s' with %d rules...\n", lbName, len(aggregatedRules))
+		lbID, err := client.CreateLoadBalancer(ctx, req)
+		if clo.IsQuota(err) {
+			fmt.Printf("[ERROR] Load balancer quota exhausted: %v\n", err)
+			return
+		} else if err != nil {
+			fmt.Printf("[ERROR] Failed to create LB: %v\n", err)
 			return
-		}
-	} else {
-		fmt.Println("[SEARCH] Searching for ANY free external IP...")
-		freeIP, err := client.FindAvailableExternalIP()
-		if err != nil {
-			fmt.Printf("   [WARNING] Search error: %v. New IP will be created.\n", err)
-			ddos := false
-			lbAddressSettings.DDOSProtection = &ddos
-		} else if freeIP != "" {
-			fmt.Printf("   [RECYCLE] Found FREE address ID: %s.\n", freeIP)
-			lbAddressSettings.ID = freeIP
 		} else {
-			fmt.Println("   [STAR] NO free addresses. A NEW IP will be created.")
-			ddos := false
-			lbAddressSettings.DDOSProtection = &ddos
+			fmt.Printf("[+OK+] Unified Load Balancer created! ID: %s\n", lbID)
 		}
-	}
-	// -----------------------------------
 
-	lbName := fmt.Sprintf("%s-main-lb", clusterName)
-	req := clo.CreateLBRequest{
-		Name:      lbName,
-		Algorithm: "ROUND_ROBIN",
-		Address:   lbAddressSettings,
-		HealthMonitor: clo.LBHealthMonitor{
-			Delay:      80,
-			MaxRetries: 5,
-			Timeout:    15,
-			Type:       "TCP",
-		},
-		SessionPersistence: false,
-		Rules:              aggregatedRules,
-	}
-
-	fmt.Printf("[GO] Creating Unified Load Balancer '%s' with %d rules...\n", lbName, len(aggregatedRules))
-	lbID, err := client.CreateLoadBalancer(req)
-	if err != nil {
-		fmt.Printf("[ERROR] Failed to create LB: %v\n", err)
-		return
-	} else {
-		fmt.Printf("[+OK+] Unified Load Balancer created! ID: %s\n", lbID)
+		lbAddressID = lbAddressSettings.ID
+		if lbAddressID == "" {
+			// A new IP was allocated: only the LB itself knows which one
+			if detail, err := client.GetLoadBalancerDetail(ctx, lbID); err == nil {
+				lbAddressID = detail.AddressID
+			}
+			if addrs, err := client.GetProjectAddressesMap(ctx); err == nil {
+				allAddrs = addrs
+			}
+		}
 	}
 
 	// --- UPDATE STATE LOGIC ---
 	fmt.Println("[SYNC] Updating State with Load Balancer IP and Ports...")
 
 	lbIP := ""
-	if lbAddressSettings.ID != "" {
-		if info, ok := allAddrs[lbAddressSettings.ID]; ok {
-			lbIP = info.Address
-		}
+	if info, ok := allAddrs[lbAddressID]; ok {
+		lbIP = info.Address
 	} else if cfg.LoadBalancerIP != "" {
 		lbIP = cfg.LoadBalancerIP
 	}
@@ -289,35 +346,30 @@ func handleCreateLB(client *clo.Client, s3Backend *state.Backend, clusterName st
 	if lbIP != "" {
 		stateModified := false
 
-		for _, group := range cfg.Groups {
-			if len(group.LBRules) == 0 {
+		for i := range st.Nodes {
+			node := &st.Nodes[i]
+			nodeGroup, ok := cfg.GroupOf(clusterName, node.Name)
+			if !ok || len(nodeGroup.LBRules) == 0 {
 				continue
 			}
-			// -------------------------------
 
 			var sshExtPort int
-			for _, r := range group.LBRules {
+			for _, r := range nodeGroup.LBRules {
 				if r.IntPort == 22 {
 					sshExtPort = r.ExtPort
 					break
 				}
 			}
 
-			prefix := fmt.Sprintf("%s-%s-", clusterName, group.NamePrefix)
-			for i := range st.Nodes {
-				node := &st.Nodes[i]
-				if strings.HasPrefix(node.Name, prefix) {
-					if node.IP != lbIP {
-						fmt.Printf("   [UPDATE] Node %s: IP %s -> %s (LB)\n", node.Name, node.IP, lbIP)
-						node.IP = lbIP
-						stateModified = true
-					}
-					if sshExtPort != 0 && node.SSHPort != sshExtPort {
-						fmt.Printf("   [UPDATE] Node %s: SSH Port %d -> %d (LB)\n", node.Name, node.SSHPort, sshExtPort)
-						node.SSHPort = sshExtPort
-						stateModified = true
-					}
-				}
+			if node.IP != lbIP {
+				fmt.Printf("   [UPDATE] Node %s: IP %s -> %s (LB)\n", node.Name, node.IP, lbIP)
+				node.IP = lbIP
+				stateModified = true
+			}
+			if sshExtPort != 0 && node.SSHPort != sshExtPort {
+				fmt.Printf("   [UPDATE] Node %s: SSH Port %d -> %d (LB)\n", node.Name, node.SSHPort, sshExtPort)
+				node.SSHPort = sshExtPort
+				stateModified = true
 			}
 		}
 
@@ -335,9 +387,76 @@ func handleCreateLB(client *clo.Client, s3Backend *state.Backend, clusterName st
 		fmt.Println("[WARNING] Could not determine LB IP, skipping state update.")
 	}
 
-	fmt.Println("\n[TIME] IP address will appear in the control panel in a few seconds.")
+	if existing == nil {
+		fmt.Println("\n[TIME] IP address will appear in the control panel in a few seconds.")
+	}
 }
-func handleAttachDisks(client *clo.Client, s3Backend *state.Backend, clusterName string) {
+
+// updateLB brings an existing LB to the wanted settings and rules and returns its address ID ("" on error)
+func updateLB(ctx context.Context, client *clo.Client, lbID, lbName, algorithm string, monitor clo.LBHealthMonitor, rules []clo.LBRule, allAddrs map[string]clo.AddressDetail) string {
+	current, err := client.GetLoadBalancerDetail(ctx, lbID)
+	if err != nil {
+		fmt.Printf("[ERROR] Load balancer detail error: %v\n", err)
+		return ""
+	}
+	changes := append(diffLBRules(current.Rules, rules, allAddrs), lbSettingChanges(current, algorithm, monitor)...)
+	if len(changes) == 0 {
+		fmt.Printf("[STAR] Load balancer '%s' (ID: %s) is up to date.\n", lbName, lbID)
+		return current.AddressID
+	}
+
+	fmt.Printf("[GEAR] Updating load balancer '%s' (ID: %s):\n", lbName, lbID)
+	for _, c := range changes {
+		fmt.Printf("   %s\n", c)
+	}
+	err = client.UpdateLoadBalancer(ctx, lbID, clo.UpdateLBRequest{
+		Algorithm: algorithm, HealthMonitor: monitor, SessionPersistence: current.SessionPersistence, Rules: rules,
+	})
+	if err != nil {
+		fmt.Printf("[ERROR] Failed to update LB: %v\n", err)
+		return ""
+	}
+	fmt.Printf("[+OK+] Load balancer updated: %d rules.\n", len(rules))
+	return current.AddressID
+}
+
+// diffLBRules lists the targets to add (+) and remove (-) to go from have to want
+func diffLBRules(have, want []clo.LBRule, allAddrs map[string]clo.AddressDetail) []string {
+	describe := func(r clo.LBRule) string {
+		target := r.AddrID
+		if info, ok := allAddrs[r.AddrID]; ok {
+			target = info.Address
+		}
+		return fmt.Sprintf(":%d -> %s:%d", r.ExtPort, target, r.IntPort)
+	}
+	h := make(map[clo.LBRule]bool)
+	for _, r := range have {
+		h[r] = true
+	}
+	w := make(map[clo.LBRule]bool)
+	for _, r := range want {
+		w[r] = true
+	}
+	var out []string
+	for _, r := range want {
+		if !h[r] {
+			out = append(out, "+ target "+describe(r))
+		}
+	}
+	for _, r := range have {
+		if !w[r] {
+			out = append(out, "- target "+describe(r))
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// formatMonitor prints a health check as "TCP every 80s, timeout 15s, 5 retries"
+func formatMonitor(m clo.LBHealthMonitor) string {
+	return fmt.Sprintf("%s every %ds, timeout %ds, %d retries", m.Type, m.Delay, m.Timeout, m.MaxRetries)
+}
+func handleAttachDisks(ctx context.Context, client *clo.Client, s3Backend state.StateStore, clusterName string) {
 	fmt.Printf("[REFRESH] [Attach Mode] Loading State '%s'...\n", clusterName)
 	st, err := s3Backend.LoadState()
 	if err != nil || st == nil {
@@ -347,7 +466,7 @@ func handleAttachDisks(client *clo.Client, s3Backend *state.Backend, clusterName
 
 	fmt.Println("[SEARCH] Searching for detached disks in the cloud...")
 
-	allVolumes, err := client.GetProjectVolumes()
+	allVolumes, err := client.GetProjectVolumes(ctx)
 	if err != nil {
 		fmt.Printf("[ERROR] API error: %v\n", err)
 		return
@@ -378,8 +497,10 @@ func handleAttachDisks(client *clo.Client, s3Backend *state.Backend, clusterName
 				if volInfo.Status == "AVAILABLE" {
 					fmt.Printf("   [SAVE_DISK] [%s] Found detached disk %s (%dGb). Attaching to server %s...\n", node.Name, disk.ID, disk.Size, node.ID)
 
-					devicePath, err := client.AttachVolume(disk.ID, node.ID)
-					if err != nil {
+					devicePath, err := client.AttachVolume(ctx, disk.ID, node.ID)
+					if clo.IsConflict(err) {
+						fmt.Printf("      [WARNING] Disk is busy, was it just attached elsewhere? %v\n", err)
+					} else if err != nil {
 						fmt.Printf("      [ERROR] Attachment error: %v\n", err)
 					} else {
 						fmt.Printf("      [+OK+] Success! Device: %s\n", devicePath)
@@ -410,7 +531,7 @@ func handleAttachDisks(client *clo.Client, s3Backend *state.Backend, clusterName
 	} else {
 		if attachedCount > 0 {
 			fmt.Println("[TIME] Waiting for operations to complete...")
-			time.Sleep(5 * time.Second)
+			time.Sleep(attachSettleTime / 3)
 		}
 		if stateChanged {
 			fmt.Println("[SAVE] Saving updated state (Device paths)...")
@@ -423,32 +544,42 @@ func handleAttachDisks(client *clo.Client, s3Backend *state.Backend, clusterName
 	}
 }
 
-func handleCreate(client *clo.Client, nameSuffix string) {
+func handleCreate(ctx context.Context, client *clo.Client, nameSuffix string) {
 	fmt.Printf("Launching single server creation with suffix: %s...\n", nameSuffix)
-	payload := map[string]interface{}{"name": "test-server-" + nameSuffix, "flavor": map[string]interface{}{"ram": 2, "vcpus": 1, "cpu_type": "SHARED"}, "storages": []map[string]interface{}{{"bootable": true, "size": 10, "storage_type": "storage"}}, "addresses": []map[string]interface{}{{"ddos_protection": false, "external": true, "version": 4, "bandwidth_max_mbps": 1024}}, "image": "45705da5-3b52-4e75-a0c6-7d4cd0911830", "keypairs": []string{"dd6b1251-ceeb-4fba-afbc-aa34cc4a6990", "92fb9ff8-9396-455f-9aed-a6482fc54ce6"}}
-	serverID, err := client.CreateServer(payload)
-	if err != nil {
+	req := clo.CreateServerRequest{
+		Name:      "test-server-" + nameSuffix,
+		Flavor:    clo.ServerFlavor{RAM: 2, VCPUs: 1, CPUType: clo.CPUShared},
+		Storages:  []clo.ServerStorage{{Bootable: true, Size: 10, StorageType: "storage"}},
+		Addresses: []clo.ServerAddress{clo.NewAddress(true)},
+		Image:     "45705da5-3b52-4e75-a0c6-7d4cd0911830",
+		Keypairs:  []string{"dd6b1251-ceeb-4fba-afbc-aa34cc4a6990", "92fb9ff8-9396-455f-9aed-a6482fc54ce6"},
+	}
+	serverID, err := client.CreateServer(ctx, req)
+	if clo.IsQuota(err) {
+		fmt.Printf("Creation error: project quota exhausted, free resources or raise the limit: %v\n", err)
+		return
+	} else if err != nil {
 		fmt.Printf("Creation error: %v\n", err)
 		return
 	}
 	fmt.Printf("Server created, ID: %s. Waiting for readiness...\n", serverID)
-	finalStatus, addrIDs, volIDs, err := client.WaitForStatus(serverID, []string{"ACTIVE", "RUNNING"}, 60, 5*time.Second)
+	finalStatus, addrIDs, volIDs, err := client.WaitForStatus(ctx, serverID, []string{"ACTIVE", "RUNNING"}, 60, 5*time.Second)
 	if err != nil {
 		fmt.Printf("Wait error (%v). Attempting to delete resources...\n", err)
 		cleanupPayload := clo.DeleteServerPayload{ClearFstab: true, DeleteVolumes: volIDs, DeleteAddresses: addrIDs}
-		if delErr := client.DeleteServer(serverID, cleanupPayload); delErr != nil {
+		if delErr := client.DeleteServer(ctx, serverID, cleanupPayload); delErr != nil {
 			fmt.Printf("Critical error: failed to delete server: %v\n", delErr)
 		}
 		return
 	}
 	fmt.Printf("Server is ready! Status: %s\n", finalStatus)
-	printIPDetails(client, addrIDs)
+	printIPDetails(ctx, client, addrIDs)
 	pass, err := clo.GenerateRandomPassword()
 	if err != nil {
 		fmt.Printf("Password generation error: %v\n", err)
 		return
 	}
-	err = client.SetServerPassword(serverID, pass)
+	err = client.SetServerPassword(ctx, serverID, pass)
 	if err != nil {
 		fmt.Printf("Password setting error: %v\n", err)
 	} else {
@@ -456,16 +587,18 @@ func handleCreate(client *clo.Client, nameSuffix string) {
 	}
 }
 
-func handleDelete(client *clo.Client, serverID string) {
+// handleDelete deletes a server with its boot volume and internal addresses; data disks and
+// external IPs are kept
+func handleDelete(ctx context.Context, client *clo.Client, serverID string) error {
 	fmt.Printf("Preparing to delete server %s...\n", serverID)
 
-	detail, err := client.GetServerDetail(serverID)
+	detail, err := client.GetServerDetail(ctx, serverID)
 	var volIDsToDelete, addrIDsToDelete []string
 
 	if err != nil {
 		fmt.Printf("Warning: failed to get server details (%v). Deleting VM only.\n", err)
 	} else {
-		allAddrs, addrErr := client.GetProjectAddressesMap()
+		allAddrs, addrErr := client.GetProjectAddressesMap(ctx)
 		if addrErr != nil {
 			fmt.Printf("Warning: failed to get IP addresses list (%v)\n", addrErr)
 		} else {
@@ -479,7 +612,7 @@ func handleDelete(client *clo.Client, serverID string) {
 		}
 
 		for _, s := range detail.Result.Storages {
-			volInfo, vErr := client.GetVolumeDetail(s.ID)
+			volInfo, vErr := client.GetVolumeDetail(ctx, s.ID)
 			if vErr == nil {
 				if volInfo.Bootable {
 					volIDsToDelete = append(volIDsToDelete, s.ID)
@@ -500,15 +633,19 @@ func handleDelete(client *clo.Client, serverID string) {
 		DeleteAddresses: addrIDsToDelete,
 	}
 
-	if err := client.DeleteServer(serverID, payload); err != nil {
+	if err := client.DeleteServer(ctx, serverID, payload); clo.IsNotFound(err) {
+		fmt.Printf("Server %s is already deleted.\n", serverID)
+	} else if err != nil {
 		fmt.Printf("Deletion error: %v\n", err)
+		return err
 	} else {
 		fmt.Printf("Server %s successfully deleted.\n", serverID)
 	}
+	return nil
 }
 
-func printIPDetails(client *clo.Client, addrIDs []string) {
-	allAddrs, err := client.GetProjectAddressesMap()
+func printIPDetails(ctx context.Context, client *clo.Client, addrIDs []string) {
+	allAddrs, err := client.GetProjectAddressesMap(ctx)
 	if err != nil {
 		return
 	}
@@ -520,7 +657,7 @@ func printIPDetails(client *clo.Client, addrIDs []string) {
 	}
 }
 
-func handleCleanAll(client *clo.Client) {
+func handleCleanAll(ctx context.Context, client *clo.Client) {
 	fmt.Println("[WARNING] ATTENTION! You are about to delete ALL servers, load balancers, and NON-EXTERNAL IP addresses in the project.")
 	fmt.Print("Are you sure? Enter 'yes' to confirm: ")
 
@@ -535,17 +672,17 @@ func handleCleanAll(client *clo.Client) {
 
 	// --- 1. Server Deletion ---
 	fmt.Println("\n[BOO] Deleting servers...")
-	list, err := client.GetServersList()
+	list, err := client.GetServersList(ctx)
 	if err != nil {
 		fmt.Printf("  [ERROR] Error getting server list: %v\n", err)
 	} else if list.Count == 0 {
 		fmt.Println("  [STAR] Servers not found.")
 	} else {
 		fmt.Printf("  Found servers: %d\n", list.Count)
-		allAddrs, _ := client.GetProjectAddressesMap()
+		allAddrs, _ := client.GetProjectAddressesMap(ctx)
 		for _, srv := range list.Result {
 			fmt.Printf("--- Deleting server [%s] (%s) ---\n", srv.Name, srv.ID)
-			detail, err := client.GetServerDetail(srv.ID)
+			detail, err := client.GetServerDetail(ctx, srv.ID)
 			var payload clo.DeleteServerPayload
 			if err != nil {
 				fmt.Printf("  [WARNING] Failed to get details: %v. Simple deletion.\n", err)
@@ -569,7 +706,9 @@ func handleCleanAll(client *clo.Client) {
 					DeleteAddresses: addrIDsToDelete,
 				}
 			}
-			if err := client.DeleteServer(srv.ID, payload); err != nil {
+			if err := client.DeleteServer(ctx, srv.ID, payload); clo.IsNotFound(err) {
+				fmt.Printf("  [STAR] Already deleted.\n")
+			} else if err != nil {
 				fmt.Printf("  [ERROR] Error deleting server: %v\n", err)
 			} else {
 				fmt.Printf("  [+OK+] Server sent for deletion.\n")
@@ -580,7 +719,7 @@ func handleCleanAll(client *clo.Client) {
 
 	// --- 2. Load Balancers Deletion (CORRECTED) ---
 	fmt.Println("\n[BOO] Deleting Load Balancers...")
-	lbList, err := client.GetLoadBalancers()
+	lbList, err := client.GetLoadBalancers(ctx)
 	if err != nil {
 		fmt.Printf("  [ERROR] Error getting load balancers list: %v\n", err)
 	} else if lbList.Count == 0 {
@@ -589,7 +728,9 @@ func handleCleanAll(client *clo.Client) {
 		fmt.Printf("  Found load balancers: %d\n", lbList.Count)
 		for _, lb := range lbList.Result {
 			fmt.Printf("   Deleting load balancer %s (ID: %s)... ", lb.Name, lb.ID)
-			if err := client.DeleteLoadBalancer(lb.ID); err != nil {
+			if err := client.DeleteLoadBalancer(ctx, lb.ID); clo.IsNotFound(err) {
+				fmt.Printf("[STAR] Already deleted.\n")
+			} else if err != nil {
 				fmt.Printf("[ERROR] Error: %v\n", err)
 			} else {
 				fmt.Printf("[+OK+] Deleted.\n")
@@ -600,7 +741,7 @@ func handleCleanAll(client *clo.Client) {
 
 	// --- 3. Cleanup remaining IP addresses ---
 	fmt.Println("\n[BROOM] Checking remaining IP addresses...")
-	addrs, err := client.GetProjectAddressesMap()
+	addrs, err := client.GetProjectAddressesMap(ctx)
 	if err != nil {
 		fmt.Printf("Error getting addresses list: %v\n", err)
 		return
@@ -612,7 +753,9 @@ func handleCleanAll(client *clo.Client) {
 			continue
 		}
 		fmt.Printf("Deleting address %s (%s)...\n", addr.Address, id)
-		if err := client.DeleteAddress(id); err != nil {
+		if err := client.DeleteAddress(ctx, id); clo.IsNotFound(err) {
+			fmt.Println("  [STAR] Already deleted.")
+		} else if err != nil {
 			fmt.Printf("  [WARNING] (Skip) Failed to delete: %v\n", err)
 		} else {
 			fmt.Println("  [+OK+] Deleted.")
@@ -9,6 +9,7 @@ import (
 	"golang.org/x/crypto/ssh"
 
 	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
@@ -487,3 +488,103 @@ func runCommandOutput(client *ssh.Client, cmd string) ([]byte, error) {
 	defer session.Close()
 	return session.Output(cmd)
 }
+
+// setNodeUnschedulable cordons (true) or uncordons (false) a node
+func setNodeUnschedulable(ctx context.Context, cs kubernetes.Interface, name string, unschedulable bool) error {
+	node, err := cs.CoreV1().Nodes().Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	if node.Spec.Unschedulable == unschedulable {
+		return nil
+	}
+	node.Spec.Unschedulable = unschedulable
+	_, err = cs.CoreV1().Nodes().Update(ctx, node, metav1.UpdateOptions{})
+	return err
+}
+
+// drainNode evicts all pods of a node except DaemonSet and static pods and waits until they are gone.
+// Evictions blocked by a PodDisruptionBudget are retried until timeout.
+func drainNode(ctx context.Context, cs kubernetes.Interface, name string, timeout, interval time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	for {
+		pods, err := cs.CoreV1().Pods("").List(ctx, metav1.ListOptions{FieldSelector: "spec.nodeName=" + name})
+		if err != nil {
+			return fmt.Errorf("list pods: %w", err)
+		}
+		var left []string
+		for _, pod := range pods.Items {
+			if !evictable(pod) {
+				continue
+			}
+			left = append(left, pod.Namespace+"/"+pod.Name)
+			if pod.DeletionTimestamp != nil {
+				continue // already terminating
+			}
+			eviction := &policyv1.Eviction{ObjectMeta: metav1.ObjectMeta{Name: pod.Name, Namespace: pod.Namespace}}
+			err := cs.PolicyV1().Evictions(pod.Namespace).Evict(ctx, eviction)
+			switch {
+			case err == nil, errors.IsNotFound(err):
+			case errors.IsTooManyRequests(err):
+				fmt.Printf("   [TIME] %s/%s: eviction blocked by a disruption budget, retrying...\n", pod.Namespace, pod.Name)
+			default:
+				return fmt.Errorf("evict %s/%s: %w", pod.Namespace, pod.Name, err)
+			}
+		}
+		if len(left) == 0 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("drain of %s timed out, pods left: %v", name, left)
+		case <-time.After(interval):
+		}
+	}
+}
+
+// evictable reports whether drain has to move the pod: not finished, not a static pod, not a DaemonSet pod
+func evictable(pod corev1.Pod) bool {
+	if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+		return false
+	}
+	if _, mirror := pod.Annotations[corev1.MirrorPodAnnotationKey]; mirror {
+		return false
+	}
+	for _, ref := range pod.OwnerReferences {
+		if ref.Kind == "DaemonSet" {
+			return false
+		}
+	}
+	return true
+}
+
+// nodeReady reports whether the node is Ready and schedulable
+func nodeReady(node *corev1.Node) bool {
+	if node.Spec.Unschedulable {
+		return false
+	}
+	for _, c := range node.Status.Conditions {
+		if c.Type == corev1.NodeReady {
+			return c.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// waitNodeReady waits until the node is registered, Ready and schedulable
+func waitNodeReady(ctx context.Context, cs kubernetes.Interface, name string, timeout, interval time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	for {
+		node, err := cs.CoreV1().Nodes().Get(ctx, name, metav1.GetOptions{})
+		if err == nil && nodeReady(node) {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("node %s is not Ready after %v", name, timeout)
+		case <-time.After(interval):
+		}
+	}
+}
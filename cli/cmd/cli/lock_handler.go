@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"cli/internal/state"
+)
+
+const (
+	stateLockTTL           = 10 * time.Minute
+	stateLockRenewInterval = 3 * time.Minute
+)
+
+// lockState takes the state lock for a mutating command, renews it in the background
+// and returns a function that releases it. It fails if the lock is held by someone else.
+func lockState(backend state.StateStore) (func(), error) {
+	info := state.NewLockInfo(strings.Join(os.Args, " "), stateLockTTL)
+	if err := backend.AcquireLock(info); err != nil {
+		var locked *state.LockedError
+		if errors.As(err, &locked) {
+			return nil, fmt.Errorf("%w\n   Wait for the other run to finish or clear a stuck lock with -force-unlock <ID>", err)
+		}
+		return nil, fmt.Errorf("state lock error: %w", err)
+	}
+	fmt.Printf("[LOCK] State lock acquired (ID: %s)\n", info.ID)
+
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(stateLockRenewInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := backend.RenewLock(info, stateLockTTL); err != nil {
+					fmt.Printf("[WARNING] State lock renew failed: %v\n", err)
+				}
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(done)
+			if err := backend.ReleaseLock(info); err != nil {
+				fmt.Printf("[WARNING] State lock release failed: %v\n", err)
+			} else {
+				fmt.Println("[LOCK] State lock released.")
+			}
+		})
+	}, nil
+}
+
+// handleLockInfo prints the current state lock
+func handleLockInfo(backend state.StateStore, jsonOutput bool) {
+	info, err := backend.GetLockInfo()
+	if err != nil {
+		fmt.Printf("[ERROR] Lock read error: %v\n", err)
+		os.Exit(1)
+	}
+	if info == nil {
+		fmt.Println("[UNLOCK] State is not locked.")
+		return
+	}
+	if jsonOutput {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(info)
+		return
+	}
+	status := "ACTIVE"
+	if info.Expired() {
+		status = "EXPIRED"
+	}
+	fmt.Printf("[LOCK] Lock ID:  %s (%s)\n", info.ID, status)
+	fmt.Printf("   Owner:    %s\n", info.Owner)
+	fmt.Printf("   Host:     %s\n", info.Host)
+	fmt.Printf("   Command:  %s\n", info.Command)
+	fmt.Printf("   Created:  %s\n", info.Created.Format(time.RFC1123))
+	fmt.Printf("   Expires:  %s\n", info.Expires.Format(time.RFC1123))
+}
+
+// handleForceUnlock removes a stuck lock after confirmation
+func handleForceUnlock(backend state.StateStore, lockID string) {
+	info, err := backend.GetLockInfo()
+	if err != nil {
+		fmt.Printf("[ERROR] Lock read error: %v\n", err)
+		os.Exit(1)
+	}
+	if info == nil {
+		fmt.Println("[UNLOCK] State is not locked.")
+		return
+	}
+	fmt.Printf("[LOCK] Lock %s is held by %s@%s (command: %s)\n", info.ID, info.Owner, info.Host, info.Command)
+	if !askForConfirmation("[WARNING] Force unlock? The other run may still be writing state") {
+		fmt.Println("Cancellation.")
+		return
+	}
+	if err := backend.ForceUnlock(lockID); err != nil {
+		fmt.Printf("[ERROR] Force unlock failed: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Println("[UNLOCK] Lock removed.")
+}
@@ -1,10 +1,14 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"os"
+	"os/signal"
 	"strings"
+	"syscall"
+	"time"
 
 	"cli/internal/clo"
 	"cli/internal/local"
@@ -36,6 +40,7 @@ var (
 	syncState       bool
 	enableLog       bool
 	mountDisks      bool
+	growFS          bool
 	deleteNodes     bool
 	attachDisks     bool
 	createLB        bool
@@ -62,6 +67,41 @@ var (
 	createBackup     bool
 	statusRestoreDB  bool
 	criticalDiskID   string
+	lockInfo         bool
+	forceUnlockID    string
+	stateHistory     bool
+	stateShowRev     string
+	stateRollbackRev string
+	historyKeep      int
+	stateURL         string
+	ageRecipients    string
+	stateRewrap      bool
+	listClusters     bool
+	driftCheck       bool
+	planMode         bool
+	planOut          string
+	applyPlanFile    string
+	stateExportFile  string
+	stateImportFile  string
+	stateMigrate     bool
+	migrateTo        string
+	adoptProject     bool
+	apiRetries       int
+	apiTimeout       time.Duration
+	apiURL           string
+	listOSImages     bool
+	listKeypairs     bool
+	createKeypair    string
+	publicKeyPath    string
+	snapshotDisks    bool
+	criticalOnly     bool
+	snapshotKeep     int
+	listSnapshots    bool
+	apiRateRead      float64
+	apiRateWrite     float64
+	apiRatePoll      float64
+	rollGroupName    string
+	maxUnavailable   int
 )
 
 func init() {
@@ -76,12 +116,15 @@ func init() {
 
 	flag.BoolVar(&deployKubespray, "deploy", false, "Deploy Kubespray")
 	flag.BoolVar(&mountDisks, "mount", false, "Mount disks only")
+	flag.BoolVar(&growFS, "grow-fs", false, "Grow the ext4 filesystems of data disks to their volume size, online")
 
 	flag.BoolVar(&setPermissions, "permissions", false, "Apply disk ownership/permissions from config recursively")
 
 	flag.IntVar(&ansibleForks, "f", 5, "Ansible forks")
 	flag.StringVar(&ansibleLimit, "l", "", "Limit Ansible hosts")
 	flag.StringVar(&removeK8sNode, "remove-k8s-node", "", "Gracefully remove node from K8s cluster (runs remove-node.yml)")
+	flag.StringVar(&rollGroupName, "roll", "", "Replace the nodes of this group one by one: drain, remove-node, recreate with the current config and data disks, scale, wait Ready")
+	flag.IntVar(&maxUnavailable, "max-unavailable", 1, "With -roll: nodes of the group that may be unavailable at once, the replaced one included")
 
 	flag.BoolVar(&fluxMode, "flux", false, "Run Flux Bootstrap")
 	flag.BoolVar(&syncState, "sync", false, "Synchronize state")
@@ -117,6 +160,39 @@ func init() {
 	flag.BoolVar(&createBackup, "create-backup", false, "Trigger manual Percona PG Backup with timestamp")
 	flag.BoolVar(&statusRestoreDB, "statusrestoredb", false, "Check if Percona PG Cluster restore is complete")
 	flag.StringVar(&criticalDiskID, "critical-disk", "", "Mark disk ID as critical (protected from deletion)")
+	flag.BoolVar(&lockInfo, "lock-info", false, "Show the current state lock")
+	flag.StringVar(&forceUnlockID, "force-unlock", "", "Remove a stuck state lock by its ID (see -lock-info)")
+	flag.BoolVar(&stateHistory, "state-history", false, "List stored state revisions")
+	flag.StringVar(&stateShowRev, "state-show", "", "Show a stored state revision")
+	flag.StringVar(&stateRollbackRev, "state-rollback", "", "Restore a stored state revision (recorded as a new revision)")
+	flag.StringVar(&stateURL, "state-url", "", "State backend: s3://bucket/prefix, file:///path or mem:// (default: $STATE_URL, then s3://$S3_BUCKET)")
+	flag.IntVar(&historyKeep, "history-keep", state.DefaultHistoryLimit, "Number of state revisions to keep (-1 disables history)")
+	flag.StringVar(&ageRecipients, "age-recipients", os.Getenv("STATE_AGE_RECIPIENTS"), "Encrypt state to these age public keys, comma separated (default: $STATE_AGE_RECIPIENTS)")
+	flag.BoolVar(&planMode, "plan", false, "Show what -cluster would change, without changing anything")
+	flag.StringVar(&planOut, "plan-out", "", "Save the -plan result to this file")
+	flag.StringVar(&applyPlanFile, "apply", "", "Execute a plan saved with -plan -plan-out (fails if the state changed since)")
+	flag.StringVar(&stateExportFile, "state-export", "", "Export the current state to a plaintext JSON file")
+	flag.StringVar(&stateImportFile, "state-import", "", "Replace the current state with an exported JSON file")
+	flag.BoolVar(&stateMigrate, "state-migrate", false, "Copy state and history to the backend given by -to")
+	flag.StringVar(&migrateTo, "to", "", "Destination for -state-migrate: endpoint/bucket[/prefix] or a state URL")
+	flag.BoolVar(&adoptProject, "adopt-project", false, "Build the state from existing servers named <cluster>-<prefix>-<n>")
+	flag.IntVar(&apiRetries, "api-retries", clo.DefaultRetryPolicy().MaxAttempts, "Max attempts per CLO API call")
+	flag.StringVar(&apiURL, "api-url", os.Getenv("CLO_API_URL"), "CLO API base URL, e.g. a local fake for tests (default: $CLO_API_URL or the public API)")
+	flag.BoolVar(&listOSImages, "list-os-images", false, "List OS images available to the CLO project")
+	flag.BoolVar(&listKeypairs, "list-keypairs", false, "List SSH keypairs of the project")
+	flag.StringVar(&createKeypair, "create-keypair", "", "Upload the public key from -public-key as a keypair with this name")
+	flag.StringVar(&publicKeyPath, "public-key", "${HOME}/.ssh/clo.pub", "Public key file for -create-keypair")
+	flag.BoolVar(&snapshotDisks, "snapshot-disks", false, "Snapshot the data disks of all nodes in the state (schedule it from cron/CI for backups)")
+	flag.BoolVar(&criticalOnly, "critical-only", false, "With -snapshot-disks: only disks marked with -critical-disk")
+	flag.IntVar(&snapshotKeep, "snapshot-keep", 7, "With -snapshot-disks: snapshots to keep per disk, older ones are deleted (0 keeps all)")
+	flag.BoolVar(&listSnapshots, "list-snapshots", false, "List disk snapshots of the cluster")
+	flag.Float64Var(&apiRateRead, "api-rate-read", clo.DefaultRateLimits().Read.PerSecond, "CLO API reads per second shared by all goroutines (0 = unlimited)")
+	flag.Float64Var(&apiRateWrite, "api-rate-write", clo.DefaultRateLimits().Write.PerSecond, "CLO API writes (create, attach, delete) per second (0 = unlimited)")
+	flag.Float64Var(&apiRatePoll, "api-rate-poll", clo.DefaultRateLimits().Poll.PerSecond, "CLO API status polls per second while waiting for servers and volumes (0 = unlimited)")
+	flag.DurationVar(&apiTimeout, "api-timeout", clo.DefaultRetryPolicy().CallTimeout, "Deadline of one CLO API call including retries (0 = none)")
+	flag.BoolVar(&driftCheck, "drift", false, "Report drift between config, state and the cloud (read-only, exit code 2 on drift)")
+	flag.BoolVar(&listClusters, "list-clusters", false, "List all clusters in the state backend")
+	flag.BoolVar(&stateRewrap, "state-rewrap", false, "Re-encrypt state and history to the current -age-recipients")
 }
 
 func main() {
@@ -142,28 +218,49 @@ func main() {
 		return
 	}
 
-	var s3Backend *state.Backend
+	var backend *state.Backend
+	var stateStore state.StateStore // stays a nil interface without a backend, handlers check for that
 	s3Endpoint := os.Getenv("S3_ENDPOINT")
 	s3Access := os.Getenv("S3_ACCESS_KEY")
 	s3Secret := os.Getenv("S3_SECRET_KEY")
 	s3Bucket := os.Getenv("S3_BUCKET")
-	s3Key := fmt.Sprintf("clusters/%s/state.json", clusterName)
 
-	if s3Endpoint != "" && s3Bucket != "" {
+	if stateURL == "" {
+		stateURL = os.Getenv("STATE_URL")
+	}
+	if stateURL == "" && s3Endpoint != "" && s3Bucket != "" {
+		stateURL = "s3://" + s3Bucket
+	}
+
+	// Commands that cannot run without a state backend; -cluster and -clean-disks use one when configured
+	stateRequired := resetPass || deployKubespray || checkState || fluxMode || syncState || mountDisks || growFS || removeK8sNode != "" || attachDisks || createLB || kvSecStr != "" || waitCertStr != "" || addUserStr != "" || osUpd || cmCreateStr != "" || createBackup || statusRestoreDB || criticalDiskID != "" || setPermissions || lockInfo || forceUnlockID != "" || delNodePtr != "" || stateHistory || stateShowRev != "" || stateRollbackRev != "" || stateRewrap || listClusters || driftCheck || planMode || applyPlanFile != "" || stateExportFile != "" || stateImportFile != "" || stateMigrate || adoptProject || snapshotDisks || rollGroupName != ""
+	if stateURL != "" {
 		var err error
-		s3Backend, err = state.NewBackend(s3Endpoint, s3Access, s3Secret, s3Bucket, s3Key, true)
-		if err != nil {
-			fmt.Printf("[WARNING] S3 Init: %v\n", err)
-		}
-	} else {
-		if resetPass || deployKubespray || checkState || fluxMode || syncState || mountDisks || removeK8sNode != "" || attachDisks || createLB || k8sImagesBundle || listImages || kvSecStr != "" || waitCertStr != "" || addUserStr != "" || osUpd || cmCreateStr != "" || createBackup || statusRestoreDB || criticalDiskID != "" || setPermissions {
-			fmt.Println("[ERROR] Error: S3 required.")
+		backend, err = state.Open(stateURL, clusterName, state.S3Options{Endpoint: s3Endpoint, AccessKey: s3Access, SecretKey: s3Secret, UseSSL: true})
+		if err != nil && (stateRequired || createCluster || cleanDisks) {
+			fmt.Printf("[ERROR] State backend init: %v\n", err)
 			os.Exit(1)
 		}
+		if err != nil {
+			fmt.Printf("[WARNING] State backend init: %v\n", err)
+		} else {
+			backend.HistoryLimit = historyKeep
+			if backend.Recipients, err = state.ParseRecipients(ageRecipients); err != nil {
+				fmt.Printf("[ERROR] Invalid age recipients: %v\n", err)
+				os.Exit(1)
+			}
+			if backend.Identities, err = state.ParseIdentities(os.Getenv("SOPS_AGE_KEY")); err != nil {
+				fmt.Printf("[WARNING] SOPS_AGE_KEY is not a valid age identity: %v\n", err)
+			}
+			stateStore = backend
+		}
+	} else if stateRequired {
+		fmt.Println("[ERROR] Error: State backend required (set S3_ENDPOINT/S3_BUCKET or -state-url).")
+		os.Exit(1)
 	}
 
 	if listImages {
-		if s3Backend == nil {
+		if s3Endpoint == "" {
 			fmt.Println("[ERROR] S3 unavailable.")
 			os.Exit(1)
 		}
@@ -175,7 +272,7 @@ func main() {
 	}
 
 	if k8sImagesBundle {
-		if s3Backend == nil {
+		if s3Endpoint == "" {
 			fmt.Println("[ERROR] S3 unavailable.")
 			os.Exit(1)
 		}
@@ -201,43 +298,143 @@ func main() {
 		ageKey := os.Getenv("SOPS_AGE_KEY")
 		acmeEmail := os.Getenv("ACME_EMAIL")
 		domain := os.Getenv("DOMAIN")
-		handleFlux(s3Backend, clusterName, token, s3Access, s3Secret, ageKey, acmeEmail, domain)
+		handleFlux(stateStore, clusterName, token, s3Access, s3Secret, ageKey, acmeEmail, domain)
 		return
 	}
 
-	if criticalDiskID != "" {
-		handleMarkDiskCritical(s3Backend, clusterName, criticalDiskID)
+	if lockInfo {
+		handleLockInfo(stateStore, jsonFormat)
+		return
+	}
+
+	if forceUnlockID != "" {
+		handleForceUnlock(stateStore, forceUnlockID)
+		return
+	}
+
+	if stateHistory {
+		handleStateHistory(backend, jsonFormat)
+		return
+	}
+
+	if stateShowRev != "" {
+		handleStateShow(backend, stateShowRev, jsonFormat)
+		return
+	}
+
+	if listClusters {
+		handleListClusters(stateStore, jsonFormat)
+		return
+	}
+
+	if stateExportFile != "" {
+		handleStateExport(backend, stateExportFile)
 		return
 	}
 
 	token := os.Getenv("CLO_AUTH_TOKEN")
 	projectID := os.Getenv("CLO_OBJECT_ID")
-	apiRequired := createCluster || cleanAll || cleanDisks || delPtr != "" || addPtr != "" || resetPass || deployKubespray || syncState || attachDisks || createLB || osUpd || setPermissions
+	apiRequired := createCluster || cleanAll || cleanDisks || delPtr != "" || addPtr != "" || resetPass || deployKubespray || syncState || attachDisks || createLB || osUpd || setPermissions || driftCheck || planMode || applyPlanFile != "" || adoptProject || listOSImages || listKeypairs || createKeypair != "" || snapshotDisks || listSnapshots || rollGroupName != ""
 	if (token == "" || projectID == "") && apiRequired {
 		fmt.Println("Error: CLO_AUTH_TOKEN required.")
 		os.Exit(1)
 	}
 
+	// Commands that write state hold the lock for the whole run
+	mutatesState := createCluster || syncState || createLB || attachDisks || criticalDiskID != "" || delNodePtr != "" || stateRollbackRev != "" || stateRewrap || applyPlanFile != "" || stateImportFile != "" || stateMigrate || adoptProject || rollGroupName != ""
+	release := func() {}
+	if mutatesState && stateStore != nil {
+		var err error
+		if release, err = lockState(stateStore); err != nil {
+			fmt.Printf("[LOCK] %v\n", err)
+			os.Exit(1)
+		}
+		defer release()
+	}
+	// fail is the exit path of the commands that can hold the lock: it is released before exiting
+	fail := func(err error) {
+		if err != nil {
+			fmt.Printf("[ERROR] %v\n", err)
+			release()
+			os.Exit(1)
+		}
+	}
+
+	// The only signal handler: the first Ctrl+C/SIGTERM cancels ctx, so API calls stop and the command
+	// saves what it finished before returning; a second one releases the lock and exits at once
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sigCh := make(chan os.Signal, 2)
+	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		sig := <-sigCh
+		fmt.Printf("\n[WARNING] Received %v, stopping after the running steps (repeat to exit now)...\n", sig)
+		cancel()
+		<-sigCh
+		release()
+		os.Exit(130)
+	}()
+	// Lists are read once per run and reused until the next write
+	ctx = clo.WithListCache(ctx)
+
 	var client *clo.Client
 	if token != "" {
 		client = clo.NewClient(token, projectID)
+		client.Retry.MaxAttempts = apiRetries
+		client.Retry.CallTimeout = apiTimeout
+		client.Limits.Read.PerSecond = apiRateRead
+		client.Limits.Write.PerSecond = apiRateWrite
+		client.Limits.Poll.PerSecond = apiRatePoll
+		if apiURL != "" {
+			client.BaseURL = strings.TrimSuffix(apiURL, "/")
+		}
+	}
+
+	if stateRollbackRev != "" {
+		fail(handleStateRollback(backend, stateRollbackRev))
+		return
+	}
+
+	if stateRewrap {
+		fail(handleStateRewrap(backend, ageRecipients != ""))
+		return
+	}
+
+	if stateImportFile != "" {
+		fail(handleStateImport(backend, clusterName, stateImportFile))
+		return
+	}
+
+	if stateMigrate {
+		fail(handleStateMigrate(backend, clusterName, migrateTo, state.S3Options{Endpoint: s3Endpoint, AccessKey: s3Access, SecretKey: s3Secret, UseSSL: true}))
+		return
+	}
+
+	if adoptProject {
+		fail(handleAdoptProject(ctx, client, stateStore, clusterName, configPath))
+		return
+	}
+
+	if criticalDiskID != "" {
+		handleMarkDiskCritical(stateStore, clusterName, criticalDiskID)
+		return
 	}
 
 	if statusRestoreDB {
-		if s3Backend == nil {
-			fmt.Println("[ERROR] S3 Backend unavailable.")
+		if stateStore == nil {
+			fmt.Println("[ERROR] State backend unavailable.")
 			os.Exit(1)
 		}
-		handleStatusRestoreDB(s3Backend, clusterName, "pg", "pg-db")
+		handleStatusRestoreDB(stateStore, clusterName, "pg", "pg-db")
 		return
 	}
 
 	if createBackup {
-		if s3Backend == nil {
-			fmt.Println("[ERROR] S3 Backend unavailable.")
+		if stateStore == nil {
+			fmt.Println("[ERROR] State backend unavailable.")
 			os.Exit(1)
 		}
-		handleCreateBackup(s3Backend, clusterName)
+		handleCreateBackup(stateStore, clusterName)
 		return
 	}
 
@@ -247,82 +444,107 @@ func main() {
 			fmt.Println("[ERROR] Format error. Use: -cmcreate 'namespace:name:json_data'")
 			os.Exit(1)
 		}
-		handleCreateConfigMap(s3Backend, clusterName, parts[0], parts[1], parts[2])
+		handleCreateConfigMap(stateStore, clusterName, parts[0], parts[1], parts[2])
 		return
 	}
 
 	if kvSecStr != "" {
-		handleKVSecret(client, s3Backend, clusterName, kvSecStr)
+		handleKVSecret(ctx, client, stateStore, clusterName, kvSecStr)
 		return
 	}
 
 	if waitCertStr != "" {
-		handleWaitCert(client, s3Backend, clusterName, waitCertStr)
+		handleWaitCert(ctx, client, stateStore, clusterName, waitCertStr)
 		return
 	}
 
 	if addUserStr != "" {
-		if s3Backend == nil {
-			fmt.Println("[ERROR] S3 Backend unavailable.")
+		if stateStore == nil {
+			fmt.Println("[ERROR] State backend unavailable.")
 			os.Exit(1)
 		}
-		handleAddUser(client, s3Backend, clusterName, addUserStr, ansibleForks, ansibleLimit)
+		handleAddUser(ctx, client, stateStore, clusterName, addUserStr, ansibleForks, ansibleLimit)
 		return
 	}
 
 	if osUpd {
-		if s3Backend == nil {
-			fmt.Println("[ERROR] S3 Backend unavailable.")
+		if stateStore == nil {
+			fmt.Println("[ERROR] State backend unavailable.")
 			os.Exit(1)
 		}
-		handleOSUpdate(client, s3Backend, clusterName, ansibleForks, ansibleLimit)
+		handleOSUpdate(ctx, client, stateStore, clusterName, ansibleForks, ansibleLimit)
 		return
 	}
 
 	if setPermissions {
-		if s3Backend == nil {
-			fmt.Println("[ERROR] S3 Backend unavailable.")
+		if stateStore == nil {
+			fmt.Println("[ERROR] State backend unavailable.")
 			os.Exit(1)
 		}
 		if clusterName == "default" && configPath == "" {
 			fmt.Println("[ERROR] Specify -name or -config")
 			os.Exit(1)
 		}
-		handlePermissions(s3Backend, clusterName, configPath, outputFile, ansibleForks, ansibleLimit)
+		handlePermissions(stateStore, clusterName, configPath, outputFile, ansibleForks, ansibleLimit)
 		return
 	}
 
 	if deployKubespray {
-		handleDeploy(client, s3Backend, clusterName, outputFile, ansibleForks, "run", ansibleLimit, "")
+		handleDeploy(ctx, client, stateStore, clusterName, outputFile, ansibleForks, "run", ansibleLimit, "")
 		return
 	}
 	if mountDisks {
-		handleDeploy(client, s3Backend, clusterName, outputFile, ansibleForks, "mount", ansibleLimit, "")
+		handleDeploy(ctx, client, stateStore, clusterName, outputFile, ansibleForks, "mount", ansibleLimit, "")
+		return
+	}
+	if growFS {
+		handleDeploy(ctx, client, stateStore, clusterName, outputFile, ansibleForks, "grow-fs", ansibleLimit, "")
+		return
+	}
+
+	if rollGroupName != "" {
+		fail(handleRoll(ctx, client, stateStore, clusterName, configPath, outputFile, rollGroupName, maxUnavailable, ansibleForks))
 		return
 	}
 
 	if removeK8sNode != "" {
-		handleRemoveK8sNode(client, s3Backend, clusterName, outputFile, ansibleForks, removeK8sNode)
+		handleRemoveK8sNode(ctx, client, stateStore, clusterName, outputFile, ansibleForks, removeK8sNode)
 		return
 	}
 
 	switch {
+	case listOSImages:
+		handleListOSImages(ctx, client, jsonFormat)
+	case listKeypairs:
+		handleListKeypairs(ctx, client, jsonFormat)
+	case createKeypair != "":
+		handleCreateKeypair(ctx, client, createKeypair, publicKeyPath)
+	case snapshotDisks:
+		handleSnapshotDisks(ctx, client, stateStore, clusterName, criticalOnly, snapshotKeep)
+	case listSnapshots:
+		handleListSnapshots(ctx, client, clusterName, jsonFormat)
 	case createLB:
-		handleCreateLB(client, s3Backend, clusterName, configPath)
+		handleCreateLB(ctx, client, stateStore, clusterName, configPath)
 	case attachDisks:
-		handleAttachDisks(client, s3Backend, clusterName)
+		handleAttachDisks(ctx, client, stateStore, clusterName)
 	case delNodePtr != "":
-		handleDeleteNodeFromState(s3Backend, clusterName, delNodePtr, outputFile)
+		handleDeleteNodeFromState(stateStore, clusterName, delNodePtr, outputFile)
 	case syncState:
-		handleSync(client, s3Backend, clusterName)
+		handleSync(ctx, client, stateStore, clusterName)
+	case planMode:
+		handlePlan(ctx, client, stateStore, clusterName, configPath, forceCreate, deleteNodes, planOut)
+	case applyPlanFile != "":
+		fail(handleApply(ctx, client, stateStore, clusterName, applyPlanFile, outputFile, sshPass, noCheck))
+	case driftCheck:
+		handleDrift(ctx, client, stateStore, clusterName, configPath, jsonFormat)
 	case resetPass:
-		handleResetPassword(client, s3Backend, clusterName)
+		handleResetPassword(ctx, client, stateStore, clusterName)
 	case checkState:
-		handleCheckState(s3Backend, jsonFormat)
+		handleCheckState(stateStore, jsonFormat)
 	case createCluster:
-		handleClusterCreateFromCode(client, outputFile, s3Backend, clusterName, forceCreate, sshPass, configPath, deleteNodes, attachDisks, noCheck)
+		fail(handleClusterCreateFromCode(ctx, client, outputFile, stateStore, clusterName, forceCreate, sshPass, configPath, deleteNodes, noCheck))
 	case cleanAll:
-		handleCleanAll(client)
+		handleCleanAll(ctx, client)
 	case cleanDisks:
 		nameFlagSet := false
 		flag.Visit(func(f *flag.Flag) {
@@ -334,16 +556,32 @@ func main() {
 			fmt.Println("[ERROR] Security Error: -name flag is required for clean-disks.")
 			os.Exit(1)
 		}
-		if s3Backend == nil {
-			fmt.Println("[ERROR] S3 unavailable.")
+		if stateStore == nil {
+			fmt.Println("[ERROR] State backend unavailable.")
 			os.Exit(1)
 		}
-		handleCleanDisks(client, s3Backend, clusterName)
+		handleCleanDisks(ctx, client, stateStore, clusterName)
 	case delPtr != "":
-		handleDelete(client, delPtr)
+		handleDelete(ctx, client, delPtr)
 	case addPtr != "":
-		handleCreate(client, addPtr)
+		handleCreate(ctx, client, addPtr)
 	default:
 		flag.PrintDefaults()
 	}
+
+	if client != nil && !jsonFormat {
+		printAPIStats(client)
+	}
+}
+
+// printAPIStats prints the API calls of the run, retries and time spent in the rate limiter
+func printAPIStats(client *clo.Client) {
+	stats := client.Stats()
+	total := stats.Total()
+	if total.Calls == 0 {
+		return
+	}
+	fmt.Printf("\n[STATS] CLO API: %d calls, %d retries, %d rate limited (429), %v throttled\n",
+		total.Calls, total.Retries, total.RateLimited, total.Throttled.Round(time.Millisecond))
+	fmt.Printf("   %s\n", stats)
 }
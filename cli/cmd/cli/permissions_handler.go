@@ -3,17 +3,16 @@ package main
 import (
 	"fmt"
 	"os"
-	"strings"
 
 	"cli/internal/state"
 )
 
 // handlePermissions applies disk permissions defined in config.go to the running nodes
-func handlePermissions(backend *state.Backend, clusterName, configPath, outputFile string, forks int, limit string) {
+func handlePermissions(backend state.StateStore, clusterName, configPath, outputFile string, forks int, limit string) {
 	fmt.Println("[DISK/SEC] Preparing to apply disk permissions from Config...")
 
 	if backend == nil {
-		fmt.Println("[ERROR] S3 Backend unavailable.")
+		fmt.Println("[ERROR] State backend unavailable.")
 		os.Exit(1)
 	}
 	fmt.Printf("[REFRESH] Loading state for cluster '%s'...\n", clusterName)
@@ -29,38 +28,18 @@ func handlePermissions(backend *state.Backend, clusterName, configPath, outputFi
 		os.Exit(1)
 	}
 
-	fmt.Println("[MERGE] Overlaying 'Owner/Group/Mode' from Config onto State disks (Matching by Size)...")
+	fmt.Println("[MERGE] Overlaying 'Owner/Group/Mode' from Config onto State disks (Matching by name, mount point or size)...")
 
 	applyCount := 0
 
 	for i := range st.Nodes {
 		node := &st.Nodes[i]
 
-		var matchedGroup *NodeGroup
-		for _, grp := range cfg.Groups {
-			prefix := fmt.Sprintf("%s-%s-", clusterName, grp.NamePrefix)
-			if strings.HasPrefix(node.Name, prefix) {
-				matchedGroup = &grp
-				break
-			}
-		}
-
-		if matchedGroup != nil {
+		if matchedGroup, ok := cfg.GroupOf(clusterName, node.Name); ok {
+			configs := diskConfigs(matchedGroup.Disks, node.Disks)
 			for j := range node.Disks {
 				dState := &node.Disks[j]
-				if dState.Bootable {
-					continue
-				}
-
-				var cfgDisk *Disk
-				for _, cd := range matchedGroup.Disks {
-					if !cd.Bootable && cd.Size == dState.Size {
-						cfgDisk = &cd
-						break
-					}
-				}
-
-				if cfgDisk != nil {
+				if cfgDisk, ok := configs[j]; ok {
 					if cfgDisk.Owner != "" || cfgDisk.Group != "" || cfgDisk.Mode != "" {
 						dState.Owner = cfgDisk.Owner
 						dState.Group = cfgDisk.Group
@@ -0,0 +1,691 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"cli/internal/clo"
+	"cli/internal/local"
+	"cli/internal/state"
+)
+
+// planFormatVersion is bumped when the plan file layout changes
+// (2: disks restored from snapshots, 3: flavor resizes, 4: disk growth)
+const planFormatVersion = 4
+
+// Plan is the full change set of a cluster reconcile. -plan prints (and saves) it,
+// -apply executes a saved plan, -cluster computes and executes it in one go.
+type Plan struct {
+	FormatVersion    int          `json:"format_version"`
+	Cluster          string       `json:"cluster"`
+	Created          time.Time    `json:"created_at"`
+	StateFingerprint string       `json:"state_fingerprint"`
+	Config           Config       `json:"config"`
+	Keep             []PlanNode   `json:"keep,omitempty"`
+	Adopt            []PlanNode   `json:"adopt,omitempty"`
+	Create           []PlanNode   `json:"create,omitempty"`
+	GC               []PlanNode   `json:"gc,omitempty"`
+	Resize           []PlanResize `json:"resize,omitempty"`
+	Grow             []PlanGrow   `json:"grow,omitempty"`
+	LB               *PlanLB      `json:"lb,omitempty"`
+	DeleteFromCloud  bool         `json:"delete_from_cloud"`
+}
+
+// PlanNode is a node with the action planned for it
+type PlanNode struct {
+	Name      string            `json:"name"`
+	Group     string            `json:"group"`
+	Role      string            `json:"role"`
+	ID        string            `json:"id,omitempty"`
+	IP        string            `json:"ip,omitempty"`
+	SSHPort   int               `json:"ssh_port,omitempty"`
+	AddressID string            `json:"address_id,omitempty"`
+	Labels    map[string]string `json:"labels,omitempty"`
+	Taints    []string          `json:"taints,omitempty"`
+	Disks     []state.DiskState `json:"disks,omitempty"`
+	OldDisks  []state.DiskState `json:"old_disks,omitempty"`
+	NewDisks  []PlannedDisk     `json:"new_disks,omitempty"`
+	Created   string            `json:"created_at,omitempty"`
+}
+
+// PlanResize is a kept node whose server flavor differs from its group: it is resized in place
+// when the group opts in with resize_in_place, otherwise it is left for replacement with -roll
+type PlanResize struct {
+	Name   string `json:"name"`
+	Group  string `json:"group"`
+	Role   string `json:"role"`
+	ID     string `json:"id"`
+	From   Flavor `json:"from"`
+	To     Flavor `json:"to"`
+	Action string `json:"action"` // "resize" or "replace"
+}
+
+// PlanGrow is a data volume of a kept node to extend to its configured size; the ext4
+// filesystem on it is grown online afterwards
+type PlanGrow struct {
+	Node       string `json:"node"`
+	VolumeID   string `json:"volume_id"`
+	Name       string `json:"name,omitempty"`
+	MountPoint string `json:"mount_point,omitempty"`
+	From       int    `json:"from"`
+	To         int    `json:"to"`
+}
+
+// PlannedDisk is one disk of a node to create: a new volume, an existing one to reattach
+// or a new one restored from the latest snapshot of a lost volume
+type PlannedDisk struct {
+	Action     string            `json:"action"` // "create", "reattach" or "restore"
+	Name       string            `json:"name,omitempty"`
+	Tags       map[string]string `json:"tags,omitempty"` // metadata of a new volume
+	VolumeID   string            `json:"volume_id,omitempty"`
+	SnapshotID string            `json:"snapshot_id,omitempty"`
+	Size       int               `json:"size"`
+	Type       string            `json:"type"`
+	Bootable   bool              `json:"bootable"`
+	MountPoint string            `json:"mount_point,omitempty"`
+}
+
+// PlanLB is the planned load balancer change. Targets follow the nodes and are
+// reconciled on every run; Changes lists what differs in settings and ports.
+type PlanLB struct {
+	Name    string   `json:"name"`
+	Action  string   `json:"action"` // "create", "update", "none" or "delete"
+	ID      string   `json:"id,omitempty"`
+	Rules   int      `json:"rules"`
+	Changes []string `json:"changes,omitempty"`
+}
+
+// computePlan reads config, state and the cloud and decides what to keep, adopt, create and GC.
+// It does not change anything.
+func computePlan(ctx context.Context, client *clo.Client, backend state.StateStore, cfg *Config, clusterName string, force, deleteFromCloud bool) (*Plan, error) {
+	if err := resolveNames(ctx, client, cfg); err != nil {
+		return nil, err
+	}
+	plan := &Plan{FormatVersion: planFormatVersion, Cluster: clusterName, Created: time.Now(), Config: *cfg, DeleteFromCloud: deleteFromCloud}
+
+	fmt.Println("[CLOUD] Getting list of servers (API)...")
+	allServersList, err := client.GetServersList(ctx)
+	if err != nil {
+		// Without the list every server looks missing and would be planned for creation
+		return nil, fmt.Errorf("server list: %w", err)
+	}
+	cloudServerMap := make(map[string]string)
+	for _, srv := range allServersList.Result {
+		cloudServerMap[srv.Name] = srv.ID
+	}
+
+	aliveNodesMap := make(map[string]state.NodeState)
+	liveFlavors := make(map[string]Flavor)
+	var existingState *state.ClusterState
+
+	if backend != nil {
+		fmt.Printf("[SEARCH] Loading State '%s'...\n", clusterName)
+		existingState, err = backend.LoadState()
+		if err != nil {
+			// Never rebuild a state we could not read (newer schema, missing key): it would be overwritten
+			return nil, fmt.Errorf("state load error: %w", err)
+		}
+		plan.StateFingerprint = state.Fingerprint(existingState)
+		if existingState != nil && len(existingState.Nodes) > 0 {
+			if force {
+				fmt.Println("[WARNING] -force: Ignoring state.")
+			} else {
+				fmt.Println("[+++] Verifying State against Cloud API...")
+				for _, n := range existingState.Nodes {
+					detail, fetchErr := client.GetServerDetail(ctx, n.ID)
+					if clo.IsNotFound(fetchErr) {
+						fmt.Printf("   [x] Node '%s' lost in the cloud.\n", n.Name)
+						continue
+					}
+					if fetchErr != nil {
+						return nil, fmt.Errorf("node '%s': %w", n.Name, fetchErr)
+					}
+					ip, addrID, disks, created := nodeDetails(ctx, client, detail, n.ID, n.Disks, nil)
+					liveFlavors[n.Name] = Flavor{RAM: detail.Result.Flavor.RAM, VCPUs: detail.Result.Flavor.VCPUs}
+
+					finalIP := ip
+					if n.SSHPort != 0 && n.IP != ip {
+						finalIP = n.IP
+					}
+
+					aliveNodesMap[n.Name] = state.NodeState{
+						Name: n.Name, Role: n.Role, ID: n.ID, IP: finalIP, SSHPort: n.SSHPort, AddressID: addrID, Labels: n.Labels, Taints: n.Taints, Disks: disks, Created: created, Updated: time.Now().Format(time.RFC3339),
+					}
+				}
+			}
+		}
+	}
+
+	// Volumes are shared between all nodes of the plan, so two new nodes never get the same one
+	allProjectVolumes, err := client.GetProjectVolumes(ctx)
+	if err != nil {
+		// Without the list no volume would be reattached
+		return nil, fmt.Errorf("volume list: %w", err)
+	}
+	var availableVolumes []clo.DiskResult
+	existingVolumes := make(map[string]bool)
+	for _, v := range allProjectVolumes.Result {
+		existingVolumes[v.ID] = true
+		if v.Status == "AVAILABLE" {
+			availableVolumes = append(availableVolumes, v)
+		}
+	}
+	usedVolIDs := make(map[string]bool)
+
+	desired := make(map[string]bool)
+	for _, dn := range cfg.DesiredNodes(clusterName) {
+		group, mergedLabels, nodeName := dn.Group, dn.Labels, dn.Name
+		desired[nodeName] = true
+		if existing, ok := aliveNodesMap[nodeName]; ok {
+			existing.Disks = planDiskGrowth(plan, nodeName, group, existing.Disks)
+			plan.Keep = append(plan.Keep, PlanNode{
+				Name: existing.Name, Group: group.NamePrefix, Role: group.Role, Labels: mergedLabels, Taints: group.Taints,
+				ID: existing.ID, IP: existing.IP, SSHPort: existing.SSHPort, AddressID: existing.AddressID, Disks: existing.Disks, Created: existing.Created,
+			})
+			if have := liveFlavors[nodeName]; have.RAM != 0 && have != group.Flavor {
+				action := "replace"
+				if group.ResizeInPlace {
+					action = "resize"
+				}
+				plan.Resize = append(plan.Resize, PlanResize{
+					Name: nodeName, Group: group.NamePrefix, Role: group.Role, ID: existing.ID, From: have, To: group.Flavor, Action: action,
+				})
+			}
+			continue
+		}
+		if realID, exists := cloudServerMap[nodeName]; exists {
+			ip, addrID, disks, created, err := fetchNodeDetails(ctx, client, realID, nil, group.Disks)
+			if err != nil {
+				fmt.Printf("[WARNING] Cannot adopt '%s': %v\n", nodeName, err)
+				continue
+			}
+			plan.Adopt = append(plan.Adopt, PlanNode{
+				Name: nodeName, Group: group.NamePrefix, Role: group.Role, ID: realID, IP: ip, AddressID: addrID,
+				Labels: mergedLabels, Taints: group.Taints, Disks: disks, Created: created,
+			})
+			continue
+		}
+		var oldDisks []state.DiskState
+		if existingState != nil {
+			for _, n := range existingState.Nodes {
+				if n.Name == nodeName {
+					oldDisks = n.Disks
+					break
+				}
+			}
+		}
+		plan.Create = append(plan.Create, PlanNode{
+			Name: nodeName, Group: group.NamePrefix, Role: group.Role, Labels: mergedLabels, Taints: group.Taints,
+			OldDisks: oldDisks, NewDisks: planNodeDisks(clusterName, nodeName, group, oldDisks, availableVolumes, usedVolIDs),
+		})
+	}
+
+	planSnapshotRestores(ctx, client, clusterName, plan.Create, existingVolumes)
+
+	for name, existing := range aliveNodesMap {
+		if !desired[name] {
+			plan.GC = append(plan.GC, PlanNode{Name: name, Role: existing.Role, ID: existing.ID, IP: existing.IP, Disks: existing.Disks})
+		}
+	}
+	sort.Slice(plan.GC, func(i, j int) bool { return plan.GC[i].Name < plan.GC[j].Name })
+
+	rules := 0
+	for _, g := range cfg.Groups {
+		rules += len(g.lbRules())
+	}
+	lbName := fmt.Sprintf("%s-main-lb", clusterName)
+	existingLB, err := findLB(ctx, client, lbName)
+	if err != nil {
+		// An LB we could not look up would be planned for creation a second time
+		return nil, err
+	}
+	switch {
+	case rules > 0 && existingLB == nil:
+		plan.LB = &PlanLB{Name: lbName, Action: "create", Rules: rules}
+	case rules > 0:
+		plan.LB = &PlanLB{Name: lbName, Action: "none", ID: existingLB.ID, Rules: rules}
+		if current, err := client.GetLoadBalancerDetail(ctx, existingLB.ID); err == nil {
+			algorithm, monitor := cfg.LBSettings()
+			plan.LB.Changes = append(diffLBPorts(current.Rules, cfg), lbSettingChanges(current, algorithm, monitor)...)
+		} else {
+			fmt.Printf("[WARNING] Load balancer detail error: %v\n", err)
+		}
+		if len(plan.LB.Changes) > 0 {
+			plan.LB.Action = "update"
+		}
+	case existingLB != nil:
+		plan.LB = &PlanLB{Name: lbName, Action: "delete", ID: existingLB.ID}
+	}
+	if err := ctx.Err(); err != nil {
+		// Calls cut short by the interrupt make nodes look missing: such a plan is never used
+		return nil, fmt.Errorf("plan interrupted: %w", err)
+	}
+	return plan, nil
+}
+
+// planDiskGrowth adds the data disks of a kept node whose configured size grew to the plan.
+// Disks recorded before they had names get the name of their config disk.
+func planDiskGrowth(plan *Plan, nodeName string, group NodeGroup, disks []state.DiskState) []state.DiskState {
+	out := append([]state.DiskState(nil), disks...)
+	configs := diskConfigs(group.Disks, disks)
+	for i, d := range out {
+		cd, ok := configs[i]
+		if !ok {
+			continue
+		}
+		out[i].Name = cd.Name
+		switch {
+		case cd.Size > d.Size:
+			plan.Grow = append(plan.Grow, PlanGrow{Node: nodeName, VolumeID: d.ID, Name: cd.Name, MountPoint: d.MountPoint, From: d.Size, To: cd.Size})
+		case cd.Size < d.Size:
+			fmt.Printf("[WARNING] %s: disk %s is %dGB, the config asks for %dGB. Volumes cannot shrink, it is kept.\n", nodeName, diskLabel(out[i]), d.Size, cd.Size)
+		}
+	}
+	return out
+}
+
+// diskLabel names a data disk in messages: its config name, or its mount point
+func diskLabel(d state.DiskState) string {
+	if d.Name != "" {
+		return fmt.Sprintf("'%s'", d.Name)
+	}
+	return d.MountPoint
+}
+
+// planNodeDisks chooses for every configured disk whether to reattach a volume of the node or create
+// a new one. A volume is reattached if the state records it for this disk, or if it is tagged for this
+// cluster, node and disk; volumes of other clusters and untagged ones are never taken.
+func planNodeDisks(clusterName, nodeName string, group NodeGroup, oldDisks []state.DiskState, availableVolumes []clo.DiskResult, usedVolIDs map[string]bool) []PlannedDisk {
+	var out []PlannedDisk
+	configs := diskConfigs(group.Disks, oldDisks)
+	for _, dConf := range group.Disks {
+		t := dConf.Type
+		if t == "" {
+			t = "storage"
+		}
+		pd := PlannedDisk{Action: "create", Name: dConf.Name, Size: dConf.Size, Type: t, Bootable: dConf.Bootable, MountPoint: dConf.MountPoint}
+		if dConf.Bootable {
+			out = append(out, pd)
+			continue
+		}
+		pd.Tags = volumeTags(clusterName, nodeName, dConf.Name)
+		for k, oldD := range oldDisks {
+			// Matched by name, so a disk keeps its volume when its size changes; it grows on the next run
+			if cd, ok := configs[k]; !ok || cd.Name != dConf.Name {
+				continue
+			}
+			for _, av := range availableVolumes {
+				if av.ID == oldD.ID && !usedVolIDs[av.ID] && !foreignVolume(av, clusterName) {
+					pd.Action, pd.VolumeID = "reattach", av.ID
+					break
+				}
+			}
+			if pd.VolumeID != "" {
+				break
+			}
+		}
+		if pd.VolumeID == "" {
+			for _, av := range availableVolumes {
+				if !usedVolIDs[av.ID] && av.Tags[volTagCluster] == clusterName && av.Tags[volTagNode] == nodeName && av.Tags[volTagDisk] == dConf.Name {
+					pd.Action, pd.VolumeID = "reattach", av.ID
+					break
+				}
+			}
+		}
+		if pd.VolumeID != "" {
+			usedVolIDs[pd.VolumeID] = true
+		}
+		out = append(out, pd)
+	}
+	return out
+}
+
+// diffLBPorts lists the port pairs the config adds to (+) or removes from (-) the LB rules
+func diffLBPorts(have []clo.LBRule, cfg *Config) []string {
+	type pair struct{ ext, in int }
+	h := make(map[pair]bool)
+	for _, r := range have {
+		h[pair{r.ExtPort, r.IntPort}] = true
+	}
+	w := make(map[pair]bool)
+	for _, g := range cfg.Groups {
+		for _, r := range g.lbRules() {
+			w[pair{r.ExtPort, r.IntPort}] = true
+		}
+	}
+	var out []string
+	for p := range w {
+		if !h[p] {
+			out = append(out, fmt.Sprintf("+ rule :%d -> :%d", p.ext, p.in))
+		}
+	}
+	for p := range h {
+		if !w[p] {
+			out = append(out, fmt.Sprintf("- rule :%d -> :%d", p.ext, p.in))
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// lbSettingChanges describes how the algorithm and health check of the LB differ from the wanted ones
+func lbSettingChanges(current *clo.LoadBalancerInfo, algorithm string, monitor clo.LBHealthMonitor) []string {
+	var out []string
+	if current.Algorithm != algorithm {
+		out = append(out, fmt.Sprintf("~ algorithm %s -> %s", current.Algorithm, algorithm))
+	}
+	if current.HealthMonitor != monitor {
+		out = append(out, fmt.Sprintf("~ health check %s -> %s", formatMonitor(current.HealthMonitor), formatMonitor(monitor)))
+	}
+	return out
+}
+
+// HasChanges reports whether applying the plan would change anything
+func (p *Plan) HasChanges() bool {
+	return len(p.Adopt) > 0 || len(p.Create) > 0 || len(p.GC) > 0 || p.resizes() > 0 || len(p.Grow) > 0 || p.LB.changes(p.DeleteFromCloud)
+}
+
+// resizes counts the nodes the plan resizes in place
+func (p *Plan) resizes() int {
+	n := 0
+	for _, r := range p.Resize {
+		if r.Action == "resize" {
+			n++
+		}
+	}
+	return n
+}
+
+// changes reports whether the LB part of the plan changes anything
+func (lb *PlanLB) changes(deleteFromCloud bool) bool {
+	if lb == nil {
+		return false
+	}
+	return lb.Action == "create" || lb.Action == "update" || (lb.Action == "delete" && deleteFromCloud)
+}
+
+// printPlan prints the change set in a terraform-like format
+func printPlan(p *Plan) {
+	fmt.Printf("\n[PLAN] Cluster '%s'\n", p.Cluster)
+	resize := make(map[string]PlanResize)
+	for _, r := range p.Resize {
+		resize[r.Name] = r
+	}
+	grow := make(map[string][]PlanGrow)
+	for _, g := range p.Grow {
+		grow[g.Node] = append(grow[g.Node], g)
+	}
+	for _, n := range p.Keep {
+		r, ok := resize[n.Name]
+		switch {
+		case ok && r.Action == "resize":
+			fmt.Printf("  ~ %s resize %s -> %s (drain, stop, resize, start; left for -roll %s if the project cannot resize)\n", n.Name, r.From, r.To, r.Group)
+		case ok:
+			fmt.Printf("-/+ %s flavor %s -> %s needs replacement: run -roll %s (or set resize_in_place)\n", n.Name, r.From, r.To, r.Group)
+		case len(grow[n.Name]) > 0:
+			fmt.Printf("  ~ %s (%s)\n", n.Name, n.IP)
+		default:
+			fmt.Printf("    %s (%s) unchanged\n", n.Name, n.IP)
+		}
+		for _, g := range grow[n.Name] {
+			label := g.MountPoint
+			if g.Name != "" {
+				label = fmt.Sprintf("'%s' %s", g.Name, g.MountPoint)
+			}
+			fmt.Printf("      disk %s: %dGB -> %dGB (extend volume, grow ext4 online)\n", label, g.From, g.To)
+		}
+	}
+	for _, n := range p.Adopt {
+		fmt.Printf("  ~ %s adopt existing server %s (%s)\n", n.Name, n.ID, n.IP)
+	}
+	for _, n := range p.Create {
+		fmt.Printf("  + %s create (group '%s', role %s)\n", n.Name, n.Group, n.Role)
+		for _, d := range n.NewDisks {
+			kind := "data"
+			if d.Bootable {
+				kind = "boot"
+			}
+			switch d.Action {
+			case "reattach":
+				fmt.Printf("      disk %dGB %s: reattach %s\n", d.Size, kind, d.VolumeID)
+			case "restore":
+				fmt.Printf("      disk %dGB %s %s: restore from snapshot %s (volume lost)\n", d.Size, kind, d.MountPoint, d.SnapshotID)
+			default:
+				fmt.Printf("      disk %dGB %s %s: create\n", d.Size, d.Type, kind)
+			}
+		}
+	}
+	for _, n := range p.GC {
+		if p.DeleteFromCloud {
+			fmt.Printf("  - %s delete server %s (not in config)\n", n.Name, n.ID)
+		} else {
+			fmt.Printf("  - %s remove from state, server %s kept (use -delnodes to delete)\n", n.Name, n.ID)
+		}
+	}
+	if p.LB != nil {
+		switch p.LB.Action {
+		case "create":
+			fmt.Printf("  + %s create load balancer with %d rule(s)\n", p.LB.Name, p.LB.Rules)
+		case "update":
+			fmt.Printf("  ~ %s update load balancer %s\n", p.LB.Name, p.LB.ID)
+			for _, c := range p.LB.Changes {
+				fmt.Printf("      %s\n", c)
+			}
+		case "delete":
+			if p.DeleteFromCloud {
+				fmt.Printf("  - %s delete load balancer %s (no lb_rules in config)\n", p.LB.Name, p.LB.ID)
+			} else {
+				fmt.Printf("    %s load balancer kept, no lb_rules in config (use -delnodes to delete)\n", p.LB.Name)
+			}
+		}
+	}
+	fmt.Printf("\n[PLAN] %d to keep, %d to adopt, %d to create, %d to remove", len(p.Keep), len(p.Adopt), len(p.Create), len(p.GC))
+	if n := p.resizes(); n > 0 {
+		fmt.Printf(", %d to resize", n)
+	}
+	if n := len(p.Resize) - p.resizes(); n > 0 {
+		fmt.Printf(", %d to replace with -roll", n)
+	}
+	if len(p.Grow) > 0 {
+		fmt.Printf(", %d disk(s) to grow", len(p.Grow))
+	}
+	if p.LB.changes(p.DeleteFromCloud) {
+		fmt.Printf(", 1 load balancer to %s", p.LB.Action)
+	}
+	fmt.Println(".")
+}
+
+// handlePlan computes the plan, prints it and optionally writes it to planOut
+func handlePlan(ctx context.Context, client *clo.Client, backend state.StateStore, clusterName, configPath string, force, deleteFromCloud bool, planOut string) {
+	cfg, err := GetClusterConfig(configPath)
+	if err != nil {
+		fmt.Printf("[ERROR] Configuration error: %v\n", err)
+		os.Exit(1)
+	}
+	plan, err := computePlan(ctx, client, backend, cfg, clusterName, force, deleteFromCloud)
+	if err != nil {
+		fmt.Printf("[ERROR] %v\n", err)
+		os.Exit(1)
+	}
+	printPlan(plan)
+
+	if planOut == "" {
+		return
+	}
+	data, _ := json.MarshalIndent(plan, "", "  ")
+	if err := os.WriteFile(planOut, data, 0600); err != nil {
+		fmt.Printf("[ERROR] Plan write error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("[SAVE] Plan saved to %s. Run with -apply %s to execute it.\n", planOut, planOut)
+}
+
+// handleApply executes a saved plan. It refuses to run if the state changed since the plan was made.
+func handleApply(ctx context.Context, client *clo.Client, backend state.StateStore, clusterName, planFile, inventoryPath, manualPassword string, noCheck bool) error {
+	startTime := time.Now()
+	defer func() {
+		fmt.Printf("\n[TIMER] Execution time (Apply): %v\n", time.Since(startTime).Round(time.Second))
+	}()
+
+	data, err := os.ReadFile(planFile)
+	if err != nil {
+		return fmt.Errorf("plan read error: %w", err)
+	}
+	var plan Plan
+	if err := json.Unmarshal(data, &plan); err != nil {
+		return fmt.Errorf("plan parse error: %w", err)
+	}
+	if plan.FormatVersion != planFormatVersion {
+		return fmt.Errorf("plan format %d is not supported (expected %d). Run -plan again", plan.FormatVersion, planFormatVersion)
+	}
+	if plan.Cluster != clusterName {
+		return fmt.Errorf("plan is for cluster '%s', not '%s'", plan.Cluster, clusterName)
+	}
+	if err := plan.Config.Validate(); err != nil {
+		return fmt.Errorf("plan config: %w\nRun -plan again", err)
+	}
+
+	current, err := backend.LoadState()
+	if err != nil {
+		return fmt.Errorf("state load error: %w", err)
+	}
+	if fp := state.Fingerprint(current); fp != plan.StateFingerprint {
+		return fmt.Errorf("state changed since the plan was created (%s). Run -plan again", plan.Created.Format(time.RFC1123))
+	}
+
+	printPlan(&plan)
+	executePlan(ctx, client, backend, &plan, inventoryPath, manualPassword, noCheck, false)
+	fmt.Println("\n[INFO] Volume tags, firewalls and load balancer targets are not part of a plan: run -cluster to reconcile them.")
+	return nil
+}
+
+// executePlan carries out a plan: creates nodes, GCs extra ones, resizes changed flavors and disks, saves state and creates the LB.
+// interactive is the -cluster run: every deletion is confirmed and the parts a plan does not record
+// (volume tags, firewalls, LB targets) are reconciled too. -apply carries out only what the plan shows.
+func executePlan(ctx context.Context, client *clo.Client, backend state.StateStore, plan *Plan, inventoryPath, manualPassword string, noCheck, interactive bool) {
+	clusterName := plan.Cluster
+	currentPassword := manualPassword
+	if currentPassword == "" {
+		if loaded, err := local.LoadPassword(clusterName); err == nil && loaded != "" {
+			currentPassword = loaded
+			fmt.Println("[KEY] Found locally saved password.")
+		}
+	}
+	if currentPassword == "" {
+		genPass, _ := clo.GenerateRandomPassword()
+		currentPassword = genPass
+	}
+
+	// Keyed by node name: each node takes its group with its instance overrides
+	groups := make(map[string]NodeGroup)
+	for _, dn := range plan.Config.DesiredNodes(plan.Cluster) {
+		groups[dn.Name] = dn.Group
+	}
+
+	var finalNodes []NodeResult
+	for _, n := range append(append([]PlanNode{}, plan.Keep...), plan.Adopt...) {
+		finalNodes = append(finalNodes, NodeResult{
+			Name: n.Name, Role: n.Role, ID: n.ID, IP: n.IP, SSHPort: n.SSHPort, AddressID: n.AddressID,
+			Labels: n.Labels, Taints: n.Taints, Disks: n.Disks, IsNew: false, Created: n.Created,
+		})
+	}
+	for _, n := range plan.Adopt {
+		fmt.Printf("[WARNING] Adopting '%s' (Import)...\n", n.Name)
+	}
+
+	if len(plan.Create) > 0 {
+		maxConcurrency := 5
+		fmt.Printf("\n[T] Creating nodes: %d (concurrency: %d)...\n", len(plan.Create), maxConcurrency)
+		results := make(chan NodeResult, len(plan.Create))
+		var wg sync.WaitGroup
+		sem := make(chan struct{}, maxConcurrency)
+		for _, item := range plan.Create {
+			wg.Add(1)
+			go func(itm PlanNode) {
+				sem <- struct{}{}
+				defer func() { <-sem }()
+				createNodeAsync(ctx, &wg, client, itm.Name, groups[itm.Name], itm.OldDisks, itm.NewDisks, itm.Labels, currentPassword, results)
+			}(item)
+		}
+		go func() { wg.Wait(); close(results) }()
+		for res := range results {
+			if res.Err != nil {
+				fmt.Printf("[ERROR] [%s] Error: %v\n", res.Name, res.Err)
+			} else {
+				fmt.Printf("[+OK+] [%s] Created (%s)\n", res.Name, res.IP)
+				finalNodes = append(finalNodes, res)
+			}
+		}
+	} else {
+		fmt.Println("\n[CELEBRATION] All nodes (from config) are in order.")
+	}
+
+	if ctx.Err() != nil {
+		// Interrupted: record the servers that exist, extra ones included as they were not deleted, and stop
+		fmt.Println("\n[WARNING] Interrupted: saving the finished nodes, the remaining steps are left to the next run.")
+		for _, n := range plan.GC {
+			finalNodes = append(finalNodes, NodeResult{Name: n.Name, Role: n.Role, ID: n.ID, IP: n.IP, Disks: n.Disks})
+		}
+		runPostActions(backend, inventoryPath, plan.Config.SSHUser, clusterName, currentPassword, finalNodes, nil, true)
+		return
+	}
+
+	for _, n := range plan.GC {
+		fmt.Printf("\n[TRASH_CAN] EXTRA NODE DETECTED: '%s' (ID: %s)\n", n.Name, n.ID)
+		if !plan.DeleteFromCloud {
+			fmt.Println("   [INFO] -delnodes disabled. State only.")
+			continue
+		}
+		if interactive && !askForConfirmation(fmt.Sprintf("[WARNING] Delete server '%s'?", n.Name)) {
+			continue
+		}
+		fmt.Printf("   [EXPLOSION] DELETING SERVER...\n")
+		handleDelete(ctx, client, n.ID)
+	}
+
+	if plan.resizes() > 0 {
+		resizeNodes(ctx, client, backend, groups, plan.Resize, inventoryPath)
+	}
+	grown := growDisks(ctx, client, plan.Grow, finalNodes)
+
+	if interactive {
+		tagVolumes(ctx, client, clusterName, finalNodes)
+	}
+
+	var dropped []string
+	for _, n := range plan.GC {
+		dropped = append(dropped, n.Name)
+	}
+	runPostActions(backend, inventoryPath, plan.Config.SSHUser, clusterName, currentPassword, finalNodes, dropped, noCheck)
+	if len(grown) > 0 {
+		growFilesystems(backend, inventoryPath, plan.Config.SSHUser, finalNodes, grown, noCheck)
+	}
+	if interactive {
+		reconcileFirewalls(ctx, client, clusterName, &plan.Config, finalNodes)
+	}
+
+	if plan.LB == nil || backend == nil || (!interactive && !plan.LB.changes(plan.DeleteFromCloud)) {
+		return
+	}
+	fmt.Println()
+	if plan.LB.Action != "delete" {
+		// With -cluster also when nothing is planned: replaced nodes need their new addresses as targets
+		reconcileLB(ctx, client, backend, clusterName, &plan.Config)
+		return
+	}
+	if !plan.DeleteFromCloud || (interactive && !askForConfirmation(fmt.Sprintf("[WARNING] Delete load balancer '%s'?", plan.LB.Name))) {
+		fmt.Printf("[INFO] Load balancer '%s' kept.\n", plan.LB.Name)
+		return
+	}
+	fmt.Printf("[BOO] Deleting load balancer '%s' (ID: %s)... ", plan.LB.Name, plan.LB.ID)
+	if err := client.DeleteLoadBalancer(ctx, plan.LB.ID); err != nil && !clo.IsNotFound(err) {
+		fmt.Printf("[ERROR] Error: %v\n", err)
+	} else {
+		fmt.Println("[+OK+] Deleted.")
+	}
+}
@@ -0,0 +1,671 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"cli/internal/clo"
+	"cli/internal/clo/clotest"
+	"cli/internal/state"
+)
+
+const testCluster = "test"
+
+const testConfig = `
+ssh_user: root
+image: ubuntu-22.04
+keypairs: [ci-deploy]
+groups:
+  - name_prefix: bastion
+    role: BASTION
+    instances:
+      1: {enabled: true}
+    flavor: {ram: 2, vcpus: 1}
+    disks:
+      - {size: 10, bootable: true}
+    lb_rules:
+      - {ext_port: 2222, int_port: 22}
+  - name_prefix: master
+    role: master
+    instances:
+      1: {enabled: true}
+      2: {enabled: true, labels: {zone: b}}
+    flavor: {ram: 4, vcpus: 2}
+    cpu_type: DEDICATED
+    user_data: |
+      #cloud-config
+      timezone: Europe/Moscow
+    labels: {tier: control}
+    disks:
+      - {size: 20, bootable: true}
+      - {size: 50, mount_point: /data}
+`
+
+// testEnv is one cluster against a fresh fake API and an in-memory state
+type testEnv struct {
+	t       *testing.T
+	fake    *clotest.Server
+	client  *clo.Client
+	backend *state.Backend
+	config  string
+}
+
+func newTestEnv(t *testing.T, config string) *testEnv {
+	t.Helper()
+	saved := []time.Duration{createRetryPause, statusPollInterval, attachSettleTime}
+	createRetryPause, statusPollInterval, attachSettleTime = time.Millisecond, time.Millisecond, time.Millisecond
+	t.Cleanup(func() {
+		createRetryPause, statusPollInterval, attachSettleTime = saved[0], saved[1], saved[2]
+	})
+
+	path := filepath.Join(t.TempDir(), "cluster.yaml")
+	if err := os.WriteFile(path, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	fake := clotest.NewServer(t)
+	fake.AddKeypair("ci-deploy", "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAITest ci@example")
+	return &testEnv{
+		t:       t,
+		fake:    fake,
+		client:  fake.Client(),
+		backend: state.NewStoreBackend(state.NewMemoryStore(), "clusters/"+testCluster+"/state.json"),
+		config:  path,
+	}
+}
+
+// reconcile runs -cluster without SSH checks, with a list cache like main
+func (e *testEnv) reconcile() {
+	handleClusterCreateFromCode(clo.WithListCache(e.t.Context()), e.client, "", e.backend, testCluster, false, "secret", e.config, false, true)
+}
+
+func (e *testEnv) plan() *Plan {
+	e.t.Helper()
+	cfg, err := GetClusterConfig(e.config)
+	if err != nil {
+		e.t.Fatal(err)
+	}
+	p, err := computePlan(clo.WithListCache(e.t.Context()), e.client, e.backend, cfg, testCluster, false, false)
+	if err != nil {
+		e.t.Fatal(err)
+	}
+	return p
+}
+
+func (e *testEnv) state() *state.ClusterState {
+	e.t.Helper()
+	st, err := e.backend.LoadState()
+	if err != nil || st == nil {
+		e.t.Fatalf("state: %v", err)
+	}
+	return st
+}
+
+func (e *testEnv) node(name string) state.NodeState {
+	e.t.Helper()
+	for _, n := range e.state().Nodes {
+		if n.Name == name {
+			return n
+		}
+	}
+	e.t.Fatalf("node %s not in state", name)
+	return state.NodeState{}
+}
+
+func dataDisk(n state.NodeState) (state.DiskState, bool) {
+	for _, d := range n.Disks {
+		if !d.Bootable {
+			return d, true
+		}
+	}
+	return state.DiskState{}, false
+}
+
+// withStdin feeds input to the confirmation prompts of a handler
+func withStdin(t *testing.T, input string) {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.WriteString(input)
+	w.Close()
+	saved := os.Stdin
+	os.Stdin = r
+	t.Cleanup(func() { os.Stdin = saved; r.Close() })
+}
+
+func TestReconcileCreatesCluster(t *testing.T) {
+	e := newTestEnv(t, testConfig)
+	lbAddr := e.fake.AddExternalAddress()
+	e.reconcile()
+
+	servers := e.fake.Servers()
+	if len(servers) != 3 {
+		t.Fatalf("got %d servers, want 3", len(servers))
+	}
+	for _, s := range servers {
+		if s.Status != "ACTIVE" || s.Password != "secret" {
+			t.Errorf("%s: status %s, password set %v", s.Name, s.Status, s.Password != "")
+		}
+		if s.Image != clotest.UbuntuImage || len(s.Keypairs) != 1 || s.Keypairs[0] != e.fake.Keypairs()[0].ID {
+			t.Errorf("%s: image %q, keypairs %v not resolved from the config", s.Name, s.Image, s.Keypairs)
+		}
+	}
+	if m, _ := e.fake.ServerByName("test-master-1"); m.CPUType != "DEDICATED" || !strings.HasPrefix(m.UserData, "#cloud-config") {
+		t.Errorf("master: cpu_type %q, user-data %q", m.CPUType, m.UserData)
+	}
+	if b, _ := e.fake.ServerByName("test-bastion-1"); b.CPUType != "SHARED" || b.UserData != "" {
+		t.Errorf("bastion: cpu_type %q, user-data %q", b.CPUType, b.UserData)
+	}
+
+	st := e.state()
+	if len(st.Nodes) != 3 || st.SSHUser != "root" {
+		t.Fatalf("state has %d nodes, ssh user %q", len(st.Nodes), st.SSHUser)
+	}
+	m2 := e.node("test-master-2")
+	if m2.Labels["tier"] != "control" || m2.Labels["zone"] != "b" {
+		t.Errorf("labels not merged: %v", m2.Labels)
+	}
+	d, ok := dataDisk(m2)
+	if !ok || d.Size != 50 || d.MountPoint != "/data" || d.Device == "" {
+		t.Errorf("data disk not recorded: %+v", m2.Disks)
+	}
+
+	lbs := e.fake.LoadBalancers()
+	if len(lbs) != 1 || lbs[0].Name != "test-main-lb" || len(lbs[0].Request.Rules) != 1 {
+		t.Fatalf("load balancer not created as expected: %+v", lbs)
+	}
+	if lbs[0].AddressID != lbAddr {
+		t.Errorf("load balancer got address %s, want the free one %s", lbs[0].AddressID, lbAddr)
+	}
+	var lbIP string
+	for _, a := range e.fake.Addresses() {
+		if a.ID == lbAddr {
+			lbIP = a.Address
+		}
+	}
+	if b := e.node("test-bastion-1"); b.IP != lbIP || b.SSHPort != 2222 {
+		t.Errorf("bastion should be reached through the LB at %s:2222, got %s:%d", lbIP, b.IP, b.SSHPort)
+	}
+}
+
+func TestReconcileIsIdempotent(t *testing.T) {
+	e := newTestEnv(t, testConfig)
+	e.reconcile()
+	creates := e.fake.CountCalls("POST", "/projects/"+clotest.ProjectID+"/servers")
+
+	if p := e.plan(); p.HasChanges() {
+		t.Fatalf("second plan has changes: create %d, adopt %d, gc %d, lb %+v", len(p.Create), len(p.Adopt), len(p.GC), p.LB)
+	}
+	e.reconcile()
+	if got := e.fake.CountCalls("POST", "/projects/"+clotest.ProjectID+"/servers"); got != creates {
+		t.Fatalf("second run created servers: %d POSTs, want %d", got, creates)
+	}
+	if len(e.fake.LoadBalancers()) != 1 {
+		t.Fatal("second run duplicated the load balancer")
+	}
+}
+
+func TestReconcileRecreatesLostNodeWithItsDisk(t *testing.T) {
+	e := newTestEnv(t, testConfig)
+	e.reconcile()
+	old := e.node("test-master-1")
+	oldDisk, _ := dataDisk(old)
+
+	e.fake.DeleteServerOutOfBand("test-master-1")
+	p := e.plan()
+	if len(p.Create) != 1 || p.Create[0].Name != "test-master-1" {
+		t.Fatalf("plan should recreate test-master-1, got %+v", p.Create)
+	}
+	e.reconcile()
+
+	n := e.node("test-master-1")
+	if n.ID == old.ID {
+		t.Fatal("node was not recreated")
+	}
+	d, _ := dataDisk(n)
+	if d.ID != oldDisk.ID || d.MountPoint != "/data" {
+		t.Fatalf("data volume not reattached: got %+v, want %s", d, oldDisk.ID)
+	}
+	if v, _ := e.fake.Volume(oldDisk.ID); v.ServerID != n.ID || v.Status != "IN_USE" {
+		t.Fatalf("volume in cloud: %+v", v)
+	}
+}
+
+func TestReconcileRetriesServerInError(t *testing.T) {
+	e := newTestEnv(t, testConfig)
+	e.fake.FailBuild["test-master-1"] = 1
+	e.reconcile()
+
+	if len(e.fake.Servers()) != 3 {
+		t.Fatalf("got %d servers, the failed one must be deleted and recreated", len(e.fake.Servers()))
+	}
+	if s, _ := e.fake.ServerByName("test-master-1"); s.Status != "ACTIVE" {
+		t.Fatalf("test-master-1 is %s", s.Status)
+	}
+	if got := e.fake.CountCalls("DELETE", "/servers/"); got != 1 {
+		t.Fatalf("%d server deletes, want 1 for the ERROR build", got)
+	}
+	if len(e.state().Nodes) != 3 {
+		t.Fatal("recreated node missing from state")
+	}
+}
+
+func TestReconcileSurvivesAPIFaults(t *testing.T) {
+	e := newTestEnv(t, testConfig)
+	project := "/projects/" + clotest.ProjectID
+	e.fake.Fail("GET", project+"/servers", http.StatusServiceUnavailable, 2)
+	e.fake.Fail("GET", project+"/volumes", http.StatusInternalServerError, 2)
+	e.fake.Fail("POST", project+"/servers", http.StatusTooManyRequests, 1)
+	e.fake.Fail("GET", "/servers/", http.StatusBadGateway, 3)
+	e.reconcile()
+
+	if len(e.fake.Servers()) != 3 || len(e.state().Nodes) != 3 {
+		t.Fatalf("got %d servers, %d nodes in state", len(e.fake.Servers()), len(e.state().Nodes))
+	}
+	if got := e.fake.CountCalls("POST", project+"/servers"); got != 4 {
+		t.Fatalf("%d server POSTs, want 3 plus one retried 429", got)
+	}
+}
+
+func TestReconcileStopsOnQuota(t *testing.T) {
+	e := newTestEnv(t, testConfig)
+	e.fake.MaxServers = 1
+	e.reconcile()
+
+	if len(e.fake.Servers()) != 1 {
+		t.Fatalf("got %d servers, quota is 1", len(e.fake.Servers()))
+	}
+	// One POST per node: quota errors are not retried
+	if got := e.fake.CountCalls("POST", "/projects/"+clotest.ProjectID+"/servers"); got != 3 {
+		t.Fatalf("%d server POSTs, want 3", got)
+	}
+	if n := len(e.state().Nodes); n != 1 {
+		t.Fatalf("state has %d nodes, want only the created one", n)
+	}
+}
+
+func TestAttachDisksWaitsForSlowAttach(t *testing.T) {
+	e := newTestEnv(t, testConfig)
+	e.reconcile()
+	n := e.node("test-master-1")
+	d, _ := dataDisk(n)
+
+	e.fake.DetachVolume(d.ID)
+	e.fake.AttachPolls = 2
+	handleAttachDisks(t.Context(), e.client, e.backend, testCluster)
+
+	if got := e.fake.CountCalls("POST", "/volumes/"+d.ID+"/attach"); got != 1 {
+		t.Fatalf("%d attach calls, want 1", got)
+	}
+	v, _ := e.fake.Volume(d.ID)
+	if v.ServerID != n.ID {
+		t.Fatalf("volume attached to %q, want %s", v.ServerID, n.ID)
+	}
+	got, _ := dataDisk(e.node("test-master-1"))
+	if got.Device != v.Device {
+		t.Fatalf("state device %q, cloud device %q", got.Device, v.Device)
+	}
+
+	// While the attach is still in progress a second run must not attach again
+	handleAttachDisks(t.Context(), e.client, e.backend, testCluster)
+	if got := e.fake.CountCalls("POST", "/volumes/"+d.ID+"/attach"); got != 1 {
+		t.Fatalf("%d attach calls after second run, want 1", got)
+	}
+}
+
+func TestReconcileListsOncePerRun(t *testing.T) {
+	var instances string
+	for i := 1; i <= 8; i++ {
+		instances += fmt.Sprintf("      %d: {enabled: true}\n", i)
+	}
+	e := newTestEnv(t, strings.Replace(testConfig, "      1: {enabled: true}\n      2: {enabled: true, labels: {zone: b}}\n", instances, 1))
+	e.fake.PageSize = 3
+	e.reconcile()
+	if n := len(e.state().Nodes); n != 9 {
+		t.Fatalf("%d nodes in state, want 9", n)
+	}
+
+	vols, addrs := "/projects/"+clotest.ProjectID+"/volumes", "/projects/"+clotest.ProjectID+"/addresses"
+	before := e.fake.CountCalls("GET", vols) + e.fake.CountCalls("GET", addrs)
+	e.reconcile()
+	// One paged read of each list (6 pages of 17 volumes, 4 of 10 addresses), not one per node
+	if got := e.fake.CountCalls("GET", vols) + e.fake.CountCalls("GET", addrs) - before; got > 10 {
+		t.Fatalf("%d volume/address list calls in a no-op run", got)
+	}
+}
+
+func TestCreateLBIsIdempotent(t *testing.T) {
+	e := newTestEnv(t, testConfig)
+	e.reconcile()
+	handleCreateLB(t.Context(), e.client, e.backend, testCluster, e.config)
+
+	if got := e.fake.CountCalls("POST", "/projects/"+clotest.ProjectID+"/loadbalancers"); got != 1 {
+		t.Fatalf("%d load balancer creates, want 1", got)
+	}
+	if got := e.fake.CountCalls("PUT", "/loadbalancers/"); got != 0 {
+		t.Fatalf("%d load balancer updates of an up to date LB", got)
+	}
+}
+
+func TestLBFollowsReplacedNode(t *testing.T) {
+	e := newTestEnv(t, testConfig)
+	e.fake.AddExternalAddress()
+	e.reconcile()
+	lbIP := e.node("test-bastion-1").IP
+
+	e.fake.DeleteServerOutOfBand("test-bastion-1")
+	e.reconcile()
+
+	b, _ := e.fake.ServerByName("test-bastion-1")
+	lbs := e.fake.LoadBalancers()
+	if len(lbs) != 1 || len(lbs[0].Request.Rules) != 1 || lbs[0].Request.Rules[0].AddrID != b.Addresses[0] {
+		t.Fatalf("LB does not target the new bastion address %s: %+v", b.Addresses[0], lbs)
+	}
+	if n := e.node("test-bastion-1"); n.IP != lbIP || n.SSHPort != 2222 {
+		t.Fatalf("new bastion should be reached through the LB at %s:2222, got %s:%d", lbIP, n.IP, n.SSHPort)
+	}
+}
+
+func TestLBSettingsAreUpdatedInPlace(t *testing.T) {
+	e := newTestEnv(t, testConfig)
+	e.reconcile()
+	lbID := e.fake.LoadBalancers()[0].ID
+
+	changed := strings.Replace(testConfig, "      - {ext_port: 2222, int_port: 22}\n", `      - {ext_port: 2222, int_port: 22}
+      - {ext_port: 8080, int_port: 80}
+    lb_algorithm: LEAST_CONNECTIONS
+    lb_health_check: {type: TCP, delay: 30, timeout: 10}
+`, 1)
+	os.WriteFile(e.config, []byte(changed), 0644)
+
+	p := e.plan()
+	if p.LB == nil || p.LB.Action != "update" || len(p.LB.Changes) != 3 {
+		t.Fatalf("plan: %+v", p.LB)
+	}
+	e.reconcile()
+
+	lbs := e.fake.LoadBalancers()
+	if len(lbs) != 1 || lbs[0].ID != lbID {
+		t.Fatalf("LB was not updated in place: %+v", lbs)
+	}
+	req := lbs[0].Request
+	if req.Algorithm != "LEAST_CONNECTIONS" || req.HealthMonitor.Delay != 30 || req.HealthMonitor.MaxRetries != 5 || len(req.Rules) != 2 {
+		t.Fatalf("LB settings: %+v", req)
+	}
+	if p := e.plan(); p.LB.Action != "none" {
+		t.Fatalf("LB still differs after the update: %+v", p.LB)
+	}
+}
+
+func TestLBWithoutRulesIsDeletedWithDelnodes(t *testing.T) {
+	e := newTestEnv(t, testConfig)
+	e.reconcile()
+	os.WriteFile(e.config, []byte(strings.Replace(testConfig, "      - {ext_port: 2222, int_port: 22}\n", "", 1)), 0644)
+	cfg, _ := GetClusterConfig(e.config)
+
+	p, err := computePlan(t.Context(), e.client, e.backend, cfg, testCluster, false, false)
+	if err != nil || p.LB == nil || p.LB.Action != "delete" || p.HasChanges() {
+		t.Fatalf("without -delnodes the LB is only reported: %+v, %v", p.LB, err)
+	}
+	p, _ = computePlan(t.Context(), e.client, e.backend, cfg, testCluster, false, true)
+	executePlan(t.Context(), e.client, e.backend, p, "", "secret", true, false)
+	if n := len(e.fake.LoadBalancers()); n != 0 {
+		t.Fatalf("%d load balancers left", n)
+	}
+}
+
+func TestCleanAllKeepsExternalAddresses(t *testing.T) {
+	e := newTestEnv(t, testConfig)
+	e.reconcile()
+	reserved := e.fake.AddExternalAddress()
+
+	withStdin(t, "yes\n")
+	handleCleanAll(t.Context(), e.client)
+
+	if n := len(e.fake.Servers()); n != 0 {
+		t.Fatalf("%d servers left", n)
+	}
+	if n := len(e.fake.LoadBalancers()); n != 0 {
+		t.Fatalf("%d load balancers left", n)
+	}
+	if n := len(e.fake.Volumes()); n != 0 {
+		t.Fatalf("%d volumes left", n)
+	}
+	kept := false
+	for _, a := range e.fake.Addresses() {
+		if !a.External {
+			t.Errorf("private address %s left", a.ID)
+		}
+		kept = kept || a.ID == reserved
+	}
+	if !kept {
+		t.Fatal("reserved external address was deleted")
+	}
+}
+
+func TestNoDriftAfterReconcile(t *testing.T) {
+	e := newTestEnv(t, testConfig)
+	e.reconcile()
+
+	cfg, _ := GetClusterConfig(e.config)
+	live, err := fetchLiveInventory(t.Context(), e.client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if items := computeDrift(cfg, testCluster, e.state(), live); len(items) != 0 {
+		t.Fatalf("unexpected drift: %+v", items)
+	}
+}
+
+func TestInstanceOverridesAreReconciled(t *testing.T) {
+	e := newTestEnv(t, testConfig)
+	static := e.fake.AddExternalAddress()
+	cfg := testConfig + `  - name_prefix: web
+    role: worker
+    instances:
+      1:
+        enabled: true
+        flavor: {ram: 8}
+        disks: [{size: 100, mount_point: /data}]
+        external_ip: true
+        static_ip: ` + static + `
+        taints: []
+        lb_rules: [{ext_port: 80, int_port: 8080}]
+      2: {enabled: true}
+    flavor: {ram: 2, vcpus: 1}
+    disks:
+      - {size: 10, bootable: true}
+      - {size: 30, mount_point: /data}
+    taints: [edge=true:NoSchedule]
+    lb_rules: [{ext_port: 443, int_port: 8443}]
+`
+	os.WriteFile(e.config, []byte(cfg), 0644)
+	e.reconcile()
+
+	one, _ := e.fake.ServerByName("test-web-1")
+	two, _ := e.fake.ServerByName("test-web-2")
+	if one.RAM != 8 || one.VCPUs != 1 || two.RAM != 2 {
+		t.Errorf("flavors: web-1 %dGB/%d, web-2 %dGB", one.RAM, one.VCPUs, two.RAM)
+	}
+	if d, _ := dataDisk(e.node("test-web-1")); d.Size != 100 {
+		t.Errorf("web-1 data disk %+v", d)
+	}
+	if d, _ := dataDisk(e.node("test-web-2")); d.Size != 30 {
+		t.Errorf("web-2 data disk %+v", d)
+	}
+	if n1, n2 := e.node("test-web-1"), e.node("test-web-2"); len(n1.Taints) != 0 || len(n2.Taints) != 1 {
+		t.Errorf("taints: web-1 %v, web-2 %v", n1.Taints, n2.Taints)
+	}
+
+	owner := make(map[string]string)
+	for _, a := range e.fake.Addresses() {
+		owner[a.ID] = a.ServerID
+	}
+	if owner[static] != one.ID {
+		t.Errorf("static ip %s is on %q, want web-1", static, owner[static])
+	}
+	lbs := e.fake.LoadBalancers()
+	if len(lbs) != 1 {
+		t.Fatalf("load balancers: %+v", lbs)
+	}
+	targets := make(map[int][]string)
+	for _, r := range lbs[0].Request.Rules {
+		targets[r.ExtPort] = append(targets[r.ExtPort], owner[r.AddrID])
+	}
+	if !slices.Equal(targets[80], []string{one.ID}) || !slices.Equal(targets[443], []string{two.ID}) {
+		t.Errorf("lb targets: %v", targets)
+	}
+
+	if p := e.plan(); p.HasChanges() || len(p.Resize) != 0 || len(p.Grow) != 0 {
+		t.Fatalf("overrides do not converge: %+v", p)
+	}
+}
+
+func TestInterruptedReconcileKeepsStateAndLeavesNoOrphans(t *testing.T) {
+	e := newTestEnv(t, testConfig)
+	e.reconcile()
+	os.WriteFile(e.config, []byte(strings.Replace(testConfig, "      2: {enabled: true, labels: {zone: b}}", "      2: {enabled: true, labels: {zone: b}}\n      3: {enabled: true}", 1)), 0644)
+	e.fake.BuildPolls = 1 << 20
+
+	ctx, cancel := context.WithCancel(clo.WithListCache(t.Context()))
+	done := make(chan error)
+	go func() {
+		done <- handleClusterCreateFromCode(ctx, e.client, "", e.backend, testCluster, false, "secret", e.config, false, true)
+	}()
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(time.Millisecond) {
+		if _, ok := e.fake.ServerByName("test-master-3"); ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("test-master-3 was never created")
+		}
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := e.fake.ServerByName("test-master-3"); ok {
+		t.Error("half-built server left behind")
+	}
+	st := e.state()
+	if len(st.Nodes) != 3 || e.node("test-master-1").ID == "" {
+		t.Fatalf("state after the interrupt: %+v", st.Nodes)
+	}
+}
+
+func TestConcurrentSaveIsMergedPerNode(t *testing.T) {
+	disk := func(id string) []state.DiskState { return []state.DiskState{{ID: id, Size: 50}} }
+	remote := []state.NodeState{
+		{Name: "c-db-1", ID: "s1", Disks: []state.DiskState{{ID: "v1", Size: 50, Critical: true, Owner: "postgres"}}, SSHPort: 2201},
+		{Name: "c-db-2", ID: "s2-new", Disks: disk("v2")},
+		{Name: "c-web-1", ID: "s3", Disks: disk("v3")},
+		{Name: "c-old-1", ID: "s4"},
+		{Name: "c-added-1", ID: "s5"},
+	}
+	local := []state.NodeState{
+		{Name: "c-db-1", ID: "s1", IP: "10.0.0.9", Disks: []state.DiskState{{ID: "v1", Size: 80}}},
+		{Name: "c-db-2", ID: "s2", Disks: disk("v2")},
+		{Name: "c-web-1", ID: "s3-new", Disks: disk("v3")},
+	}
+	results := []NodeResult{{Name: "c-db-1"}, {Name: "c-db-2"}, {Name: "c-web-1", IsNew: true}}
+
+	got := mergeNodes(remote, local, results, []string{"c-old-1"})
+	byName := make(map[string]state.NodeState)
+	for _, n := range got {
+		byName[n.Name] = n
+	}
+	if len(got) != 4 || byName["c-added-1"].ID != "s5" {
+		t.Fatalf("nodes of the other run lost or dropped ones kept: %+v", got)
+	}
+	db1 := byName["c-db-1"]
+	if d := db1.Disks[0]; db1.IP != "10.0.0.9" || d.Size != 80 || !d.Critical || d.Owner != "postgres" || db1.SSHPort != 2201 {
+		t.Errorf("db-1 must take this run's fields and keep the other's: %+v", db1)
+	}
+	if byName["c-db-2"].ID != "s2-new" {
+		t.Errorf("a node replaced by the other run was overwritten: %+v", byName["c-db-2"])
+	}
+	if byName["c-web-1"].ID != "s3-new" {
+		t.Errorf("a node created by this run was lost: %+v", byName["c-web-1"])
+	}
+}
+
+func TestApplyCarriesOutOnlyThePlan(t *testing.T) {
+	e := newTestEnv(t, testConfig)
+	e.fake.SecurityGroupsEnabled = true
+	e.reconcile()
+	d, _ := dataDisk(e.node("test-master-1"))
+	if err := e.client.UpdateVolume(t.Context(), d.ID, "legacy", nil); err != nil {
+		t.Fatal(err)
+	}
+	e.fake.DeleteServerOutOfBand("test-bastion-1")
+	os.WriteFile(e.config, []byte(firewallConfig), 0644)
+	planFile := filepath.Join(t.TempDir(), "plan.json")
+	handlePlan(t.Context(), e.client, e.backend, testCluster, e.config, false, false, planFile)
+
+	if err := handleApply(clo.WithListCache(t.Context()), e.client, e.backend, testCluster, planFile, "", "secret", true); err != nil {
+		t.Fatal(err)
+	}
+	if e.node("test-bastion-1").ID == "" {
+		t.Fatal("planned node not created")
+	}
+	if v, _ := e.fake.Volume(d.ID); v.Name != "legacy" || len(e.fake.SecurityGroups()) != 0 || e.fake.CountCalls("PUT", "/loadbalancers/") != 0 {
+		t.Fatalf("unplanned changes applied: volume %s, security groups %+v", v.Name, e.fake.SecurityGroups())
+	}
+
+	e.reconcile()
+	if v, _ := e.fake.Volume(d.ID); v.Name != "test-master-1-data" || len(e.fake.SecurityGroups()) != 1 {
+		t.Fatalf("-cluster must reconcile the rest: volume %s, security groups %+v", v.Name, e.fake.SecurityGroups())
+	}
+}
+
+func TestSyncDropsOnlyMissingServers(t *testing.T) {
+	e := newTestEnv(t, testConfig)
+	e.reconcile()
+	m1 := e.node("test-master-1").ID
+
+	e.fake.Fail("GET", "/servers/"+m1+"/detail", http.StatusForbidden, 1)
+	withStdin(t, "yes\n")
+	handleSync(t.Context(), e.client, e.backend, testCluster)
+	if len(e.state().Nodes) != 3 {
+		t.Fatalf("an API error dropped nodes: %+v", e.state().Nodes)
+	}
+
+	e.fake.DeleteServerOutOfBand("test-master-1")
+	withStdin(t, "yes\n")
+	handleSync(t.Context(), e.client, e.backend, testCluster)
+	if st := e.state(); len(st.Nodes) != 2 || slices.ContainsFunc(st.Nodes, func(n state.NodeState) bool { return n.ID == m1 }) {
+		t.Fatalf("deleted server kept: %+v", st.Nodes)
+	}
+}
+
+func TestPlanFailsInsteadOfRecreatingOnAPIErrors(t *testing.T) {
+	e := newTestEnv(t, testConfig)
+	e.reconcile()
+	cfg, _ := GetClusterConfig(e.config)
+
+	e.fake.Fail("GET", "/servers/"+e.node("test-master-1").ID+"/detail", http.StatusForbidden, 1)
+	if p, err := computePlan(t.Context(), e.client, e.backend, cfg, testCluster, false, false); err == nil {
+		t.Fatalf("detail error planned as a lost node: %+v", p.Create)
+	}
+	e.fake.Fail("GET", "/projects/"+clotest.ProjectID+"/servers", http.StatusForbidden, 1)
+	if p, err := computePlan(t.Context(), e.client, e.backend, cfg, testCluster, false, false); err == nil {
+		t.Fatalf("server list error ignored: %+v", p.Adopt)
+	}
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+	if _, err := computePlan(ctx, e.client, e.backend, cfg, testCluster, false, false); err == nil {
+		t.Fatal("interrupted plan returned")
+	}
+	if p := e.plan(); p.HasChanges() {
+		t.Fatalf("plan after the errors: %+v", p)
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"cli/internal/clo"
+	"cli/internal/state"
+)
+
+// drainer moves the pods off a Kubernetes node and lets them back
+type drainer interface {
+	// Drain cordons the node and evicts its pods
+	Drain(ctx context.Context, name string) error
+	// Uncordon makes the node schedulable again
+	Uncordon(ctx context.Context, name string) error
+}
+
+// newDrainer connects to the cluster for the drain steps of an in-place resize; tests replace it
+var newDrainer = func(backend state.StateStore, inventoryPath string) (drainer, func(), error) {
+	st, err := backend.LoadState()
+	if err != nil || st == nil {
+		return nil, nil, fmt.Errorf("state: %v", err)
+	}
+	ops, err := newKubeOps(st, backend, inventoryPath, 1)
+	if err != nil {
+		return nil, nil, err
+	}
+	return ops, ops.Close, nil
+}
+
+// resizeNodes resizes the planned nodes in place one at a time and stops on the first failure.
+// groups holds the group of each node by node name. Support is checked before any node is drained:
+// if the API cannot resize, the nodes are left for replacement with -roll.
+func resizeNodes(ctx context.Context, client *clo.Client, backend state.StateStore, groups map[string]NodeGroup, items []PlanResize, inventoryPath string) {
+	if i := slices.IndexFunc(items, func(r PlanResize) bool { return r.Action == "resize" }); i >= 0 {
+		r := items[i]
+		ok, err := client.CanResize(ctx, r.ID, clo.ServerFlavor{RAM: r.From.RAM, VCPUs: r.From.VCPUs, CPUType: groups[r.Name].CPUType})
+		if err != nil {
+			fmt.Printf("[ERROR] [%s] Resize check failed: %v\n", r.Name, err)
+			fmt.Println("   [INFO] Resizes skipped, run -cluster again.")
+			return
+		}
+		if !ok {
+			fmt.Println("[WARNING] The project cannot resize servers, nothing was drained or stopped.")
+			printReplaceHints(items)
+			return
+		}
+	}
+
+	var k8s drainer
+	connected := false
+	for i, r := range items {
+		if r.Action != "resize" {
+			continue
+		}
+		var d drainer
+		if r.Role != "BASTION" {
+			if !connected {
+				connected = true
+				var closeFn func()
+				var err error
+				if k8s, closeFn, err = newDrainer(backend, inventoryPath); err != nil {
+					fmt.Printf("[WARNING] Cannot reach Kubernetes to drain nodes: %v\n", err)
+				} else {
+					defer closeFn()
+				}
+			}
+			if k8s == nil {
+				fmt.Printf("[WARNING] [%s] Not resized: it cannot be drained. Deploy the cluster first or run -roll %s.\n", r.Name, r.Group)
+				continue
+			}
+			d = k8s
+		}
+
+		err := resizeNode(ctx, client, groups[r.Name], r, d)
+		if err == nil {
+			fmt.Printf("[+OK+] [%s] Resized to %s.\n", r.Name, r.To)
+			continue
+		}
+		if clo.IsUnsupported(err) {
+			fmt.Printf("[WARNING] The project cannot resize servers: %v\n", err)
+			printReplaceHints(items[i:])
+			return
+		}
+		fmt.Printf("[ERROR] [%s] Resize failed: %v\n", r.Name, err)
+		fmt.Println("   [INFO] Remaining resizes skipped, run -cluster again once the node is healthy.")
+		return
+	}
+}
+
+// printReplaceHints tells how to apply the planned resizes the API refused
+func printReplaceHints(items []PlanResize) {
+	for _, r := range items {
+		if r.Action == "resize" {
+			fmt.Printf("   [INFO] %s needs replacement: run -roll %s\n", r.Name, r.Group)
+		}
+	}
+}
+
+// resizeNode drains the node (d is nil for the bastion), stops the server, changes its flavor,
+// starts it and uncordons the node. A stopped server is started again even if the resize fails,
+// and a drained node is uncordoned on every exit; an uncordon error is joined to the returned one.
+func resizeNode(ctx context.Context, client *clo.Client, group NodeGroup, r PlanResize, d drainer) (err error) {
+	fmt.Printf("\n[REFRESH] Resizing %s: %s -> %s...\n", r.Name, r.From, r.To)
+	if d != nil {
+		fmt.Printf("   [1/5] Cordon and drain...\n")
+		if err := d.Drain(ctx, r.Name); err != nil {
+			if uerr := d.Uncordon(context.WithoutCancel(ctx), r.Name); uerr != nil {
+				return errors.Join(fmt.Errorf("drain: %w", err), fmt.Errorf("uncordon: %w", uerr))
+			}
+			return fmt.Errorf("drain: %w", err)
+		}
+		defer func() {
+			fmt.Printf("   [5/5] Uncordon...\n")
+			// Even an interrupted run must not leave the node cordoned
+			if uerr := d.Uncordon(context.WithoutCancel(ctx), r.Name); uerr != nil {
+				err = errors.Join(err, fmt.Errorf("uncordon: %w", uerr))
+			}
+		}()
+	}
+
+	fmt.Printf("   [2/5] Stopping server %s...\n", r.ID)
+	if err := client.StopServer(ctx, r.ID); err != nil {
+		return fmt.Errorf("stop: %w", err)
+	}
+	if _, _, _, err := client.WaitForStatus(ctx, r.ID, []string{"STOPPED"}, 60, statusPollInterval); err != nil {
+		return fmt.Errorf("stop: %w", err)
+	}
+
+	fmt.Printf("   [3/5] Resizing...\n")
+	resizeErr := client.ResizeServer(ctx, r.ID, clo.ServerFlavor{RAM: r.To.RAM, VCPUs: r.To.VCPUs, CPUType: group.CPUType})
+
+	fmt.Printf("   [4/5] Starting...\n")
+	if err := client.StartServer(ctx, r.ID); err != nil {
+		return fmt.Errorf("start: %w", err)
+	}
+	if _, _, _, err := client.WaitForStatus(ctx, r.ID, []string{"ACTIVE", "RUNNING"}, 60, statusPollInterval); err != nil {
+		return fmt.Errorf("start: %w", err)
+	}
+	return resizeErr
+}
+
+func (o *kubeOps) Uncordon(ctx context.Context, name string) error {
+	return setNodeUnschedulable(ctx, o.k8s, name, false)
+}
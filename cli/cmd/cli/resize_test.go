@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+
+	"cli/internal/state"
+)
+
+// masterResize is testConfig with a bigger master flavor
+var masterResize = strings.Replace(testConfig, "flavor: {ram: 4, vcpus: 2}", "flavor: {ram: 8, vcpus: 4}", 1)
+
+// useDrainer makes in-place resizes drain through ops
+func useDrainer(t *testing.T, ops *fakeOps) {
+	saved := newDrainer
+	newDrainer = func(state.StateStore, string) (drainer, func(), error) { return ops, func() {}, nil }
+	t.Cleanup(func() { newDrainer = saved })
+}
+
+func TestResizeIsPlannedPerGroupOptIn(t *testing.T) {
+	e := newTestEnv(t, testConfig)
+	e.reconcile()
+
+	os.WriteFile(e.config, []byte(masterResize), 0644)
+	p := e.plan()
+	if len(p.Resize) != 2 || p.HasChanges() {
+		t.Fatalf("want 2 replacements and no changes without opt-in, got %+v", p.Resize)
+	}
+	for _, r := range p.Resize {
+		if r.Action != "replace" || r.From != (Flavor{4, 2}) || r.To != (Flavor{8, 4}) || r.Group != "master" {
+			t.Errorf("unexpected resize %+v", r)
+		}
+	}
+
+	os.WriteFile(e.config, []byte(strings.Replace(masterResize, "cpu_type: DEDICATED", "cpu_type: DEDICATED\n    resize_in_place: true", 1)), 0644)
+	p = e.plan()
+	if len(p.Resize) != 2 || p.Resize[0].Action != "resize" || !p.HasChanges() {
+		t.Fatalf("want 2 in-place resizes, got %+v", p.Resize)
+	}
+	if len(p.Keep) != 3 || len(p.Create) != 0 {
+		t.Errorf("resized nodes must be kept, got keep %d create %d", len(p.Keep), len(p.Create))
+	}
+}
+
+func TestResizeInPlace(t *testing.T) {
+	e := newTestEnv(t, testConfig)
+	e.fake.ResizeEnabled = true
+	e.reconcile()
+	ids := map[string]string{}
+	for _, srv := range e.fake.Servers() {
+		ids[srv.Name] = srv.ID
+	}
+
+	ops := &fakeOps{}
+	useDrainer(t, ops)
+	os.WriteFile(e.config, []byte(strings.Replace(masterResize, "cpu_type: DEDICATED", "cpu_type: DEDICATED\n    resize_in_place: true", 1)), 0644)
+	e.reconcile()
+
+	want := []string{"drain test-master-1", "uncordon test-master-1", "drain test-master-2", "uncordon test-master-2"}
+	if !slices.Equal(ops.calls, want) {
+		t.Errorf("k8s steps %v, want %v", ops.calls, want)
+	}
+	for _, srv := range e.fake.Servers() {
+		if srv.ID != ids[srv.Name] || srv.Status != "ACTIVE" {
+			t.Errorf("%s: server replaced or not running: %+v", srv.Name, srv)
+		}
+		if strings.Contains(srv.Name, "master") && (srv.RAM != 8 || srv.VCPUs != 4 || srv.CPUType != "DEDICATED") {
+			t.Errorf("%s not resized: %dGB/%d %s", srv.Name, srv.RAM, srv.VCPUs, srv.CPUType)
+		}
+	}
+	if n := e.fake.CountCalls("POST", "/servers/"+ids["test-bastion-1"]+"/stop"); n != 0 {
+		t.Errorf("bastion touched: %d calls", n)
+	}
+	if p := e.plan(); len(p.Resize) != 0 || p.HasChanges() {
+		t.Errorf("resize not converged: %+v", p.Resize)
+	}
+}
+
+func TestResizeFallsBackWhenUnsupported(t *testing.T) {
+	e := newTestEnv(t, testConfig)
+	e.reconcile()
+
+	m1, m2 := e.node("test-master-1").ID, e.node("test-master-2").ID
+
+	ops := &fakeOps{}
+	useDrainer(t, ops)
+	os.WriteFile(e.config, []byte(strings.Replace(masterResize, "cpu_type: DEDICATED", "cpu_type: DEDICATED\n    resize_in_place: true", 1)), 0644)
+	e.reconcile()
+
+	if e.fake.CountCalls("POST", "/servers/"+m1+"/resize") != 1 || e.fake.CountCalls("POST", "/servers/"+m1+"/stop") != 0 || e.fake.CountCalls("POST", "/servers/"+m2+"/stop") != 0 {
+		t.Errorf("want one resize check and no node stopped: %v", e.fake.Calls())
+	}
+	if len(ops.calls) != 0 {
+		t.Errorf("k8s steps %v, no node may be drained before resize is known to work", ops.calls)
+	}
+	for _, srv := range e.fake.Servers() {
+		if srv.Status != "ACTIVE" || (strings.Contains(srv.Name, "master") && srv.RAM != 4) {
+			t.Errorf("%s: %s %dGB", srv.Name, srv.Status, srv.RAM)
+		}
+	}
+	if p := e.plan(); len(p.Resize) != 2 {
+		t.Errorf("nodes must stay planned for resize, got %+v", p.Resize)
+	}
+}
+
+func TestResizeUncordonsOnEveryExit(t *testing.T) {
+	e := newTestEnv(t, testConfig)
+	e.fake.ResizeEnabled = true
+	e.reconcile()
+	n := e.node("test-master-1")
+	r := PlanResize{Name: n.Name, Group: "master", Role: n.Role, ID: n.ID, From: Flavor{4, 2}, To: Flavor{8, 4}, Action: "resize"}
+	want := []string{"drain test-master-1", "uncordon test-master-1"}
+
+	// The stop fails after the drain, and so does the uncordon: both are reported
+	e.fake.Fail("POST", "/servers/"+n.ID+"/stop", http.StatusForbidden, 1)
+	ops := &fakeOps{fail: map[string]error{"uncordon test-master-1": fmt.Errorf("api down")}}
+	err := resizeNode(t.Context(), e.client, NodeGroup{CPUType: "DEDICATED"}, r, ops)
+	if err == nil || !strings.Contains(err.Error(), "stop:") || !strings.Contains(err.Error(), "uncordon: api down") {
+		t.Fatalf("want the stop and uncordon errors, got %v", err)
+	}
+	if !slices.Equal(ops.calls, want) {
+		t.Fatalf("k8s steps %v, want %v", ops.calls, want)
+	}
+
+	ops = &fakeOps{}
+	if err := resizeNode(t.Context(), e.client, NodeGroup{CPUType: "DEDICATED"}, r, ops); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(ops.calls, want) {
+		t.Fatalf("k8s steps %v, want %v", ops.calls, want)
+	}
+}
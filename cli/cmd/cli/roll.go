@@ -0,0 +1,352 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"cli/internal/clo"
+	"cli/internal/local"
+	"cli/internal/state"
+)
+
+// Timeouts of the Kubernetes steps of -roll
+var (
+	drainTimeout     = 15 * time.Minute
+	nodeReadyTimeout = 20 * time.Minute
+	k8sPollInterval  = 10 * time.Second
+)
+
+// nodeOps are the Kubernetes and kubespray steps of a rolling replacement
+type nodeOps interface {
+	// Unavailable returns the nodes among names that are not Ready, cordoned or not registered
+	Unavailable(ctx context.Context, names []string) ([]string, error)
+	// Drain cordons the node and evicts its pods
+	Drain(ctx context.Context, name string) error
+	// RemoveNode runs kubespray remove-node.yml for the node
+	RemoveNode(ctx context.Context, name string) error
+	// Join runs kubespray scale.yml for the node
+	Join(ctx context.Context, name string) error
+	// WaitReady waits until the node is Ready
+	WaitReady(ctx context.Context, name string) error
+}
+
+func handleRoll(ctx context.Context, client *clo.Client, backend state.StateStore, clusterName, configPath, inventoryPath, groupName string, maxUnavailable, forks int) error {
+	cfg, err := GetClusterConfig(configPath)
+	if err != nil {
+		return fmt.Errorf("configuration error: %w", err)
+	}
+	st, err := backend.LoadState()
+	if err != nil || st == nil {
+		return fmt.Errorf("state error: %w", err)
+	}
+	nodes := groupNodes(st, clusterName, groupName)
+	if !askForConfirmation(fmt.Sprintf("[WARNING] Replace %d node(s) of group '%s' one by one?", len(nodes), groupName)) {
+		return nil
+	}
+
+	ops, err := newKubeOps(st, backend, inventoryPath, forks)
+	if err != nil {
+		return fmt.Errorf("cluster connection error: %w", err)
+	}
+	defer ops.Close()
+
+	if err := rollGroup(ctx, client, backend, cfg, clusterName, groupName, maxUnavailable, ops); err != nil {
+		return fmt.Errorf("roll stopped: %w\n   [INFO] Fix the cause: -cluster recreates a deleted node, -deploy -l <node> joins it to Kubernetes", err)
+	}
+	return nil
+}
+
+// groupNodes returns the state nodes of a group, sorted by name
+func groupNodes(st *state.ClusterState, clusterName, groupName string) []state.NodeState {
+	var nodes []state.NodeState
+	index := make(map[string]int)
+	for _, n := range st.Nodes {
+		if i, ok := nodeIndex(clusterName, groupName, n.Name); ok {
+			nodes = append(nodes, n)
+			index[n.Name] = i
+		}
+	}
+	sort.Slice(nodes, func(i, j int) bool { return index[nodes[i].Name] < index[nodes[j].Name] })
+	return nodes
+}
+
+// rollGroup replaces the nodes of a group one at a time and stops on the first failure.
+// A node is only taken down while fewer than maxUnavailable nodes of the group are unavailable.
+func rollGroup(ctx context.Context, client *clo.Client, backend state.StateStore, cfg *Config, clusterName, groupName string, maxUnavailable int, ops nodeOps) error {
+	var group *NodeGroup
+	for i := range cfg.Groups {
+		if cfg.Groups[i].NamePrefix == groupName {
+			group = &cfg.Groups[i]
+		}
+	}
+	switch {
+	case group == nil:
+		return fmt.Errorf("group '%s' is not in the config", groupName)
+	case group.Role == "BASTION":
+		return fmt.Errorf("group '%s' is the bastion, it is not a Kubernetes node", groupName)
+	case group.Role == "master":
+		return fmt.Errorf("group '%s' is the control plane: scale.yml cannot join masters, replace them with -cluster and -deploy", groupName)
+	}
+	if maxUnavailable < 1 {
+		maxUnavailable = 1
+	}
+
+	st, err := backend.LoadState()
+	if err != nil || st == nil {
+		return fmt.Errorf("state: %v", err)
+	}
+	nodes := groupNodes(st, clusterName, groupName)
+	if len(nodes) == 0 {
+		return fmt.Errorf("group '%s' has no nodes in the state", groupName)
+	}
+
+	fmt.Printf("[REFRESH] Rolling %d node(s) of group '%s' (max unavailable: %d)...\n", len(nodes), groupName, maxUnavailable)
+	for i, n := range nodes {
+		var others []string
+		for _, o := range nodes {
+			if o.Name != n.Name {
+				others = append(others, o.Name)
+			}
+		}
+		down, err := ops.Unavailable(ctx, others)
+		if err != nil {
+			return fmt.Errorf("node status: %w", err)
+		}
+		if len(down)+1 > maxUnavailable {
+			return fmt.Errorf("%s: %d other node(s) of the group are unavailable %v, max unavailable is %d", n.Name, len(down), down, maxUnavailable)
+		}
+
+		fmt.Printf("\n[REFRESH] [%d/%d] Replacing %s...\n", i+1, len(nodes), n.Name)
+		if err := rollNode(ctx, client, backend, cfg, clusterName, *group, n, ops); err != nil {
+			return fmt.Errorf("%s: %w", n.Name, err)
+		}
+		fmt.Printf("[+OK+] [%s] Replaced and Ready.\n", n.Name)
+	}
+	fmt.Printf("\n[CELEBRATION] Group '%s' rolled.\n", groupName)
+	return nil
+}
+
+// rollNode drains a node, removes it from Kubernetes, recreates its server with the current
+// group settings and its data disks, and joins it back
+func rollNode(ctx context.Context, client *clo.Client, backend state.StateStore, cfg *Config, clusterName string, group NodeGroup, n state.NodeState, ops nodeOps) error {
+	fmt.Printf("   [1/6] Cordon and drain...\n")
+	if err := ops.Drain(ctx, n.Name); err != nil {
+		return fmt.Errorf("drain: %w", err)
+	}
+	fmt.Printf("   [2/6] kubespray remove-node...\n")
+	if err := ops.RemoveNode(ctx, n.Name); err != nil {
+		return fmt.Errorf("remove-node: %w", err)
+	}
+
+	fmt.Printf("   [3/6] Deleting server %s (data disks are kept)...\n", n.ID)
+	if err := handleDelete(ctx, client, n.ID); err != nil {
+		return fmt.Errorf("delete server: %w", err)
+	}
+	// Until the delete finishes the server still holds its name and disks, and the plan would keep it
+	if err := client.WaitForDeleted(ctx, n.ID, 60, statusPollInterval); err != nil {
+		return fmt.Errorf("delete server: %w", err)
+	}
+	for _, d := range n.Disks {
+		if d.Bootable {
+			continue
+		}
+		if err := client.WaitForVolumeStatus(ctx, d.ID, "AVAILABLE", 60, statusPollInterval); err != nil {
+			return fmt.Errorf("data disk %s was not released: %w", d.ID, err)
+		}
+	}
+
+	fmt.Printf("   [4/6] Recreating the server...\n")
+	plan, err := computePlan(ctx, client, backend, cfg, clusterName, false, false)
+	if err != nil {
+		return err
+	}
+	var item *PlanNode
+	for i := range plan.Create {
+		if plan.Create[i].Name == n.Name {
+			item = &plan.Create[i]
+		}
+	}
+	if item == nil {
+		return fmt.Errorf("the plan does not recreate the node")
+	}
+	// The plan carries the group with image and keypair names resolved to IDs
+	if g, ok := plan.Config.GroupOf(clusterName, n.Name); ok {
+		group = g
+	}
+	password, _ := local.LoadPassword(clusterName)
+	if password == "" {
+		password, _ = clo.GenerateRandomPassword()
+	}
+	results := make(chan NodeResult, 1)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	createNodeAsync(ctx, &wg, client, item.Name, group, item.OldDisks, item.NewDisks, item.Labels, password, results)
+	res := <-results
+	if res.Err != nil {
+		return fmt.Errorf("recreate: %w", res.Err)
+	}
+	fmt.Printf("   [+OK+] [%s] Created (%s)\n", res.Name, res.IP)
+
+	err = backend.UpdateState(func(st *state.ClusterState) error {
+		now := time.Now().Format(time.RFC3339)
+		for i := range st.Nodes {
+			if st.Nodes[i].Name == n.Name {
+				st.Nodes[i] = state.NodeState{
+					Name: res.Name, Role: res.Role, ID: res.ID, IP: res.IP, SSHPort: n.SSHPort, AddressID: res.AddressID,
+					Labels: res.Labels, Taints: res.Taints, Disks: res.Disks, Created: res.Created, Updated: now,
+				}
+			}
+		}
+		st.LastUpdated = time.Now()
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("save state: %w", err)
+	}
+	if len(group.LBRules) > 0 {
+		reconcileLB(ctx, client, backend, clusterName, cfg)
+	}
+	reconcileFirewalls(ctx, client, clusterName, cfg, []NodeResult{res})
+	tagVolumes(ctx, client, clusterName, []NodeResult{res})
+
+	fmt.Printf("   [5/6] kubespray scale...\n")
+	if err := ops.Join(ctx, n.Name); err != nil {
+		return fmt.Errorf("scale: %w", err)
+	}
+	fmt.Printf("   [6/6] Waiting for Ready...\n")
+	if err := ops.WaitReady(ctx, n.Name); err != nil {
+		return err
+	}
+	return nil
+}
+
+// kubeOps runs the steps over SSH to the bastion: client-go through the tunnel, kubespray through the runner
+type kubeOps struct {
+	ssh           *ssh.Client
+	k8s           kubernetes.Interface
+	backend       state.StateStore
+	keyPath       string
+	inventoryPath string
+	envVars       string
+	forks         int
+}
+
+func newKubeOps(st *state.ClusterState, backend state.StateStore, inventoryPath string, forks int) (*kubeOps, error) {
+	var bastionIP, masterIP string
+	bastionPort := 22
+	for _, n := range st.Nodes {
+		if n.Role == "BASTION" {
+			bastionIP = n.IP
+			if n.SSHPort != 0 {
+				bastionPort = n.SSHPort
+			}
+		}
+		if n.Role == "master" && masterIP == "" {
+			masterIP = n.IP
+		}
+	}
+	if bastionIP == "" || masterIP == "" {
+		return nil, fmt.Errorf("bastion or master missing in the state")
+	}
+
+	keyPath := os.ExpandEnv("${HOME}/.ssh/clo")
+	if inventoryPath == "" {
+		inventoryPath = "inventory.gen.yaml"
+	}
+	sshConfig := &ssh.ClientConfig{
+		User:            st.SSHUser,
+		Auth:            getAuthMethods("", keyPath),
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+		Timeout:         30 * time.Second,
+	}
+	sshClient, err := ssh.Dial("tcp", net.JoinHostPort(bastionIP, fmt.Sprintf("%d", bastionPort)), sshConfig)
+	if err != nil {
+		return nil, fmt.Errorf("ssh dial: %w", err)
+	}
+	cmd := fmt.Sprintf("ssh -o StrictHostKeyChecking=no -i /root/.ssh/id_rsa root@%s cat /etc/kubernetes/admin.conf", masterIP)
+	kubeconfig, err := runCommandOutput(sshClient, cmd)
+	if err != nil {
+		sshClient.Close()
+		return nil, fmt.Errorf("get kubeconfig: %w", err)
+	}
+	cs, err := createTunneledK8sClient(sshClient, kubeconfig, masterIP)
+	if err != nil {
+		sshClient.Close()
+		return nil, fmt.Errorf("k8s client: %w", err)
+	}
+	return &kubeOps{
+		ssh: sshClient, k8s: cs, backend: backend, keyPath: keyPath, inventoryPath: inventoryPath,
+		envVars: runnerEnv(backend), forks: forks,
+	}, nil
+}
+
+func (o *kubeOps) Close() { o.ssh.Close() }
+
+func (o *kubeOps) Unavailable(ctx context.Context, names []string) ([]string, error) {
+	var down []string
+	for _, name := range names {
+		node, err := o.k8s.CoreV1().Nodes().Get(ctx, name, metav1.GetOptions{})
+		if errors.IsNotFound(err) {
+			down = append(down, name)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !nodeReady(node) {
+			down = append(down, name)
+		}
+	}
+	return down, nil
+}
+
+func (o *kubeOps) Drain(ctx context.Context, name string) error {
+	if err := setNodeUnschedulable(ctx, o.k8s, name, true); err != nil {
+		return fmt.Errorf("cordon: %w", err)
+	}
+	return drainNode(ctx, o.k8s, name, drainTimeout, k8sPollInterval)
+}
+
+func (o *kubeOps) RemoveNode(ctx context.Context, name string) error {
+	if err := o.writeInventory(); err != nil {
+		return err
+	}
+	return runRunnerStep(o.ssh, o.inventoryPath, o.keyPath, o.envVars, "remove-node", "-node", name, "-yes")
+}
+
+func (o *kubeOps) Join(ctx context.Context, name string) error {
+	if err := o.writeInventory(); err != nil {
+		return err
+	}
+	return runRunnerStep(o.ssh, o.inventoryPath, o.keyPath, o.envVars, "scale", "-f", fmt.Sprintf("%d", o.forks), "-l", name)
+}
+
+func (o *kubeOps) WaitReady(ctx context.Context, name string) error {
+	return waitNodeReady(ctx, o.k8s, name, nodeReadyTimeout, k8sPollInterval)
+}
+
+// writeInventory regenerates the inventory from the current state
+func (o *kubeOps) writeInventory() error {
+	st, err := o.backend.LoadState()
+	if err != nil || st == nil {
+		return fmt.Errorf("state: %v", err)
+	}
+	var nodes []NodeResult
+	for _, n := range st.Nodes {
+		nodes = append(nodes, NodeResult{
+			Name: n.Name, IP: n.IP, Role: n.Role, Labels: n.Labels, Taints: n.Taints, Disks: n.Disks, SSHPort: n.SSHPort,
+		})
+	}
+	saveToAnsibleInventory(o.inventoryPath, st.SSHUser, nodes, "")
+	return nil
+}
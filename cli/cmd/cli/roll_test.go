@@ -0,0 +1,245 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"cli/internal/state"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+const rollConfig = testConfig + `  - name_prefix: worker
+    role: worker
+    instances:
+      1: {enabled: true}
+      2: {enabled: true}
+    flavor: {ram: 2, vcpus: 1}
+    disks:
+      - {size: 10, bootable: true}
+      - {size: 30, mount_point: /data}
+`
+
+// fakeOps records the Kubernetes steps of a roll and fails the ones listed in fail
+type fakeOps struct {
+	calls []string
+	down  []string
+	fail  map[string]error
+}
+
+func (o *fakeOps) step(name string) error {
+	o.calls = append(o.calls, name)
+	return o.fail[name]
+}
+
+func (o *fakeOps) Unavailable(ctx context.Context, names []string) ([]string, error) {
+	var down []string
+	for _, n := range names {
+		if slices.Contains(o.down, n) {
+			down = append(down, n)
+		}
+	}
+	return down, nil
+}
+func (o *fakeOps) Drain(ctx context.Context, name string) error      { return o.step("drain " + name) }
+func (o *fakeOps) RemoveNode(ctx context.Context, name string) error { return o.step("remove " + name) }
+func (o *fakeOps) Join(ctx context.Context, name string) error       { return o.step("join " + name) }
+func (o *fakeOps) WaitReady(ctx context.Context, name string) error  { return o.step("ready " + name) }
+func (o *fakeOps) Uncordon(ctx context.Context, name string) error   { return o.step("uncordon " + name) }
+
+func (e *testEnv) roll(group string, maxUnavailable int, ops nodeOps) error {
+	e.t.Helper()
+	cfg, err := GetClusterConfig(e.config)
+	if err != nil {
+		e.t.Fatal(err)
+	}
+	return rollGroup(e.t.Context(), e.client, e.backend, cfg, testCluster, group, maxUnavailable, ops)
+}
+
+func TestRollReplacesNodesOneByOne(t *testing.T) {
+	e := newTestEnv(t, rollConfig)
+	e.reconcile()
+	old := map[string]string{}
+	oldDisk := map[string]string{}
+	for _, name := range []string{"test-worker-1", "test-worker-2"} {
+		n := e.node(name)
+		d, _ := dataDisk(n)
+		old[name], oldDisk[name] = n.ID, d.ID
+	}
+	volumes := len(e.fake.Volumes())
+
+	os.WriteFile(e.config, []byte(strings.Replace(rollConfig, "flavor: {ram: 2, vcpus: 1}\n    disks:\n      - {size: 10, bootable: true}\n      - {size: 30", "flavor: {ram: 4, vcpus: 2}\n    disks:\n      - {size: 10, bootable: true}\n      - {size: 30", 1)), 0644)
+	ops := &fakeOps{}
+	if err := e.roll("worker", 1, ops); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"drain test-worker-1", "remove test-worker-1", "join test-worker-1", "ready test-worker-1",
+		"drain test-worker-2", "remove test-worker-2", "join test-worker-2", "ready test-worker-2",
+	}
+	if !slices.Equal(ops.calls, want) {
+		t.Fatalf("steps:\n%v\nwant\n%v", ops.calls, want)
+	}
+	for name, oldID := range old {
+		n := e.node(name)
+		d, _ := dataDisk(n)
+		if n.ID == oldID || d.ID != oldDisk[name] || d.MountPoint != "/data" {
+			t.Fatalf("%s after roll: %+v", name, n)
+		}
+		found := false
+		for _, srv := range e.fake.Servers() {
+			if srv.ID == n.ID {
+				found = true
+				if srv.RAM != 4 || srv.VCPUs != 2 {
+					t.Fatalf("%s was recreated with %dGB/%d vCPU", name, srv.RAM, srv.VCPUs)
+				}
+			}
+		}
+		if !found || len(e.fake.Servers()) != 5 {
+			t.Fatalf("servers after roll: %+v", e.fake.Servers())
+		}
+	}
+	if got := len(e.fake.Volumes()); got != volumes {
+		t.Fatalf("%d volumes after roll, want %d: data disks must be reused", got, volumes)
+	}
+	if e.node("test-master-1").ID == "" {
+		t.Fatal("other groups lost from the state")
+	}
+}
+
+func TestRollWaitsForTheDelete(t *testing.T) {
+	e := newTestEnv(t, rollConfig)
+	e.reconcile()
+	old := e.node("test-worker-1").ID
+	volumes := len(e.fake.Volumes())
+
+	// The old server stays DELETING with its disks attached for a few polls
+	e.fake.DeletePolls = 3
+	ops := &fakeOps{}
+	if err := e.roll("worker", 1, ops); err != nil {
+		t.Fatal(err)
+	}
+	if n := e.node("test-worker-1"); n.ID == old || n.IP == "" {
+		t.Fatalf("test-worker-1 was not recreated: %+v", n)
+	}
+	if got := len(e.fake.Servers()); got != 5 {
+		t.Fatalf("%d servers after roll, want 5", got)
+	}
+	if got := len(e.fake.Volumes()); got != volumes {
+		t.Fatalf("%d volumes after roll, want %d", got, volumes)
+	}
+}
+
+func TestRollStopsOnFirstFailure(t *testing.T) {
+	e := newTestEnv(t, rollConfig)
+	e.reconcile()
+	second := e.node("test-worker-2").ID
+
+	ops := &fakeOps{fail: map[string]error{"join test-worker-1": fmt.Errorf("scale.yml failed")}}
+	err := e.roll("worker", 1, ops)
+	if err == nil || !strings.Contains(err.Error(), "test-worker-1") || !strings.Contains(err.Error(), "scale.yml failed") {
+		t.Fatalf("expected the scale failure of test-worker-1, got %v", err)
+	}
+	if ops.calls[len(ops.calls)-1] != "join test-worker-1" {
+		t.Fatalf("steps after the failure: %v", ops.calls)
+	}
+	if e.node("test-worker-2").ID != second {
+		t.Fatal("test-worker-2 was replaced after a failure")
+	}
+}
+
+func TestRollRespectsMaxUnavailable(t *testing.T) {
+	e := newTestEnv(t, rollConfig)
+	e.reconcile()
+
+	ops := &fakeOps{down: []string{"test-worker-2"}}
+	if err := e.roll("worker", 1, ops); err == nil || !strings.Contains(err.Error(), "max unavailable is 1") {
+		t.Fatalf("expected a max-unavailable error, got %v", err)
+	}
+	if len(ops.calls) != 0 {
+		t.Fatalf("nodes touched with the budget used up: %v", ops.calls)
+	}
+
+	ops = &fakeOps{down: []string{"test-worker-2"}, fail: map[string]error{"drain test-worker-1": fmt.Errorf("stop")}}
+	if err := e.roll("worker", 2, ops); err == nil || len(ops.calls) != 1 {
+		t.Fatalf("with -max-unavailable 2 the roll should start: %v, %v", err, ops.calls)
+	}
+}
+
+func TestRollRefusesControlPlaneAndBastion(t *testing.T) {
+	e := newTestEnv(t, rollConfig)
+	e.reconcile()
+	for _, g := range []string{"master", "bastion", "missing"} {
+		ops := &fakeOps{}
+		if err := e.roll(g, 1, ops); err == nil || len(ops.calls) != 0 {
+			t.Errorf("roll of %s: %v, %v", g, err, ops.calls)
+		}
+	}
+}
+
+func TestGroupNodesMatchesTheGroupExactly(t *testing.T) {
+	st := &state.ClusterState{}
+	for _, n := range []string{"test-web-10", "test-web-api-1", "test-web-2", "test-web-x", "test-web-1"} {
+		st.Nodes = append(st.Nodes, state.NodeState{Name: n})
+	}
+	var got []string
+	for _, n := range groupNodes(st, testCluster, "web") {
+		got = append(got, n.Name)
+	}
+	if want := []string{"test-web-1", "test-web-2", "test-web-10"}; !slices.Equal(got, want) {
+		t.Fatalf("nodes of web: %v, want %v", got, want)
+	}
+}
+
+func TestDrainEvictsPodsExceptDaemonSets(t *testing.T) {
+	pod := func(name string, owner string) *corev1.Pod {
+		p := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"}, Spec: corev1.PodSpec{NodeName: "w1"}}
+		if owner != "" {
+			p.OwnerReferences = []metav1.OwnerReference{{Kind: owner, Name: "x"}}
+		}
+		return p
+	}
+	cs := fake.NewClientset(
+		&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "w1"}},
+		pod("app", "ReplicaSet"), pod("guarded", "ReplicaSet"), pod("agent", "DaemonSet"),
+	)
+	blocked := 2
+	cs.PrependReactor("create", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.GetSubresource() != "eviction" {
+			return false, nil, nil
+		}
+		name := action.(k8stesting.CreateAction).GetObject().(metav1.Object).GetName()
+		if name == "guarded" && blocked > 0 {
+			blocked--
+			return true, nil, apierrors.NewTooManyRequests("disruption budget", 1)
+		}
+		return true, nil, cs.Tracker().Delete(schema.GroupVersionResource{Version: "v1", Resource: "pods"}, "default", name)
+	})
+
+	if err := setNodeUnschedulable(t.Context(), cs, "w1", true); err != nil {
+		t.Fatal(err)
+	}
+	if err := drainNode(t.Context(), cs, "w1", time.Second, time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	pods, _ := cs.CoreV1().Pods("").List(t.Context(), metav1.ListOptions{})
+	if len(pods.Items) != 1 || pods.Items[0].Name != "agent" {
+		t.Fatalf("pods left: %+v", pods.Items)
+	}
+	node, _ := cs.CoreV1().Nodes().Get(t.Context(), "w1", metav1.GetOptions{})
+	if !node.Spec.Unschedulable || nodeReady(node) {
+		t.Fatal("node not cordoned")
+	}
+}
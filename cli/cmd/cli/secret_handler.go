@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"strings"
@@ -11,7 +12,7 @@ import (
 )
 
 // handleKVSecret handles the -kvsec flag
-func handleKVSecret(client *clo.Client, backend *state.Backend, clusterName, inputStr string) {
+func handleKVSecret(ctx context.Context, client *clo.Client, backend state.StateStore, clusterName, inputStr string) {
 	parts := strings.SplitN(inputStr, ":", 2)
 	if len(parts) != 2 {
 		fmt.Println("[ERROR] Format error. Use: -kvsec 'SECRET_NAME:KEY=VALUE'")
@@ -28,7 +29,7 @@ func handleKVSecret(client *clo.Client, backend *state.Backend, clusterName, inp
 	value := kvParts[1]
 
 	if backend == nil {
-		fmt.Println("[ERROR] S3 Backend unavailable.")
+		fmt.Println("[ERROR] State backend unavailable.")
 		os.Exit(1)
 	}
 
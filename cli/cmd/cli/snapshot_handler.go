@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"cli/internal/clo"
+	"cli/internal/state"
+)
+
+// Snapshot metadata keys; they tie a snapshot to the disk it was taken from
+const (
+	snapTagCluster = "cluster"
+	snapTagNode    = "node"
+	snapTagMount   = "mount_point"
+	snapTagVolume  = "volume_id"
+)
+
+// handleSnapshotDisks snapshots the data disks of every node in the state (only critical ones with
+// criticalOnly) and then keeps the newest `keep` snapshots per disk. Run it from cron/CI for scheduled backups.
+func handleSnapshotDisks(ctx context.Context, client *clo.Client, backend state.StateStore, clusterName string, criticalOnly bool, keep int) {
+	fmt.Printf("[REFRESH] Loading state '%s'...\n", clusterName)
+	st, err := backend.LoadState()
+	if err != nil || st == nil {
+		fmt.Printf("[ERROR] State loading error: %v\n", err)
+		os.Exit(1)
+	}
+
+	stamp := time.Now().UTC().Format("20060102-150405")
+	created, failed := 0, 0
+	for _, n := range st.Nodes {
+		for _, d := range n.Disks {
+			if d.Bootable || (criticalOnly && !d.Critical) {
+				continue
+			}
+			name := fmt.Sprintf("%s-%s-%s", n.Name, mountSlug(d.MountPoint), stamp)
+			tags := map[string]string{snapTagCluster: clusterName, snapTagNode: n.Name, snapTagMount: d.MountPoint, snapTagVolume: d.ID}
+			fmt.Printf("[CAMERA] %s %s (%s, %dGB)... ", n.Name, d.MountPoint, d.ID, d.Size)
+			id, err := client.CreateSnapshot(ctx, d.ID, name, tags)
+			if err != nil {
+				fmt.Printf("[ERROR] %v\n", err)
+				failed++
+				continue
+			}
+			fmt.Printf("[+OK+] %s\n", id)
+			created++
+		}
+	}
+	if created == 0 && failed == 0 {
+		fmt.Println("[INFO] No data disks to snapshot.")
+		return
+	}
+	fmt.Printf("[STAR] Snapshots created: %d, failed: %d\n", created, failed)
+	if failed > 0 {
+		// Older snapshots are all we have for the failed disks: do not prune them
+		fmt.Println("[WARNING] Retention pruning skipped because of failures.")
+		os.Exit(1)
+	}
+	if keep > 0 {
+		if err := pruneSnapshots(ctx, client, clusterName, keep); err != nil {
+			fmt.Printf("[ERROR] Pruning error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+}
+
+// pruneSnapshots deletes all but the newest keep snapshots of every disk of the cluster
+func pruneSnapshots(ctx context.Context, client *clo.Client, clusterName string, keep int) error {
+	list, err := client.GetProjectSnapshots(ctx)
+	if err != nil {
+		return err
+	}
+	perDisk := make(map[string][]clo.Snapshot)
+	for _, sn := range clusterSnapshots(list.Result, clusterName) {
+		key := sn.Tags[snapTagNode] + " " + sn.Tags[snapTagMount]
+		perDisk[key] = append(perDisk[key], sn)
+	}
+	deleted := 0
+	for _, snaps := range perDisk {
+		for _, sn := range snaps[min(keep, len(snaps)):] {
+			fmt.Printf("[TRASH_CAN] Deleting old snapshot %s (%s)... ", sn.Name, sn.ID)
+			if err := client.DeleteSnapshot(ctx, sn.ID); err != nil && !clo.IsNotFound(err) {
+				fmt.Printf("[ERROR] %v\n", err)
+				continue
+			}
+			fmt.Println("[+OK+]")
+			deleted++
+		}
+	}
+	fmt.Printf("[STAR] Retention: %d snapshot(s) per disk kept, %d deleted.\n", keep, deleted)
+	return nil
+}
+
+// handleListSnapshots prints the snapshots taken of the cluster disks, newest first
+func handleListSnapshots(ctx context.Context, client *clo.Client, clusterName string, jsonOutput bool) {
+	list, err := client.GetProjectSnapshots(ctx)
+	if err != nil {
+		fmt.Printf("[ERROR] API error: %v\n", err)
+		os.Exit(1)
+	}
+	snaps := clusterSnapshots(list.Result, clusterName)
+	if jsonOutput {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(snaps)
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "ID\tNODE\tMOUNT\tSIZE\tCREATED\tSTATUS")
+	fmt.Fprintln(w, "--\t----\t-----\t----\t-------\t------")
+	for _, sn := range snaps {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%dGB\t%s\t%s\n", sn.ID, sn.Tags[snapTagNode], sn.Tags[snapTagMount], sn.Size, sn.Created, sn.Status)
+	}
+	w.Flush()
+}
+
+// clusterSnapshots returns the snapshots tagged with the cluster, newest first
+func clusterSnapshots(all []clo.Snapshot, clusterName string) []clo.Snapshot {
+	var out []clo.Snapshot
+	for _, sn := range all {
+		if sn.Tags[snapTagCluster] == clusterName {
+			out = append(out, sn)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		ti, _ := time.Parse(time.RFC3339, out[i].Created)
+		tj, _ := time.Parse(time.RFC3339, out[j].Created)
+		return ti.After(tj)
+	})
+	return out
+}
+
+// latestSnapshot finds the newest usable snapshot of a node disk
+func latestSnapshot(all []clo.Snapshot, clusterName, node, mountPoint string) *clo.Snapshot {
+	for _, sn := range clusterSnapshots(all, clusterName) {
+		if sn.Tags[snapTagNode] == node && sn.Tags[snapTagMount] == mountPoint && sn.Status == "AVAILABLE" {
+			return &sn
+		}
+	}
+	return nil
+}
+
+// planSnapshotRestores turns new data disks of nodes whose old volume is gone from the project
+// into restores from the latest snapshot. existingVolumes holds the IDs of all project volumes.
+func planSnapshotRestores(ctx context.Context, client *clo.Client, clusterName string, nodes []PlanNode, existingVolumes map[string]bool) {
+	var snaps []clo.Snapshot
+	loaded := false
+	for i := range nodes {
+		n := &nodes[i]
+		for j := range n.NewDisks {
+			d := &n.NewDisks[j]
+			if d.Action != "create" || d.Bootable || d.MountPoint == "" || !lostDisk(n.OldDisks, d.MountPoint, existingVolumes) {
+				continue
+			}
+			if !loaded {
+				loaded = true
+				list, err := client.GetProjectSnapshots(ctx)
+				if err != nil {
+					fmt.Printf("[WARNING] Snapshots API error, lost disks are created empty: %v\n", err)
+					return
+				}
+				snaps = list.Result
+			}
+			sn := latestSnapshot(snaps, clusterName, n.Name, d.MountPoint)
+			if sn == nil {
+				fmt.Printf("[WARNING] [%s] Volume of %s is gone and has no snapshot: an empty disk is created.\n", n.Name, d.MountPoint)
+				continue
+			}
+			if sn.Size > d.Size {
+				fmt.Printf("[WARNING] [%s] Snapshot %s of %s is %dGB, larger than the configured %dGB: not restored.\n", n.Name, sn.ID, d.MountPoint, sn.Size, d.Size)
+				continue
+			}
+			d.Action, d.SnapshotID = "restore", sn.ID
+		}
+	}
+}
+
+// lostDisk reports whether the node had a data disk at mountPoint whose volume no longer exists
+func lostDisk(oldDisks []state.DiskState, mountPoint string, existingVolumes map[string]bool) bool {
+	for _, old := range oldDisks {
+		if !old.Bootable && old.MountPoint == mountPoint && !existingVolumes[old.ID] {
+			return true
+		}
+	}
+	return false
+}
+
+// mountSlug turns a mount point into a name fragment: /var/lib/data -> var-lib-data
+func mountSlug(mountPoint string) string {
+	slug := strings.Trim(strings.ReplaceAll(mountPoint, "/", "-"), "-")
+	if slug == "" {
+		return "disk"
+	}
+	return slug
+}
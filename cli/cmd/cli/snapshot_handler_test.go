@@ -0,0 +1,117 @@
+package main
+
+import (
+	"testing"
+
+	"cli/internal/state"
+)
+
+func TestSnapshotDisksTagsAndPrunes(t *testing.T) {
+	e := newTestEnv(t, testConfig)
+	e.reconcile()
+	disk, _ := dataDisk(e.node("test-master-1"))
+
+	handleSnapshotDisks(t.Context(), e.client, e.backend, testCluster, false, 1)
+	first := e.fake.Snapshots()
+	if len(first) != 2 {
+		t.Fatalf("%d snapshots, want one per data disk", len(first))
+	}
+	handleSnapshotDisks(t.Context(), e.client, e.backend, testCluster, false, 1)
+
+	snaps := e.fake.Snapshots()
+	if len(snaps) != 2 {
+		t.Fatalf("%d snapshots after pruning to 1 per disk, want 2", len(snaps))
+	}
+	for _, sn := range snaps {
+		if sn.ID == first[0].ID || sn.ID == first[1].ID {
+			t.Fatalf("pruning kept the older snapshot %s", sn.ID)
+		}
+		if sn.Tags[snapTagNode] == "test-master-1" && (sn.VolumeID != disk.ID || sn.Tags[snapTagMount] != "/data" || sn.Tags[snapTagCluster] != testCluster) {
+			t.Fatalf("snapshot of test-master-1: %+v", sn)
+		}
+	}
+}
+
+func TestSnapshotDisksCriticalOnly(t *testing.T) {
+	e := newTestEnv(t, testConfig)
+	e.reconcile()
+	markCritical(t, e, "test-master-2")
+
+	handleSnapshotDisks(t.Context(), e.client, e.backend, testCluster, true, 0)
+	snaps := e.fake.Snapshots()
+	if len(snaps) != 1 || snaps[0].Tags[snapTagNode] != "test-master-2" {
+		t.Fatalf("snapshots: %+v", snaps)
+	}
+}
+
+func TestReconcileRestoresLostVolumeFromSnapshot(t *testing.T) {
+	e := newTestEnv(t, testConfig)
+	e.reconcile()
+	markCritical(t, e, "test-master-1")
+	handleSnapshotDisks(t.Context(), e.client, e.backend, testCluster, false, 0)
+	oldDisk, _ := dataDisk(e.node("test-master-1"))
+
+	e.fake.DeleteServerOutOfBand("test-master-1")
+	e.fake.DeleteVolumeOutOfBand(oldDisk.ID)
+	p := e.plan()
+	if len(p.Create) != 1 {
+		t.Fatalf("plan should recreate test-master-1, got %+v", p.Create)
+	}
+	var restore *PlannedDisk
+	for i, d := range p.Create[0].NewDisks {
+		if d.MountPoint == "/data" {
+			restore = &p.Create[0].NewDisks[i]
+		}
+	}
+	if restore == nil || restore.Action != "restore" || restore.SnapshotID == "" {
+		t.Fatalf("data disk not planned as a restore: %+v", p.Create[0].NewDisks)
+	}
+	e.reconcile()
+
+	n := e.node("test-master-1")
+	d, _ := dataDisk(n)
+	if d.ID == oldDisk.ID || d.MountPoint != "/data" || !d.Critical {
+		t.Fatalf("restored disk in state: %+v", d)
+	}
+	if v, _ := e.fake.Volume(d.ID); v.ServerID != n.ID || v.Status != "IN_USE" || v.Size != 50 {
+		t.Fatalf("restored volume in cloud: %+v", v)
+	}
+	if got := e.fake.CountCalls("POST", "/snapshots/"+restore.SnapshotID+"/restore"); got != 1 {
+		t.Fatalf("%d restore calls, want 1", got)
+	}
+}
+
+func TestLostVolumeWithoutSnapshotIsCreated(t *testing.T) {
+	e := newTestEnv(t, testConfig)
+	e.reconcile()
+	oldDisk, _ := dataDisk(e.node("test-master-1"))
+
+	e.fake.DeleteServerOutOfBand("test-master-1")
+	e.fake.DeleteVolumeOutOfBand(oldDisk.ID)
+	for _, d := range e.plan().Create[0].NewDisks {
+		if d.Action != "create" {
+			t.Fatalf("without a snapshot every disk is created: %+v", d)
+		}
+	}
+}
+
+// markCritical flags the data disk of a node as critical in the state
+func markCritical(t *testing.T, e *testEnv, node string) {
+	t.Helper()
+	err := e.backend.UpdateState(func(st *state.ClusterState) error {
+		for i := range st.Nodes {
+			if st.Nodes[i].Name != node {
+				continue
+			}
+			for j := range st.Nodes[i].Disks {
+				if !st.Nodes[i].Disks[j].Bootable {
+					st.Nodes[i].Disks[j].Critical = true
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"net"
 	"os"
 	"os/exec"
+	"strings"
 	"time"
 
 	"cli/internal/local"
@@ -94,27 +95,9 @@ func getAuthMethods(password string, priorityKeyPath string) []ssh.AuthMethod {
 	return methods
 }
 
-func DeployAndRunKubespray(bastionIP string, bastionPort int, user, keyPath, inventoryPath string, forks int, runnerMode string, extraArgs string, s3Backend *state.Backend) {
+func DeployAndRunKubespray(bastionIP string, bastionPort int, user, keyPath, inventoryPath string, forks int, runnerMode string, extraArgs string, s3Backend state.StateStore) {
 	err := func() error {
-		var envVars string
-		if s3Backend != nil {
-			endpoint := os.Getenv("S3_ENDPOINT")
-			access := os.Getenv("S3_ACCESS_KEY")
-			secret := os.Getenv("S3_SECRET_KEY")
-			if endpoint != "" {
-				am, _ := NewArtifactsManager(endpoint, access, secret, true)
-				if am != nil {
-					if url, err := am.GetPresignedURL("kubespray.tar"); err == nil {
-						envVars += fmt.Sprintf("export KUBESPRAY_URL='%s'\n", url)
-						fmt.Println("[LINK] S3 Link generated: kubespray.tar")
-					}
-					if url, err := am.GetPresignedURL("k8s_images.tar"); err == nil {
-						envVars += fmt.Sprintf("export K8S_IMAGES_URL='%s'\n", url)
-						fmt.Println("[LINK] S3 Link generated: k8s_images.tar")
-					}
-				}
-			}
-		}
+		envVars := runnerEnv(s3Backend)
 
 		bastionAddr := net.JoinHostPort(bastionIP, fmt.Sprintf("%d", bastionPort))
 		fmt.Printf("[PLUG] [1/5] Connecting to %s...\n", bastionAddr)
@@ -145,32 +128,18 @@ func DeployAndRunKubespray(bastionIP string, bastionPort int, user, keyPath, inv
 			sessionName = "create-user-ops"
 		} else if runnerMode == "os-update" {
 			sessionName = "os-upgrade"
+		} else if runnerMode == "grow-fs" {
+			sessionName = "grow-fs"
 		}
 
 		if checkScreenSession(client, sessionName) {
 			fmt.Println("[ANNOUNCE] Connecting to existing session...")
 		} else {
 			fmt.Println("[T] [4/5] Uploading files...")
-			localBinary := "./k8s-runner-temp"
-			if err := exec.Command("go", "build", "-o", localBinary, "./cmd/runner/main.go").Run(); err != nil {
-				return fmt.Errorf("compile error: %w", err)
-			}
-			defer os.Remove(localBinary)
-
-			const remoteRoot = "/root/kubespray-fs"
-			remoteBin := "/root/k8s-runner"
-
-			runCommand(client, fmt.Sprintf("mkdir -p %s/root/.ssh", remoteRoot))
-			runCommand(client, "rm -f "+remoteBin)
-
-			uploadFile(client, inventoryPath, remoteRoot+"/inventory.yaml")
-			uploadFile(client, keyPath, remoteRoot+"/root/.ssh/id_rsa")
-			if err := uploadFile(client, localBinary, remoteBin); err != nil {
-				return fmt.Errorf("upload error: %w", err)
+			if err := uploadRunner(client, inventoryPath, keyPath); err != nil {
+				return err
 			}
 
-			runCommand(client, "chmod +x "+remoteBin)
-
 			wrapperScript := fmt.Sprintf("/root/run_%s.sh", runnerMode)
 			runArgs := runnerMode
 
@@ -204,7 +173,7 @@ else
     echo "[ERROR] Failed with exit code $RET."
     exec bash
 fi
-`, envVars, runnerMode, remoteBin, runArgs)
+`, envVars, runnerMode, remoteRunnerBin, runArgs)
 
 			runCommand(client, fmt.Sprintf("cat <<'EOF' > %s\n%s\nEOF", wrapperScript, scriptContent))
 			runCommand(client, "chmod +x "+wrapperScript)
@@ -244,6 +213,79 @@ startup_message off
 	}
 }
 
+// remoteRunnerBin is where the runner binary is uploaded on the bastion
+const remoteRunnerBin = "/root/k8s-runner"
+
+// runnerEnv exports presigned S3 links to the kubespray FS and image bundle, when S3 is configured
+func runnerEnv(s3Backend state.StateStore) string {
+	var envVars string
+	if s3Backend == nil {
+		return ""
+	}
+	endpoint := os.Getenv("S3_ENDPOINT")
+	access := os.Getenv("S3_ACCESS_KEY")
+	secret := os.Getenv("S3_SECRET_KEY")
+	if endpoint != "" {
+		am, _ := NewArtifactsManager(endpoint, access, secret, true)
+		if am != nil {
+			if url, err := am.GetPresignedURL("kubespray.tar"); err == nil {
+				envVars += fmt.Sprintf("export KUBESPRAY_URL='%s'\n", url)
+				fmt.Println("[LINK] S3 Link generated: kubespray.tar")
+			}
+			if url, err := am.GetPresignedURL("k8s_images.tar"); err == nil {
+				envVars += fmt.Sprintf("export K8S_IMAGES_URL='%s'\n", url)
+				fmt.Println("[LINK] S3 Link generated: k8s_images.tar")
+			}
+		}
+	}
+	return envVars
+}
+
+// uploadRunner builds the runner and uploads it with the inventory and the SSH key to the bastion
+func uploadRunner(client *ssh.Client, inventoryPath, keyPath string) error {
+	localBinary := "./k8s-runner-temp"
+	if err := exec.Command("go", "build", "-o", localBinary, "./cmd/runner/main.go").Run(); err != nil {
+		return fmt.Errorf("compile error: %w", err)
+	}
+	defer os.Remove(localBinary)
+
+	const remoteRoot = "/root/kubespray-fs"
+
+	runCommand(client, fmt.Sprintf("mkdir -p %s/root/.ssh", remoteRoot))
+	runCommand(client, "rm -f "+remoteRunnerBin)
+
+	uploadFile(client, inventoryPath, remoteRoot+"/inventory.yaml")
+	uploadFile(client, keyPath, remoteRoot+"/root/.ssh/id_rsa")
+	if err := uploadFile(client, localBinary, remoteRunnerBin); err != nil {
+		return fmt.Errorf("upload error: %w", err)
+	}
+
+	runCommand(client, "chmod +x "+remoteRunnerBin)
+	return nil
+}
+
+// runRunnerStep uploads the runner and runs one mode to completion without a screen session.
+// The error reports a failed playbook (non-zero exit of the runner).
+func runRunnerStep(client *ssh.Client, inventoryPath, keyPath, envVars, mode string, args ...string) error {
+	if err := uploadRunner(client, inventoryPath, keyPath); err != nil {
+		return err
+	}
+	session, err := client.NewSession()
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+	mw := local.GetLogWriter()
+	session.Stdout = mw
+	session.Stderr = mw
+
+	cmd := fmt.Sprintf("%s%s %s %s", envVars, remoteRunnerBin, mode, strings.Join(args, " "))
+	if err := session.Run(cmd); err != nil {
+		return fmt.Errorf("runner %s: %w", mode, err)
+	}
+	return nil
+}
+
 // Helpers
 func checkScreenSession(client *ssh.Client, name string) bool {
 	session, err := client.NewSession()
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"cli/internal/state"
+)
+
+// handleStateHistory lists stored state revisions, newest first
+func handleStateHistory(backend *state.Backend, jsonOutput bool) {
+	revs, err := backend.ListHistory()
+	if err != nil {
+		fmt.Printf("[ERROR] History listing error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if jsonOutput {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(revs)
+		return
+	}
+
+	if len(revs) == 0 {
+		fmt.Println("[ENVELOPE] No state history found.")
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "REVISION\tSAVED AT\tNODES\tSIZE")
+	fmt.Fprintln(w, "--------\t--------\t-----\t----")
+	for _, r := range revs {
+		nodes := "?"
+		if st, err := backend.LoadRevision(r.ID); err == nil {
+			nodes = fmt.Sprintf("%d", len(st.Nodes))
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d B\n", r.ID, r.Saved.Local().Format(time.RFC1123), nodes, r.Size)
+	}
+	w.Flush()
+}
+
+// handleStateShow prints a stored revision
+func handleStateShow(backend *state.Backend, revID string, jsonOutput bool) {
+	st, err := backend.LoadRevision(revID)
+	if err != nil {
+		fmt.Printf("[ERROR] %v\n", err)
+		os.Exit(1)
+	}
+	if !jsonOutput {
+		fmt.Printf("[FILE_FOLDER] Revision: %s\n", revID)
+	}
+	printClusterState(st, jsonOutput)
+}
+
+// handleStateRollback restores a stored revision as the current state
+func handleStateRollback(backend *state.Backend, revID string) error {
+	rev, err := backend.LoadRevision(revID)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("[FILE_FOLDER] Revision %s: %d nodes, last updated %s\n", revID, len(rev.Nodes), rev.LastUpdated.Format(time.RFC1123))
+	if !askForConfirmation("[WARNING] Replace the current state with this revision?") {
+		fmt.Println("Cancellation.")
+		return nil
+	}
+	if err := backend.Rollback(revID); err != nil {
+		return fmt.Errorf("rollback failed: %w", err)
+	}
+	fmt.Println("[SAVE] State rolled back. The previous state is kept in history (see -state-history).")
+	return nil
+}
+
+// handleStateRewrap re-encrypts the state and its history after the recipient list changed
+func handleStateRewrap(backend *state.Backend, encrypted bool) error {
+	if !encrypted && !askForConfirmation("[WARNING] No age recipients set: state and history will be stored as plaintext. Continue?") {
+		fmt.Println("Cancellation.")
+		return nil
+	}
+	if err := backend.Rewrap(); err != nil {
+		return fmt.Errorf("rewrap failed: %w", err)
+	}
+	if encrypted {
+		fmt.Println("[LOCK] State and history re-encrypted to the current recipients.")
+	} else {
+		fmt.Println("[SAVE] State and history decrypted.")
+	}
+	return nil
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"cli/internal/clo"
+	"cli/internal/state"
+)
+
+// handleStateExport writes the current state as plaintext JSON to a file
+func handleStateExport(backend *state.Backend, file string) {
+	data, err := backend.ExportState()
+	if err != nil {
+		fmt.Printf("[ERROR] Export failed: %v\n", err)
+		os.Exit(1)
+	}
+	if err := os.WriteFile(file, data, 0600); err != nil {
+		fmt.Printf("[ERROR] File write error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("[SAVE] State exported to %s (plaintext, keep it safe).\n", file)
+}
+
+// handleStateImport replaces the current state with a previously exported file
+func handleStateImport(backend *state.Backend, clusterName, file string) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return fmt.Errorf("file read error: %w", err)
+	}
+
+	current, err := backend.LoadState()
+	if err != nil {
+		return fmt.Errorf("state load error: %w", err)
+	}
+	if current != nil && len(current.Nodes) > 0 {
+		fmt.Printf("[WARNING] Cluster '%s' already has a state with %d nodes.\n", clusterName, len(current.Nodes))
+		if !askForConfirmation("[WARNING] Replace it with the imported state? (the current one stays in history)") {
+			fmt.Println("Cancellation.")
+			return nil
+		}
+	}
+
+	if err := backend.ImportState(data); err != nil {
+		return err
+	}
+	st, _ := backend.LoadState()
+	for _, n := range st.Nodes {
+		if !strings.HasPrefix(n.Name, clusterName+"-") {
+			fmt.Printf("[WARNING] Node '%s' does not follow the '%s-<prefix>-<n>' naming of this cluster.\n", n.Name, clusterName)
+		}
+	}
+	fmt.Printf("[SAVE] Imported %d nodes into '%s'.\n", len(st.Nodes), clusterName)
+	return nil
+}
+
+// handleStateMigrate copies the state and its history to another bucket or endpoint.
+// target is a state URL (s3://bucket/prefix, file:///dir) or "endpoint/bucket[/prefix]";
+// for the latter TARGET_S3_ACCESS_KEY/TARGET_S3_SECRET_KEY override the source credentials.
+func handleStateMigrate(backend *state.Backend, clusterName, target string, srcOpts state.S3Options) error {
+	if target == "" {
+		return errors.New("specify the destination with -to (endpoint/bucket[/prefix] or a state URL)")
+	}
+
+	targetURL, opts := target, srcOpts
+	if !strings.Contains(target, "://") {
+		endpoint, rest, ok := strings.Cut(target, "/")
+		if !ok || rest == "" {
+			return fmt.Errorf("invalid destination '%s' (expected endpoint/bucket[/prefix])", target)
+		}
+		targetURL = "s3://" + rest
+		opts.Endpoint = endpoint
+		if v := os.Getenv("TARGET_S3_ACCESS_KEY"); v != "" {
+			opts.AccessKey = v
+		}
+		if v := os.Getenv("TARGET_S3_SECRET_KEY"); v != "" {
+			opts.SecretKey = v
+		}
+	}
+
+	dst, err := state.Open(targetURL, clusterName, opts)
+	if err != nil {
+		return fmt.Errorf("destination: %w", err)
+	}
+
+	fmt.Printf("[GO] Copying state of '%s' to %s ...\n", clusterName, target)
+	if err := backend.CopyTo(dst.Store, dst.StateKey); err != nil {
+		return fmt.Errorf("migration failed: %w", err)
+	}
+	revs, _ := dst.ListHistory()
+	fmt.Printf("[+OK+] State and %d revisions copied. Point STATE_URL/S3_* at the destination; the source is left untouched.\n", len(revs))
+	return nil
+}
+
+// handleAdoptProject builds a state for a CLO project that was never managed by this tool.
+// Servers named <cluster>-<prefix>-<n> are matched against the config groups.
+func handleAdoptProject(ctx context.Context, client *clo.Client, backend state.StateStore, clusterName, configPath string) error {
+	cfg, err := GetClusterConfig(configPath)
+	if err != nil {
+		return fmt.Errorf("configuration error: %w", err)
+	}
+	groups := make(map[string]NodeGroup)
+	for _, g := range cfg.Groups {
+		groups[g.NamePrefix] = g
+	}
+	desired := make(map[string]DesiredNode)
+	for _, dn := range cfg.DesiredNodes(clusterName) {
+		desired[dn.Name] = dn
+	}
+
+	fmt.Println("[CLOUD] Getting list of servers (API)...")
+	servers, err := client.GetServersList(ctx)
+	if err != nil {
+		return fmt.Errorf("API error: %w", err)
+	}
+
+	nameRe := regexp.MustCompile("^" + regexp.QuoteMeta(clusterName) + `-(.+)-(\d+)$`)
+	var nodes []state.NodeState
+	adopted := make(map[string]bool)
+	now := time.Now().Format(time.RFC3339)
+	for _, srv := range servers.Result {
+		m := nameRe.FindStringSubmatch(srv.Name)
+		if m == nil {
+			fmt.Printf("   [SKIP] %s: name does not match '%s-<prefix>-<n>'\n", srv.Name, clusterName)
+			continue
+		}
+		group, ok := groups[m[1]]
+		if !ok {
+			fmt.Printf("   [SKIP] %s: no group with name_prefix '%s' in config\n", srv.Name, m[1])
+			continue
+		}
+		idx, _ := strconv.Atoi(m[2])
+		if !group.Instances[idx].Enabled {
+			fmt.Printf("   [WARNING] %s: instance %d is not enabled in config, adopting anyway\n", srv.Name, idx)
+		}
+		group = group.ForInstance(idx)
+
+		ip, addrID, disks, created, err := fetchNodeDetails(ctx, client, srv.ID, nil, group.Disks)
+		if err != nil {
+			fmt.Printf("   [ERROR] %s: %v\n", srv.Name, err)
+			continue
+		}
+		labels := group.Labels
+		if dn, ok := desired[srv.Name]; ok {
+			labels = dn.Labels
+		}
+		nodes = append(nodes, state.NodeState{
+			Name: srv.Name, Role: group.Role, ID: srv.ID, IP: ip, AddressID: addrID, Labels: labels, Taints: group.Taints,
+			Disks: disks, Created: created, Updated: now,
+		})
+		for _, d := range disks {
+			adopted[d.ID] = true
+		}
+		fmt.Printf("   [+OK+] %s (%s, %s, %d disks)\n", srv.Name, group.Role, ip, len(disks))
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].Name < nodes[j].Name })
+
+	if vols, err := client.GetProjectVolumes(ctx); err == nil {
+		for _, v := range vols.Result {
+			if !adopted[v.ID] && v.AttachedToServer == nil {
+				fmt.Printf("   [INFO] Detached volume %s (%dGB, '%s') is not adopted.\n", v.ID, v.Size, v.Name)
+			}
+		}
+	}
+
+	if len(nodes) == 0 {
+		return errors.New("no servers matched, nothing to adopt")
+	}
+
+	current, err := backend.LoadState()
+	if err != nil {
+		return fmt.Errorf("state load error: %w", err)
+	}
+	if current != nil && len(current.Nodes) > 0 {
+		if !askForConfirmation(fmt.Sprintf("[WARNING] Cluster '%s' already has a state with %d nodes. Replace it?", clusterName, len(current.Nodes))) {
+			fmt.Println("Cancellation.")
+			return nil
+		}
+	}
+
+	err = backend.UpdateState(func(st *state.ClusterState) error {
+		*st = state.ClusterState{LastUpdated: time.Now(), SSHUser: cfg.SSHUser, Nodes: nodes}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("save error: %w", err)
+	}
+	fmt.Printf("[SAVE] Adopted %d nodes into state '%s'. Run -drift or -plan to review.\n", len(nodes), clusterName)
+	return nil
+}
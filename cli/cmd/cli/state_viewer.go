@@ -11,9 +11,9 @@ import (
 )
 
 // handleCheckState loads the state and outputs it
-func handleCheckState(backend *state.Backend, jsonOutput bool) {
+func handleCheckState(backend state.StateStore, jsonOutput bool) {
 	if backend == nil {
-		fmt.Println("[ERROR] S3 Backend not configured.")
+		fmt.Println("[ERROR] State backend not configured.")
 		return
 	}
 
@@ -29,6 +29,11 @@ func handleCheckState(backend *state.Backend, jsonOutput bool) {
 		return
 	}
 
+	printClusterState(st, jsonOutput)
+}
+
+// printClusterState outputs a state as a node table or as JSON
+func printClusterState(st *state.ClusterState, jsonOutput bool) {
 	if jsonOutput {
 		enc := json.NewEncoder(os.Stdout)
 		enc.SetIndent("", "  ")
@@ -12,7 +12,7 @@ import (
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 )
 
-func handleStatusRestoreDB(backend *state.Backend, clusterName, ns, pgClusterName string) {
+func handleStatusRestoreDB(backend state.StateStore, clusterName, ns, pgClusterName string) {
 	fmt.Printf("[SEARCH] Waiting for cluster restore '%s/%s'...\n", ns, pgClusterName)
 
 	st, err := backend.LoadState()
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"net"
 	"os"
@@ -15,7 +16,7 @@ import (
 )
 
 // handleAddUser adds a user
-func handleAddUser(client *clo.Client, backend *state.Backend, clusterName, inputStr string, forks int, limit string) {
+func handleAddUser(ctx context.Context, client *clo.Client, backend state.StateStore, clusterName, inputStr string, forks int, limit string) {
 	// 1. Parse the argument
 	parts := strings.SplitN(inputStr, ":", 2)
 	if len(parts) != 2 {
@@ -74,7 +75,7 @@ func handleAddUser(client *clo.Client, backend *state.Backend, clusterName, inpu
 }
 
 // handleOSUpdate runs system update
-func handleOSUpdate(client *clo.Client, backend *state.Backend, clusterName string, forks int, limit string) {
+func handleOSUpdate(ctx context.Context, client *clo.Client, backend state.StateStore, clusterName string, forks int, limit string) {
 	fmt.Println("[REFRESH] Preparing for OS update (apt dist-upgrade)...")
 
 	// 1. Load state
@@ -103,7 +104,7 @@ func handleOSUpdate(client *clo.Client, backend *state.Backend, clusterName stri
 
 // --- HELPERS ---
 
-func loadStateAndBastion(backend *state.Backend, clusterName string) (*state.ClusterState, error) {
+func loadStateAndBastion(backend state.StateStore, clusterName string) (*state.ClusterState, error) {
 	if backend == nil {
 		return nil, fmt.Errorf("backend unavailable")
 	}
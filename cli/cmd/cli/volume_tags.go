@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"maps"
+
+	"cli/internal/clo"
+)
+
+// Metadata keys that tie a data volume to its cluster, node and config disk
+const (
+	volTagCluster = "cluster"
+	volTagNode    = "node"
+	volTagDisk    = "disk"
+)
+
+// volumeName is the name of the data volume of a config disk: <node>-<disk>
+func volumeName(node, disk string) string {
+	return fmt.Sprintf("%s-%s", node, disk)
+}
+
+// volumeTags are the metadata of the data volume of a config disk
+func volumeTags(clusterName, node, disk string) map[string]string {
+	return map[string]string{volTagCluster: clusterName, volTagNode: node, volTagDisk: disk}
+}
+
+// foreignVolume reports whether a volume is tagged for another cluster
+func foreignVolume(v clo.DiskResult, clusterName string) bool {
+	c := v.Tags[volTagCluster]
+	return c != "" && c != clusterName
+}
+
+// tagVolumes names and tags the data volumes of the nodes whose metadata is missing or stale:
+// volumes created before disks had names, or reattached to another node
+func tagVolumes(ctx context.Context, client *clo.Client, clusterName string, nodes []NodeResult) {
+	vols, err := client.GetProjectVolumes(ctx)
+	if err != nil {
+		fmt.Printf("[WARNING] Volume tags not checked: %v\n", err)
+		return
+	}
+	byID := make(map[string]clo.DiskResult)
+	for _, v := range vols.Result {
+		byID[v.ID] = v
+	}
+	for _, n := range nodes {
+		for _, d := range n.Disks {
+			v, ok := byID[d.ID]
+			if d.Bootable || d.Name == "" || !ok {
+				continue
+			}
+			name, tags := volumeName(n.Name, d.Name), volumeTags(clusterName, n.Name, d.Name)
+			if v.Name == name && maps.Equal(v.Tags, tags) {
+				continue
+			}
+			if err := client.UpdateVolume(ctx, d.ID, name, tags); err != nil {
+				fmt.Printf("[WARNING] [%s] Volume %s not tagged: %v\n", n.Name, d.ID, err)
+				continue
+			}
+			fmt.Printf("[TAG] [%s] Volume %s tagged as disk '%s'.\n", n.Name, d.ID, d.Name)
+		}
+	}
+}
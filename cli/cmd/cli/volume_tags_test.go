@@ -0,0 +1,104 @@
+package main
+
+import (
+	"maps"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestVolumesAreNamedAndTagged(t *testing.T) {
+	e := newTestEnv(t, testConfig)
+	e.reconcile()
+	d, _ := dataDisk(e.node("test-master-1"))
+	if d.Name != "data" {
+		t.Fatalf("default disk name not recorded: %+v", d)
+	}
+	v, _ := e.fake.Volume(d.ID)
+	if v.Name != "test-master-1-data" || !maps.Equal(v.Tags, volumeTags(testCluster, "test-master-1", "data")) {
+		t.Fatalf("volume metadata: %s %v", v.Name, v.Tags)
+	}
+}
+
+func TestLegacyVolumesAreTagged(t *testing.T) {
+	e := newTestEnv(t, testConfig)
+	e.reconcile()
+	d, _ := dataDisk(e.node("test-master-2"))
+	if err := e.client.UpdateVolume(t.Context(), d.ID, "test-master-2-disk", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	e.reconcile()
+	v, _ := e.fake.Volume(d.ID)
+	if v.Name != "test-master-2-data" || v.Tags[volTagDisk] != "data" || v.Tags[volTagCluster] != testCluster {
+		t.Fatalf("legacy volume not tagged: %s %v", v.Name, v.Tags)
+	}
+}
+
+func TestForeignVolumesAreNotAdopted(t *testing.T) {
+	e := newTestEnv(t, testConfig)
+	e.reconcile()
+	lost, _ := dataDisk(e.node("test-master-1"))
+	e.fake.DeleteServerOutOfBand("test-master-1")
+	e.fake.DeleteVolumeOutOfBand(lost.ID)
+	foreign := e.fake.AddVolume("test-master-1-data", 50, volumeTags("other", "test-master-1", "data"))
+	untagged := e.fake.AddVolume("scratch", 50, nil)
+
+	e.reconcile()
+	d, _ := dataDisk(e.node("test-master-1"))
+	if d.ID == "" || d.ID == foreign || d.ID == untagged {
+		t.Fatalf("data disk %q: foreign %s, untagged %s", d.ID, foreign, untagged)
+	}
+	for _, id := range []string{foreign, untagged} {
+		if v, ok := e.fake.Volume(id); !ok || v.ServerID != "" {
+			t.Errorf("volume %s touched: %+v", id, v)
+		}
+	}
+}
+
+func TestTaggedVolumeIsReattachedWithoutState(t *testing.T) {
+	e := newTestEnv(t, testConfig)
+	e.reconcile()
+	old, _ := dataDisk(e.node("test-master-1"))
+	e.fake.DeleteServerOutOfBand("test-master-1")
+	st := e.state()
+	for i := range st.Nodes {
+		if st.Nodes[i].Name == "test-master-1" {
+			st.Nodes[i].Disks = nil
+		}
+	}
+	if err := e.backend.SaveState(*st); err != nil {
+		t.Fatal(err)
+	}
+
+	e.reconcile()
+	if d, _ := dataDisk(e.node("test-master-1")); d.ID != old.ID || d.MountPoint != "/data" {
+		t.Fatalf("tagged volume %s not reattached: %+v", old.ID, d)
+	}
+}
+
+func TestSameSizeDisksKeepTheirMountPoints(t *testing.T) {
+	cfg := strings.Replace(testConfig, "{size: 50, mount_point: /data}", "{size: 50, mount_point: /data}\n      - {size: 50, mount_point: /logs}", 1)
+	e := newTestEnv(t, cfg)
+	e.reconcile()
+	for _, n := range []string{"test-master-1", "test-master-2"} {
+		seen := map[string]bool{}
+		for _, d := range e.node(n).Disks {
+			if d.Bootable {
+				continue
+			}
+			v, _ := e.fake.Volume(d.ID)
+			if seen[d.MountPoint] || "/"+d.Name != d.MountPoint || v.Tags[volTagDisk] != d.Name {
+				t.Fatalf("%s: disk %+v, volume tags %v", n, d, v.Tags)
+			}
+			seen[d.MountPoint] = true
+		}
+		if len(seen) != 2 {
+			t.Fatalf("%s: mount points %v", n, seen)
+		}
+	}
+	os.WriteFile(e.config, []byte(cfg), 0644)
+	if p := e.plan(); p.HasChanges() {
+		t.Fatalf("second plan has changes: %+v", p)
+	}
+}
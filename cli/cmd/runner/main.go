@@ -206,6 +206,27 @@ const PermissionsYml = `
         - item.owner is defined or item.group is defined or item.mode is defined
 `
 
+// GrowFsYml grows the ext4 filesystem of every data disk to the size of its volume, online
+const GrowFsYml = `
+---
+- hosts: all
+  become: true
+  gather_facts: false
+  tasks:
+    - name: Rescan the size of the data disks
+      shell: "echo 1 > /sys/class/block/{{ item.device | basename }}/device/rescan"
+      loop: "{{ data_disks | default([], true) }}"
+      changed_when: false
+      failed_when: false
+
+    - name: Grow ext4 to the volume size
+      filesystem:
+        fstype: ext4
+        dev: "{{ item.device }}"
+        resizefs: yes
+      loop: "{{ data_disks | default([], true) }}"
+`
+
 func main() {
 	if len(os.Args) < 2 {
 		parent("kubespray", []string{})
@@ -224,6 +245,8 @@ func main() {
 		parent("kubespray", append([]string{"mount"}, restArgs...))
 	case "remove-node":
 		parent("kubespray", append([]string{"remove-node"}, restArgs...))
+	case "scale":
+		parent("kubespray", append([]string{"scale"}, restArgs...))
 	case "run":
 		parent("kubespray", restArgs)
 	case "create-user":
@@ -232,6 +255,8 @@ func main() {
 		parent("kubespray", append([]string{"os-update"}, restArgs...))
 	case "permissions":
 		parent("kubespray", append([]string{"permissions"}, restArgs...))
+	case "grow-fs":
+		parent("kubespray", append([]string{"grow-fs"}, restArgs...))
 	case "child":
 		mode := "kubespray"
 		if len(os.Args) > 2 {
@@ -241,7 +266,9 @@ func main() {
 		if len(os.Args) > 3 {
 			childArgs = os.Args[3:]
 		}
-		child(mode, childArgs)
+		if err := child(mode, childArgs); err != nil {
+			os.Exit(1)
+		}
 	default:
 		parent("kubespray", os.Args[1:])
 	}
@@ -338,7 +365,9 @@ func parent(mode string, args []string) {
 	}
 }
 
-func child(mode string, args []string) {
+// child runs the playbook of mode inside the runner FS. An error means the playbook failed
+// and the process must exit non-zero; modes that report failures interactively return nil.
+func child(mode string, args []string) error {
 	var rootFS string
 	if mode == "flux" {
 		rootFS = FluxFS
@@ -390,13 +419,17 @@ func child(mode string, args []string) {
 			case "mount":
 				runDiskSetup(args[1:])
 			case "remove-node":
-				runRemoveNode(args[1:])
+				return runRemoveNode(args[1:])
+			case "scale":
+				return runScale(args[1:])
 			case "create-user":
 				runCreateUser(args[1:])
 			case "os-update":
 				runOSUpdate(args[1:])
 			case "permissions":
 				runPermissions(args[1:])
+			case "grow-fs":
+				return runGrowFs(args[1:])
 			default:
 				runKubespraySmart(args)
 			}
@@ -404,6 +437,7 @@ func child(mode string, args []string) {
 			runKubespraySmart(args)
 		}
 	}
+	return nil
 }
 
 func runPermissions(args []string) {
@@ -610,21 +644,22 @@ func runKubespraySmart(args []string) {
 	}
 }
 
-func runRemoveNode(args []string) {
+func runRemoveNode(args []string) error {
 	fs := flag.NewFlagSet("remove-node", flag.ContinueOnError)
 	nodeNamePtr := fs.String("node", "", "Node name to remove")
 	resetPtr := fs.Bool("reset", true, "Reset node (drain/delete)")
 	ungracefulPtr := fs.Bool("ungraceful", false, "Allow ungraceful removal")
+	yesPtr := fs.Bool("yes", false, "Skip the confirmation prompt of remove-node.yml")
 	fs.Parse(args)
 
 	if *nodeNamePtr == "" {
 		fmt.Println("[ERROR] Error: Node name not specified (-node)")
-		return
+		return fmt.Errorf("no node name")
 	}
 
 	ansibleBin, env, keyPath := setupAnsibleEnv()
 	if ansibleBin == "" {
-		return
+		return fmt.Errorf("ansible-playbook not found")
 	}
 
 	workDir := "/"
@@ -648,15 +683,65 @@ func runRemoveNode(args []string) {
 	if *ungracefulPtr {
 		cmdArgs = append(cmdArgs, "-e", "allow_ungraceful_removal=true")
 	}
+	if *yesPtr {
+		cmdArgs = append(cmdArgs, "-e", "skip_confirmation=yes")
+	}
 	cmdArgs = append(cmdArgs, "--become", "--become-user=root")
 
 	fmt.Printf("[TRASH_CAN] Launching Graceful Removal for node: %s\n", *nodeNamePtr)
 
 	if err := runAnsible(ansibleBin, env, cmdArgs); err != nil {
 		fmt.Printf("\n[ERROR] remove-node error: %v\n", err)
-	} else {
-		fmt.Println("\n[+OK+] Node successfully removed from cluster.")
+		return err
+	}
+	fmt.Println("\n[+OK+] Node successfully removed from cluster.")
+	return nil
+}
+
+// runScale joins the hosts given by -l to the cluster with kubespray scale.yml
+func runScale(args []string) error {
+	fs := flag.NewFlagSet("scale", flag.ContinueOnError)
+	forksPtr := fs.Int("f", 5, "Forks")
+	limitPtr := fs.String("l", "", "Hosts to add")
+	fs.Parse(args)
+
+	if *limitPtr == "" {
+		fmt.Println("[ERROR] Error: hosts not specified (-l)")
+		return fmt.Errorf("no hosts")
+	}
+
+	ansibleBin, env, keyPath := setupAnsibleEnv()
+	if ansibleBin == "" {
+		return fmt.Errorf("ansible-playbook not found")
+	}
+
+	workDir := "/"
+	for _, d := range []string{"/kubespray", "/runner/project", "/"} {
+		if _, err := os.Stat(filepath.Join(d, "scale.yml")); err == nil {
+			workDir = d
+			break
+		}
 	}
+	os.Chdir(workDir)
+
+	cmdArgs := []string{
+		"-i", "/inventory.yaml",
+		"--private-key", keyPath,
+		"scale.yml",
+		"-e", `{"download_run_once":false,"download_localhost":false,"download_force_cache":false}`,
+		"-f", fmt.Sprintf("%d", *forksPtr),
+		"--limit", *limitPtr,
+		"--become", "--become-user=root",
+	}
+
+	fmt.Printf("[PLUS] Scaling the cluster with: %s\n", *limitPtr)
+
+	if err := runAnsible(ansibleBin, env, cmdArgs); err != nil {
+		fmt.Printf("\n[ERROR] scale error: %v\n", err)
+		return err
+	}
+	fmt.Println("\n[+OK+] Nodes joined the cluster.")
+	return nil
 }
 
 func runDiskSetup(args []string) {
@@ -687,6 +772,34 @@ func runDiskSetup(args []string) {
 	}
 }
 
+// runGrowFs grows the filesystems of the data disks after their volumes were extended
+func runGrowFs(args []string) error {
+	fs := flag.NewFlagSet("grow-fs", flag.ContinueOnError)
+	forksPtr := fs.Int("f", 5, "Forks")
+	limitPtr := fs.String("l", "", "Limit hosts")
+	fs.Parse(args)
+
+	ansibleBin, env, keyPath := setupAnsibleEnv()
+	if ansibleBin == "" {
+		return fmt.Errorf("ansible-playbook not found")
+	}
+
+	fmt.Println("[DISK/STORAGE] [TASK] Growing filesystems (online)...")
+	os.WriteFile("/grow-fs.yml", []byte(GrowFsYml), 0644)
+
+	cmdArgs := []string{"-i", "/inventory.yaml", "--private-key", keyPath, "/grow-fs.yml", "-f", fmt.Sprintf("%d", *forksPtr)}
+	if *limitPtr != "" {
+		cmdArgs = append(cmdArgs, "--limit", *limitPtr)
+	}
+
+	if err := runAnsible(ansibleBin, env, cmdArgs); err != nil {
+		fmt.Printf("[ERROR] Error: %v\n", err)
+		return err
+	}
+	fmt.Println("[+OK+] Filesystems grown.")
+	return nil
+}
+
 func runAnsible(bin string, env []string, args []string) error {
 	cmd := exec.Command(bin, args...)
 	cmd.Env = env
@@ -3,6 +3,7 @@ module cli
 go 1.25.3
 
 require (
+	filippo.io/age v1.2.1
 	github.com/google/go-containerregistry v0.20.7
 	github.com/minio/minio-go/v7 v7.0.97
 	golang.org/x/crypto v0.45.0
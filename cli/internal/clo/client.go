@@ -2,10 +2,15 @@ package clo
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	mrand "math/rand"
+	"net"
 	"net/http"
+	"strconv"
 	"time"
 )
 
@@ -15,6 +20,29 @@ type Client struct {
 	BaseURL    string
 	AuthToken  string
 	ProjectID  string
+	Retry      RetryPolicy
+	PageSize   int        // items per page of list calls (0 = DefaultPageSize)
+	Limits     RateLimits // read before the first call; change it only right after NewClient
+
+	lim limiter
+}
+
+// RetryPolicy controls how failed API calls are retried
+type RetryPolicy struct {
+	MaxAttempts int           // total attempts per call, including the first one
+	BaseDelay   time.Duration // first backoff, doubled on every retry (with jitter)
+	MaxDelay    time.Duration // cap for the backoff and for Retry-After
+	CallTimeout time.Duration // deadline of one call including all retries (0 = none)
+}
+
+// DefaultRetryPolicy gives up after about 5 minutes of retrying
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: 8,
+		BaseDelay:   2 * time.Second,
+		MaxDelay:    60 * time.Second,
+		CallTimeout: 5 * time.Minute,
+	}
 }
 
 // NewClient creates a new client instance
@@ -24,11 +52,18 @@ func NewClient(token, projectID string) *Client {
 		BaseURL:    "https://api.clo.ru/v2",
 		AuthToken:  token,
 		ProjectID:  projectID,
+		Retry:      DefaultRetryPolicy(),
+		Limits:     DefaultRateLimits(),
 	}
 }
 
-// sendRequest - internal method with automatic retries (Retries)
-func (c *Client) sendRequest(method, path string, payload interface{}) ([]byte, int, error) {
+// sendRequest sends one API call and retries it according to c.Retry.
+// 5xx, 429 and network errors are retried for idempotent methods. A POST is only retried
+// when the server certainly did not process it (429/503, or the connection was never made),
+// so a lost response to CreateServer cannot create a second server.
+// A final 4xx/5xx response is returned as *APIError.
+// Every attempt first takes a token of its call class (see RateLimits).
+func (c *Client) sendRequest(ctx context.Context, method, path string, payload interface{}) ([]byte, int, error) {
 	var jsonPayload []byte
 	var err error
 
@@ -40,11 +75,35 @@ func (c *Client) sendRequest(method, path string, payload interface{}) ([]byte,
 		}
 	}
 
-	// Retry settings
-	maxRetries := 50              // Try 5 times
-	baseDelay := 25 * time.Second // Wait 3 seconds between attempts
+	policy := c.Retry
+	if policy.MaxAttempts <= 0 {
+		policy = DefaultRetryPolicy()
+	}
+	if policy.CallTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, policy.CallTimeout)
+		defer cancel()
+	}
+	idempotent := method != http.MethodPost
+	if method != http.MethodGet {
+		// Whatever the outcome, the cached lists of this run may no longer match the project
+		defer listCacheFrom(ctx).clear()
+	}
+
+	lim, class := c.limiter(), callClass(ctx, method)
+
+	var lastErr error
+	for attempt := 1; attempt <= policy.MaxAttempts; attempt++ {
+		waited, err := lim.buckets[class].wait(ctx)
+		if err != nil {
+			return nil, 0, fmt.Errorf("%s %s: %w", method, path, err)
+		}
+		lim.throttled[class].Add(int64(waited))
+		lim.calls[class].Add(1)
+		if attempt > 1 {
+			lim.retries[class].Add(1)
+		}
 
-	for attempt := 0; attempt < maxRetries; attempt++ {
 		// 2. Important: BodyReader must be recreated on each iteration,
 		// as it is "read out" when sent
 		var bodyReader io.Reader
@@ -52,8 +111,7 @@ func (c *Client) sendRequest(method, path string, payload interface{}) ([]byte,
 			bodyReader = bytes.NewBuffer(jsonPayload)
 		}
 
-		url := c.BaseURL + path
-		req, err := http.NewRequest(method, url, bodyReader)
+		req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+path, bodyReader)
 		if err != nil {
 			return nil, 0, fmt.Errorf("creating request: %w", err)
 		}
@@ -64,9 +122,18 @@ func (c *Client) sendRequest(method, path string, payload interface{}) ([]byte,
 		// 3. Send request
 		resp, err := c.HTTPClient.Do(req)
 		if err != nil {
-			// Network error (e.g., timeout or disconnection). Log and retry.
-			fmt.Printf("[WARNING] [Attempt %d/%d] Network error: %v. Retrying in %v...\n", attempt+1, maxRetries, err, baseDelay)
-			time.Sleep(baseDelay)
+			if ctx.Err() != nil {
+				return nil, 0, fmt.Errorf("%s %s: %w", method, path, ctx.Err())
+			}
+			if !idempotent && !requestNotSent(err) {
+				return nil, 0, fmt.Errorf("%s %s: network error, request may have been processed, not retrying: %w", method, path, err)
+			}
+			lastErr = err
+			delay := policy.backoff(attempt, 0)
+			fmt.Printf("[WARNING] [Attempt %d/%d] Network error: %v. Retrying in %v...\n", attempt, policy.MaxAttempts, err, delay)
+			if err := sleepCtx(ctx, delay); err != nil {
+				return nil, 0, err
+			}
 			continue
 		}
 
@@ -74,44 +141,114 @@ func (c *Client) sendRequest(method, path string, payload interface{}) ([]byte,
 		body, readErr := io.ReadAll(resp.Body)
 		resp.Body.Close() // Close immediately after reading
 		if readErr != nil {
-			fmt.Printf("[WARNING] [Attempt %d/%d] Error reading body: %v. Retrying...\n", attempt+1, maxRetries, readErr)
-			time.Sleep(baseDelay)
+			if !idempotent {
+				return nil, resp.StatusCode, fmt.Errorf("%s %s: reading response: %w", method, path, readErr)
+			}
+			lastErr = readErr
+			delay := policy.backoff(attempt, 0)
+			fmt.Printf("[WARNING] [Attempt %d/%d] Error reading body: %v. Retrying in %v...\n", attempt, policy.MaxAttempts, readErr, delay)
+			if err := sleepCtx(ctx, delay); err != nil {
+				return nil, 0, err
+			}
 			continue
 		}
 
-		// 4. Check status
-		// If it's 5xx (Internal Server Error, Gateway Timeout, etc.) - RETRY
-		if resp.StatusCode >= 500 {
-			fmt.Printf("[FIRE] [Attempt %d/%d] API Error %d: %s. Retrying in %v...\n", attempt+1, maxRetries, resp.StatusCode, string(body), baseDelay)
-			time.Sleep(baseDelay)
-			continue
+		// 4. Check status: 429 and 5xx are retried, everything else is returned to the caller
+		if resp.StatusCode == http.StatusTooManyRequests {
+			lim.rateLimited[class].Add(1)
+		}
+		retryable := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable ||
+			(idempotent && resp.StatusCode >= 500)
+		if !retryable || attempt == policy.MaxAttempts {
+			if resp.StatusCode >= 400 {
+				return body, resp.StatusCode, newAPIError(method, path, resp.StatusCode, resp.Header, body)
+			}
+			return body, resp.StatusCode, nil
 		}
 
-		// If it's 429 (Too Many Requests) - should also retry
-		if resp.StatusCode == 429 {
-			fmt.Printf("[TIME] [Attempt %d/%d] Rate Limit (429). Retrying in %v...\n", attempt+1, maxRetries, baseDelay)
-			time.Sleep(baseDelay + 2*time.Second) // Wait a bit longer
-			continue
+		lastErr = fmt.Errorf("status %d", resp.StatusCode)
+		delay := policy.backoff(attempt, parseRetryAfter(resp.Header.Get("Retry-After")))
+		if resp.StatusCode == http.StatusTooManyRequests {
+			fmt.Printf("[TIME] [Attempt %d/%d] Rate Limit (429). Retrying in %v...\n", attempt, policy.MaxAttempts, delay)
+		} else {
+			fmt.Printf("[FIRE] [Attempt %d/%d] API Error %d: %s. Retrying in %v...\n", attempt, policy.MaxAttempts, resp.StatusCode, string(body), delay)
 		}
+		if err := sleepCtx(ctx, delay); err != nil {
+			return nil, 0, err
+		}
+	}
 
-		// If it's 2xx, 400, 401, 404, etc. (client errors or success) - return result
-		return body, resp.StatusCode, nil
+	return nil, 0, fmt.Errorf("%s %s failed after %d attempts: %v", method, path, policy.MaxAttempts, lastErr)
+}
+
+// backoff returns the delay before the next attempt: exponential with jitter,
+// at least retryAfter, capped at MaxDelay
+func (p RetryPolicy) backoff(attempt int, retryAfter time.Duration) time.Duration {
+	d := p.BaseDelay << (attempt - 1)
+	if d <= 0 || (p.MaxDelay > 0 && d > p.MaxDelay) {
+		d = p.MaxDelay
+	}
+	// Equal jitter: half fixed, half random, so parallel goroutines spread out
+	if half := int64(d / 2); half > 0 {
+		d = time.Duration(half + mrand.Int63n(half+1))
 	}
+	if retryAfter > d {
+		d = retryAfter
+	}
+	if p.MaxDelay > 0 && d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	return d
+}
 
-	return nil, 0, fmt.Errorf("failed after %d attempts", maxRetries)
+// parseRetryAfter understands both forms of the header: seconds and an HTTP date
+func parseRetryAfter(v string) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return time.Until(t)
+	}
+	return 0
+}
+
+// requestNotSent reports whether a transport error happened before the request reached the
+// server (DNS failure, refused connection), so even a non-idempotent call is safe to repeat
+func requestNotSent(err error) bool {
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return true
+	}
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// sleepCtx waits for d or until ctx is done
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
 }
 
 // DeleteAddress deletes an IP address by ID
-func (c *Client) DeleteAddress(addrID string) error {
+func (c *Client) DeleteAddress(ctx context.Context, addrID string) error {
 	path := fmt.Sprintf("/addresses/%s", addrID)
 
-	body, status, err := c.sendRequest("DELETE", path, nil)
+	body, status, err := c.sendRequest(ctx, "DELETE", path, nil)
 	if err != nil {
 		return err
 	}
 
 	if status != http.StatusNoContent && status != http.StatusOK {
-		return fmt.Errorf("delete address failed. Status: %d, Body: %s", status, string(body))
+		return newAPIError("DELETE", path, status, nil, body)
 	}
 	return nil
 }
@@ -0,0 +1,327 @@
+package clo_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"cli/internal/clo"
+	"cli/internal/clo/clotest"
+)
+
+func testServer(name string) clo.CreateServerRequest {
+	return clo.CreateServerRequest{
+		Name:      name,
+		Flavor:    clo.ServerFlavor{RAM: 2, VCPUs: 1, CPUType: clo.CPUShared},
+		Storages:  []clo.ServerStorage{{Bootable: true, StorageType: "storage", Size: 10}},
+		Addresses: []clo.ServerAddress{clo.NewAddress(false)},
+		Image:     clotest.UbuntuImage,
+	}
+}
+
+func TestIdempotentCallsAreRetried(t *testing.T) {
+	fake := clotest.NewServer(t)
+	fake.Fail("GET", "/projects/", http.StatusBadGateway, 2)
+	fake.Fail("GET", "/projects/", http.StatusTooManyRequests, 1)
+
+	if _, err := fake.Client().GetServersList(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+	if got := fake.CountCalls("GET", "/projects/"); got != 4 {
+		t.Fatalf("%d calls, want 4", got)
+	}
+}
+
+func TestPostIsNotRetriedOnServerError(t *testing.T) {
+	fake := clotest.NewServer(t)
+	fake.Fail("POST", "/projects/", http.StatusInternalServerError, 1)
+
+	_, err := fake.Client().CreateServer(t.Context(), testServer("x"))
+	var apiErr *clo.APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusInternalServerError || apiErr.Code != "injected" {
+		t.Fatalf("expected *APIError 500, got %v", err)
+	}
+	if apiErr.Method != "POST" || apiErr.RequestID == "" {
+		t.Fatalf("incomplete error: %+v", apiErr)
+	}
+	if got := fake.CountCalls("POST", "/projects/"); got != 1 {
+		t.Fatalf("%d POSTs, a 500 on create must not be repeated", got)
+	}
+}
+
+func TestPostIsRetriedOnRateLimit(t *testing.T) {
+	fake := clotest.NewServer(t)
+	fake.Fail("POST", "/projects/", http.StatusTooManyRequests, 2)
+
+	if _, err := fake.Client().CreateServer(t.Context(), testServer("x")); err != nil {
+		t.Fatal(err)
+	}
+	if len(fake.Servers()) != 1 {
+		t.Fatalf("%d servers, want exactly 1", len(fake.Servers()))
+	}
+}
+
+func TestRetriesGiveUp(t *testing.T) {
+	fake := clotest.NewServer(t)
+	fake.Fail("GET", "/projects/", http.StatusServiceUnavailable, 100)
+	c := fake.Client()
+	c.Retry.MaxAttempts = 3
+
+	if _, err := c.GetServersList(t.Context()); err == nil {
+		t.Fatal("expected an error")
+	}
+	if got := fake.CountCalls("GET", "/projects/"); got != 3 {
+		t.Fatalf("%d calls, want 3", got)
+	}
+}
+
+func TestCanceledContextStopsRetries(t *testing.T) {
+	fake := clotest.NewServer(t)
+	fake.Fail("GET", "/projects/", http.StatusServiceUnavailable, 100)
+	c := fake.Client()
+	c.Retry.BaseDelay, c.Retry.MaxDelay = time.Hour, time.Hour
+
+	ctx, cancel := context.WithTimeout(t.Context(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := c.GetServersList(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+}
+
+func TestErrorHelpers(t *testing.T) {
+	fake := clotest.NewServer(t)
+	c := fake.Client()
+
+	if _, err := c.GetServerDetail(t.Context(), "missing"); !clo.IsNotFound(err) {
+		t.Fatalf("IsNotFound(%v) = false", err)
+	}
+
+	id, _ := c.CreateServer(t.Context(), testServer("a"))
+	detail, _ := c.GetServerDetail(t.Context(), id)
+	if err := c.DeleteVolume(t.Context(), detail.Result.Storages[0].ID); !clo.IsConflict(err) {
+		t.Fatalf("deleting an attached volume: IsConflict(%v) = false", err)
+	}
+
+	fake.MaxServers = 1
+	_, err := c.CreateServer(t.Context(), testServer("b"))
+	if !clo.IsQuota(err) || clo.IsNotFound(err) {
+		t.Fatalf("IsQuota(%v) = false", err)
+	}
+}
+
+func TestCanResizeLeavesTheServerAlone(t *testing.T) {
+	fake := clotest.NewServer(t)
+	c := fake.Client()
+	id, err := c.CreateServer(t.Context(), testServer("a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	current := clo.ServerFlavor{RAM: 2, VCPUs: 1, CPUType: clo.CPUShared}
+
+	for _, enabled := range []bool{false, true} {
+		fake.ResizeEnabled = enabled
+		if ok, err := c.CanResize(t.Context(), id, current); err != nil || ok != enabled {
+			t.Errorf("resize enabled %v: CanResize %v, %v", enabled, ok, err)
+		}
+	}
+	if srv, _ := fake.ServerByName("a"); srv.Status == "STOPPED" || srv.RAM != 2 {
+		t.Fatalf("server changed by the check: %+v", srv)
+	}
+
+	// An API that resizes a running server must not pass for one that supports resize
+	fake.ResizeRunning = true
+	if ok, err := c.CanResize(t.Context(), id, current); err == nil || ok {
+		t.Fatalf("accepted resize: CanResize %v, %v", ok, err)
+	}
+}
+
+func TestWaitForStatus(t *testing.T) {
+	fake := clotest.NewServer(t)
+	fake.BuildPolls = 3
+	fake.FailBuild["bad"] = 1
+	c := fake.Client()
+
+	good, _ := c.CreateServer(t.Context(), testServer("good"))
+	if status, _, _, err := c.WaitForStatus(t.Context(), good, []string{"ACTIVE"}, 10, time.Millisecond); err != nil || status != "ACTIVE" {
+		t.Fatalf("good: %s, %v", status, err)
+	}
+
+	bad, _ := c.CreateServer(t.Context(), testServer("bad"))
+	if status, _, _, err := c.WaitForStatus(t.Context(), bad, []string{"ACTIVE"}, 10, time.Millisecond); err == nil || status != "ERROR" {
+		t.Fatalf("bad: %s, %v", status, err)
+	}
+
+	fake.DeleteServerOutOfBand("bad")
+	if status, _, _, err := c.WaitForStatus(t.Context(), bad, []string{"ACTIVE"}, 10, time.Millisecond); !clo.IsNotFound(err) || status != "DELETED" {
+		t.Fatalf("deleted: %s, %v", status, err)
+	}
+}
+
+func TestInvalidServerRequestIsNotSent(t *testing.T) {
+	fake := clotest.NewServer(t)
+	req := testServer("x")
+	req.Flavor.CPUType = "TURBO"
+	req.Image = "ubuntu"
+	req.Storages = append(req.Storages, clo.ServerStorage{Bootable: true, StorageType: "storage", Size: 10})
+
+	_, err := fake.Client().CreateServer(t.Context(), req)
+	if err == nil {
+		t.Fatal("expected a validation error")
+	}
+	for _, want := range []string{"cpu_type", "image", "bootable"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error does not mention %s: %v", want, err)
+		}
+	}
+	if n := len(fake.Calls()); n != 0 {
+		t.Fatalf("%d API calls for an invalid request", n)
+	}
+}
+
+func TestServerRequestEncoding(t *testing.T) {
+	req := testServer("x")
+	req.Addresses = []clo.ServerAddress{clo.ExistingAddress("addr-1"), clo.NewAddress(true)}
+	data, _ := json.Marshal(req.Addresses)
+	want := `[{"address_id":"addr-1"},{"ddos_protection":false,"external":true,"version":4,"bandwidth_max_mbps":1024}]`
+	if string(data) != want {
+		t.Fatalf("addresses encode as %s, want %s", data, want)
+	}
+}
+
+func TestListCallsReadAllPages(t *testing.T) {
+	fake := clotest.NewServer(t)
+	fake.PageSize = 2
+	c := fake.Client()
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		if _, err := c.CreateServer(t.Context(), testServer(name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	list, err := c.GetServersList(t.Context())
+	if err != nil || list.Count != 5 || len(list.Result) != 5 || list.Result[4].Name != "e" {
+		t.Fatalf("servers: %+v, %v", list, err)
+	}
+	if got := fake.CountCalls("GET", "/projects/"+clotest.ProjectID+"/servers"); got != 3 {
+		t.Fatalf("%d page reads, want 3", got)
+	}
+	vols, err := c.GetProjectVolumes(t.Context())
+	if err != nil || len(vols.Result) != 5 {
+		t.Fatalf("volumes past the first page are missing: %d, %v", len(vols.Result), err)
+	}
+}
+
+func TestListStopsWhenOffsetIsIgnored(t *testing.T) {
+	fake := clotest.NewServer(t)
+	fake.PageSize = 2
+	fake.IgnoreOffset = true
+	c := fake.Client()
+	for _, name := range []string{"a", "b", "c"} {
+		if _, err := c.CreateServer(t.Context(), testServer(name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	list, err := c.GetServersList(t.Context())
+	if err != nil || len(list.Result) != 2 {
+		t.Fatalf("servers: %+v, %v", list, err)
+	}
+	if got := fake.CountCalls("GET", "/projects/"+clotest.ProjectID+"/servers"); got != 2 {
+		t.Fatalf("%d page reads, want 2", got)
+	}
+}
+
+func TestFreeAddressLookupIsNotCached(t *testing.T) {
+	fake := clotest.NewServer(t)
+	c := fake.Client()
+	ctx := clo.WithListCache(t.Context())
+	if _, err := c.GetProjectAddressesMap(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	free := fake.AddExternalAddress()
+	if id, err := c.FindAvailableExternalIP(ctx); err != nil || id != free {
+		t.Fatalf("free address %q, %v, want %s", id, err, free)
+	}
+}
+
+func TestListCacheIsDroppedByWrites(t *testing.T) {
+	fake := clotest.NewServer(t)
+	c := fake.Client()
+	ctx := clo.WithListCache(t.Context())
+	listed := func() int {
+		t.Helper()
+		list, err := c.GetServersList(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(list.Result)
+	}
+
+	listed()
+	listed()
+	if got := fake.CountCalls("GET", "/projects/"+clotest.ProjectID+"/servers"); got != 1 {
+		t.Fatalf("%d list calls, the second one should be cached", got)
+	}
+	if _, err := c.CreateServer(ctx, testServer("a")); err != nil {
+		t.Fatal(err)
+	}
+	if n := listed(); n != 1 {
+		t.Fatalf("%d servers after a create, the cache was not dropped", n)
+	}
+
+	fake.DeleteServerOutOfBand("a")
+	clo.InvalidateListCache(ctx)
+	if n := listed(); n != 0 {
+		t.Fatalf("%d servers after invalidation", n)
+	}
+}
+
+func TestRateLimitIsSharedByGoroutines(t *testing.T) {
+	fake := clotest.NewServer(t)
+	c := fake.Client()
+	c.Limits.Read = clo.RateLimit{PerSecond: 50, Burst: 1}
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for range 6 {
+		wg.Go(func() { c.GetServerDetail(t.Context(), "missing") })
+	}
+	wg.Wait()
+	// 1 call from the burst, 5 more at 20ms intervals
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Fatalf("6 calls took %v, the limiter did not space them", elapsed)
+	}
+	if s := c.Stats()[clo.ClassRead]; s.Calls != 6 || s.Throttled < 90*time.Millisecond {
+		t.Fatalf("read stats: %+v", s)
+	}
+}
+
+func TestStatsByCallClass(t *testing.T) {
+	fake := clotest.NewServer(t)
+	fake.Fail("GET", "/projects/", http.StatusTooManyRequests, 1)
+	c := fake.Client()
+
+	c.GetServersList(t.Context())
+	id, _ := c.CreateServer(t.Context(), testServer("a"))
+	c.WaitForStatus(t.Context(), id, []string{"ACTIVE"}, 10, time.Millisecond)
+
+	s := c.Stats()
+	if r := s[clo.ClassRead]; r.Calls != 2 || r.Retries != 1 || r.RateLimited != 1 {
+		t.Fatalf("read stats: %+v", r)
+	}
+	if w := s[clo.ClassWrite]; w.Calls != 1 || w.Retries != 0 {
+		t.Fatalf("write stats: %+v", w)
+	}
+	if p := s[clo.ClassPoll]; p.Calls == 0 {
+		t.Fatalf("status polls not counted as polls: %+v", s)
+	}
+	if !strings.Contains(s.String(), "read 2 (1 retried, 1 rate limited") {
+		t.Fatalf("stats line: %s", s)
+	}
+}
@@ -1,22 +1,23 @@
 package clo
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 )
 
 // GetVolumeDetail gets information about a specific disk by ID
-func (c *Client) GetVolumeDetail(volumeID string) (*DiskResult, error) {
+func (c *Client) GetVolumeDetail(ctx context.Context, volumeID string) (*DiskResult, error) {
 	path := fmt.Sprintf("/volumes/%s", volumeID)
 
-	body, status, err := c.sendRequest("GET", path, nil)
+	body, status, err := c.sendRequest(ctx, "GET", path, nil)
 	if err != nil {
 		return nil, err
 	}
 
 	if status != http.StatusOK {
-		return nil, fmt.Errorf("get volume detail failed. Status: %d", status)
+		return nil, newAPIError("GET", path, status, nil, body)
 	}
 
 	var resp DiskDetailResponse
@@ -27,27 +28,16 @@ func (c *Client) GetVolumeDetail(volumeID string) (*DiskResult, error) {
 }
 
 // GetProjectVolumes gets a list of all volumes in the project
-func (c *Client) GetProjectVolumes() (*DiskListResponse, error) {
-	path := fmt.Sprintf("/projects/%s/volumes", c.ProjectID)
-
-	body, status, err := c.sendRequest("GET", path, nil)
+func (c *Client) GetProjectVolumes(ctx context.Context) (*DiskListResponse, error) {
+	items, err := listAll[DiskResult](ctx, c, fmt.Sprintf("/projects/%s/volumes", c.ProjectID))
 	if err != nil {
 		return nil, err
 	}
-
-	if status != http.StatusOK {
-		return nil, fmt.Errorf("get volumes failed. Status: %d", status)
-	}
-
-	var resp DiskListResponse
-	if err := json.Unmarshal(body, &resp); err != nil {
-		return nil, err
-	}
-	return &resp, nil
+	return &DiskListResponse{Count: len(items), Result: items}, nil
 }
 
 // DeleteVolume deletes a volume by ID
-func (c *Client) DeleteVolume(volumeID string) error {
+func (c *Client) DeleteVolume(ctx context.Context, volumeID string) error {
 	path := fmt.Sprintf("/volumes/%s", volumeID)
 
 	payload := DeleteVolumePayload{
@@ -55,19 +45,46 @@ func (c *Client) DeleteVolume(volumeID string) error {
 		Force:      false,
 	}
 
-	body, status, err := c.sendRequest("DELETE", path, payload)
+	body, status, err := c.sendRequest(ctx, "DELETE", path, payload)
 	if err != nil {
 		return err
 	}
 
 	if status != http.StatusNoContent && status != http.StatusOK && status != http.StatusAccepted {
-		return fmt.Errorf("delete volume failed. Status: %d, Body: %s", status, string(body))
+		return newAPIError("DELETE", path, status, nil, body)
+	}
+	return nil
+}
+
+// UpdateVolume renames a volume and replaces its metadata
+func (c *Client) UpdateVolume(ctx context.Context, volumeID, name string, tags map[string]string) error {
+	path := fmt.Sprintf("/volumes/%s", volumeID)
+	body, status, err := c.sendRequest(ctx, "PATCH", path, UpdateVolumeRequest{Name: name, Tags: tags})
+	if err != nil {
+		return err
+	}
+	if status != http.StatusOK && status != http.StatusAccepted && status != http.StatusNoContent {
+		return newAPIError("PATCH", path, status, nil, body)
+	}
+	return nil
+}
+
+// ExtendVolume grows a volume to size GB, attached or not; it is EXTENDING until done.
+// The filesystem on it is not touched.
+func (c *Client) ExtendVolume(ctx context.Context, volumeID string, size int) error {
+	path := fmt.Sprintf("/volumes/%s/extend", volumeID)
+	body, status, err := c.sendRequest(ctx, "POST", path, map[string]int{"size": size})
+	if err != nil {
+		return err
+	}
+	if status != http.StatusOK && status != http.StatusAccepted && status != http.StatusNoContent {
+		return newAPIError("POST", path, status, nil, body)
 	}
 	return nil
 }
 
 // AttachVolume attaches an existing disk to a server and returns the device path (/dev/...)
-func (c *Client) AttachVolume(volumeID, serverID string) (string, error) {
+func (c *Client) AttachVolume(ctx context.Context, volumeID, serverID string) (string, error) {
 	path := fmt.Sprintf("/volumes/%s/attach", volumeID)
 
 	// Form the JSON payload: {'server_id': '...'}
@@ -75,13 +92,13 @@ func (c *Client) AttachVolume(volumeID, serverID string) (string, error) {
 		"server_id": serverID,
 	}
 
-	body, status, err := c.sendRequest("POST", path, payload)
+	body, status, err := c.sendRequest(ctx, "POST", path, payload)
 	if err != nil {
 		return "", err
 	}
 
 	if status != http.StatusOK && status != http.StatusAccepted && status != http.StatusNoContent {
-		return "", fmt.Errorf("attach volume failed. Status: %d, Body: %s", status, string(body))
+		return "", newAPIError("POST", path, status, nil, body)
 	}
 
 	// Parse the response to get "device": "/dev/vdb"
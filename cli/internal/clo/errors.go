@@ -0,0 +1,132 @@
+package clo
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// APIError is a non-successful response of the CLO API
+type APIError struct {
+	StatusCode int    // HTTP status
+	Code       string // CLO error code, if the body has one
+	Message    string // CLO error message, or the raw body
+	RequestID  string // X-Request-Id of the response, for support tickets
+	Method     string
+	Path       string
+}
+
+func (e *APIError) Error() string {
+	msg := fmt.Sprintf("%s %s: %d %s", e.Method, e.Path, e.StatusCode, http.StatusText(e.StatusCode))
+	if e.Code != "" {
+		msg += " [" + e.Code + "]"
+	}
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	if e.RequestID != "" {
+		msg += " (request " + e.RequestID + ")"
+	}
+	return msg
+}
+
+// newAPIError builds an APIError from a response; header may be nil
+func newAPIError(method, path string, status int, header http.Header, body []byte) *APIError {
+	e := &APIError{StatusCode: status, Method: method, Path: path}
+	if header != nil {
+		e.RequestID = header.Get("X-Request-Id")
+	}
+	e.Code, e.Message = parseErrorBody(body)
+	return e
+}
+
+// parseErrorBody extracts code and message from the error shapes the API returns:
+// {"code","message"}, {"error":{"code","message"}}, {"error":"..."}, {"detail":"..."}.
+// Anything else is returned as the message verbatim.
+func parseErrorBody(body []byte) (code, message string) {
+	var raw struct {
+		Code    any             `json:"code"`
+		Message string          `json:"message"`
+		Detail  string          `json:"detail"`
+		Error   json.RawMessage `json:"error"`
+	}
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return "", strings.TrimSpace(string(body))
+	}
+
+	if len(raw.Error) > 0 {
+		var nested struct {
+			Code    any    `json:"code"`
+			Message string `json:"message"`
+		}
+		var s string
+		if json.Unmarshal(raw.Error, &nested) == nil {
+			if raw.Code == nil {
+				raw.Code = nested.Code
+			}
+			if raw.Message == "" {
+				raw.Message = nested.Message
+			}
+		} else if json.Unmarshal(raw.Error, &s) == nil && raw.Message == "" {
+			raw.Message = s
+		}
+	}
+	if raw.Code != nil {
+		code = fmt.Sprint(raw.Code)
+	}
+	message = raw.Message
+	if message == "" {
+		message = raw.Detail
+	}
+	if code == "" && message == "" {
+		message = strings.TrimSpace(string(body))
+	}
+	return code, message
+}
+
+// IsNotFound reports whether err is a 404 from the API
+func IsNotFound(err error) bool {
+	var e *APIError
+	return errors.As(err, &e) && e.StatusCode == http.StatusNotFound
+}
+
+// IsConflict reports whether err is a 409 from the API (resource busy or in the wrong state)
+func IsConflict(err error) bool {
+	var e *APIError
+	return errors.As(err, &e) && e.StatusCode == http.StatusConflict
+}
+
+// IsQuota reports whether the API refused the call because a project limit is exhausted.
+// Retrying such a call does not help until resources are freed or the quota is raised.
+func IsQuota(err error) bool {
+	var e *APIError
+	if !errors.As(err, &e) || e.StatusCode < 400 || e.StatusCode >= 500 || e.StatusCode == http.StatusTooManyRequests {
+		return false
+	}
+	text := strings.ToLower(e.Code + " " + e.Message)
+	return strings.Contains(text, "quota") || strings.Contains(text, "limit exceeded") || strings.Contains(text, "not enough")
+}
+
+// IsUnsupported reports whether the API refused the call because the feature is not available
+// for the project (501, or a 403/404 that says so), as opposed to a missing object
+func IsUnsupported(err error) bool {
+	var e *APIError
+	if !errors.As(err, &e) {
+		return false
+	}
+	if e.StatusCode == http.StatusNotImplemented {
+		return true
+	}
+	if e.StatusCode != http.StatusForbidden && e.StatusCode != http.StatusNotFound {
+		return false
+	}
+	text := strings.ToLower(e.Code + " " + e.Message)
+	for _, s := range []string{"not supported", "not_supported", "not available", "not_available", "not enabled", "feature"} {
+		if strings.Contains(text, s) {
+			return true
+		}
+	}
+	return false
+}
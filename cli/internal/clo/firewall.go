@@ -0,0 +1,77 @@
+package clo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// GetSecurityGroups gets the security groups of the project with their rules and servers.
+// Projects without the feature answer with an error for which IsUnsupported is true.
+func (c *Client) GetSecurityGroups(ctx context.Context) (*SecurityGroupListResponse, error) {
+	items, err := listAll[SecurityGroup](ctx, c, fmt.Sprintf("/projects/%s/security_groups", c.ProjectID))
+	if err != nil {
+		return nil, err
+	}
+	return &SecurityGroupListResponse{Count: len(items), Result: items}, nil
+}
+
+// CreateSecurityGroup creates a security group with its rules and returns its ID
+func (c *Client) CreateSecurityGroup(ctx context.Context, req CreateSecurityGroupRequest) (string, error) {
+	path := fmt.Sprintf("/projects/%s/security_groups", c.ProjectID)
+	body, status, err := c.sendRequest(ctx, "POST", path, req)
+	if err != nil {
+		return "", err
+	}
+	if status != http.StatusCreated && status != http.StatusOK {
+		return "", newAPIError("POST", path, status, nil, body)
+	}
+	var resp CreateSecurityGroupResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return "", err
+	}
+	if resp.Result.ID == "" {
+		return "", fmt.Errorf("no security group ID in response: %s", string(body))
+	}
+	return resp.Result.ID, nil
+}
+
+// SetSecurityGroupRules replaces all rules of a security group
+func (c *Client) SetSecurityGroupRules(ctx context.Context, groupID string, rules []SecurityGroupRule) error {
+	path := fmt.Sprintf("/security_groups/%s/rules", groupID)
+	body, status, err := c.sendRequest(ctx, "PUT", path, map[string][]SecurityGroupRule{"rules": rules})
+	if err != nil {
+		return err
+	}
+	if status != http.StatusOK && status != http.StatusAccepted && status != http.StatusNoContent {
+		return newAPIError("PUT", path, status, nil, body)
+	}
+	return nil
+}
+
+// AttachSecurityGroup applies a security group to a server
+func (c *Client) AttachSecurityGroup(ctx context.Context, groupID, serverID string) error {
+	path := fmt.Sprintf("/security_groups/%s/attach", groupID)
+	body, status, err := c.sendRequest(ctx, "POST", path, map[string]string{"server_id": serverID})
+	if err != nil {
+		return err
+	}
+	if status != http.StatusOK && status != http.StatusAccepted && status != http.StatusNoContent {
+		return newAPIError("POST", path, status, nil, body)
+	}
+	return nil
+}
+
+// DeleteSecurityGroup deletes a security group by ID
+func (c *Client) DeleteSecurityGroup(ctx context.Context, groupID string) error {
+	path := fmt.Sprintf("/security_groups/%s", groupID)
+	body, status, err := c.sendRequest(ctx, "DELETE", path, nil)
+	if err != nil {
+		return err
+	}
+	if status != http.StatusOK && status != http.StatusAccepted && status != http.StatusNoContent {
+		return newAPIError("DELETE", path, status, nil, body)
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package clo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// GetImages gets the list of OS images available to the project
+func (c *Client) GetImages(ctx context.Context) (*ImageListResponse, error) {
+	items, err := listAll[Image](ctx, c, fmt.Sprintf("/projects/%s/images", c.ProjectID))
+	if err != nil {
+		return nil, err
+	}
+	return &ImageListResponse{Count: len(items), Result: items}, nil
+}
+
+// GetKeypairs gets the list of SSH keypairs of the project
+func (c *Client) GetKeypairs(ctx context.Context) (*KeypairListResponse, error) {
+	items, err := listAll[Keypair](ctx, c, fmt.Sprintf("/projects/%s/keypairs", c.ProjectID))
+	if err != nil {
+		return nil, err
+	}
+	return &KeypairListResponse{Count: len(items), Result: items}, nil
+}
+
+// CreateKeypair uploads a public key under the given name. With an empty public key the API
+// generates a pair and returns the private key once, in Keypair.PrivateKey.
+func (c *Client) CreateKeypair(ctx context.Context, name, publicKey string) (*Keypair, error) {
+	path := fmt.Sprintf("/projects/%s/keypairs", c.ProjectID)
+	req := CreateKeypairRequest{Name: name, PublicKey: publicKey}
+	body, status, err := c.sendRequest(ctx, "POST", path, req)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusCreated && status != http.StatusOK {
+		return nil, newAPIError("POST", path, status, nil, body)
+	}
+	var resp CreateKeypairResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, err
+	}
+	if resp.Result.ID == "" {
+		return nil, fmt.Errorf("no keypair ID in response: %s", string(body))
+	}
+	return &resp.Result, nil
+}
@@ -1,57 +1,48 @@
 package clo
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 )
 
 // GetLoadBalancers gets a list of all load balancers in the project.
-func (c *Client) GetLoadBalancers() (*LoadBalancerListResponse, error) {
-	path := fmt.Sprintf("/projects/%s/loadbalancers", c.ProjectID)
-
-	body, status, err := c.sendRequest("GET", path, nil)
+func (c *Client) GetLoadBalancers(ctx context.Context) (*LoadBalancerListResponse, error) {
+	items, err := listAll[LoadBalancerDetail](ctx, c, fmt.Sprintf("/projects/%s/loadbalancers", c.ProjectID))
 	if err != nil {
 		return nil, err
 	}
-	if status != http.StatusOK {
-		return nil, fmt.Errorf("get lb list failed. Status: %d, Body: %s", status, string(body))
-	}
-
-	var resp LoadBalancerListResponse
-	if err := json.Unmarshal(body, &resp); err != nil {
-		return nil, err
-	}
-	return &resp, nil
+	return &LoadBalancerListResponse{Count: len(items), Result: items}, nil
 }
 
 // DeleteLoadBalancer deletes a load balancer by its ID.
-func (c *Client) DeleteLoadBalancer(lbID string) error {
+func (c *Client) DeleteLoadBalancer(ctx context.Context, lbID string) error {
 	path := fmt.Sprintf("/loadbalancers/%s", lbID)
 
-	body, status, err := c.sendRequest("DELETE", path, nil)
+	body, status, err := c.sendRequest(ctx, "DELETE", path, nil)
 	if err != nil {
 		return err
 	}
 
 	// Successful deletion can return 200, 202 (in progress) or 204 (no content)
 	if status != http.StatusOK && status != http.StatusAccepted && status != http.StatusNoContent {
-		return fmt.Errorf("delete lb failed. Status: %d, Body: %s", status, string(body))
+		return newAPIError("DELETE", path, status, nil, body)
 	}
 	return nil
 }
 
 // CreateLoadBalancer creates a load balancer via API
-func (c *Client) CreateLoadBalancer(req CreateLBRequest) (string, error) {
+func (c *Client) CreateLoadBalancer(ctx context.Context, req CreateLBRequest) (string, error) {
 	path := fmt.Sprintf("/projects/%s/loadbalancers", c.ProjectID)
 
-	body, status, err := c.sendRequest("POST", path, req)
+	body, status, err := c.sendRequest(ctx, "POST", path, req)
 	if err != nil {
 		return "", err
 	}
 
 	if status != http.StatusCreated && status != http.StatusOK {
-		return "", fmt.Errorf("create lb failed. Status: %d, Body: %s", status, string(body))
+		return "", newAPIError("POST", path, status, nil, body)
 	}
 
 	var resp CreateLBResponse
@@ -61,3 +52,36 @@ func (c *Client) CreateLoadBalancer(req CreateLBRequest) (string, error) {
 
 	return resp.Result.ID, nil
 }
+
+// GetLoadBalancerDetail gets the settings, address and rules of a load balancer
+func (c *Client) GetLoadBalancerDetail(ctx context.Context, lbID string) (*LoadBalancerInfo, error) {
+	path := fmt.Sprintf("/loadbalancers/%s/detail", lbID)
+
+	body, status, err := c.sendRequest(ctx, "GET", path, nil)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK {
+		return nil, newAPIError("GET", path, status, nil, body)
+	}
+
+	var resp LoadBalancerInfoResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, err
+	}
+	return &resp.Result, nil
+}
+
+// UpdateLoadBalancer changes the algorithm, health check and rules of a load balancer in place
+func (c *Client) UpdateLoadBalancer(ctx context.Context, lbID string, req UpdateLBRequest) error {
+	path := fmt.Sprintf("/loadbalancers/%s", lbID)
+
+	body, status, err := c.sendRequest(ctx, "PUT", path, req)
+	if err != nil {
+		return err
+	}
+	if status != http.StatusOK && status != http.StatusAccepted && status != http.StatusNoContent {
+		return newAPIError("PUT", path, status, nil, body)
+	}
+	return nil
+}
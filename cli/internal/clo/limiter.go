@@ -0,0 +1,190 @@
+package clo
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// CallClass groups endpoints that share a rate limit
+type CallClass int
+
+const (
+	ClassRead  CallClass = iota // GET of lists and details
+	ClassWrite                  // POST, PUT, DELETE
+	ClassPoll                   // GETs of status waiters (WaitForStatus, WaitForVolumeStatus)
+	numClasses
+)
+
+func (cc CallClass) String() string {
+	return [...]string{"read", "write", "poll"}[cc]
+}
+
+// RateLimit is a token bucket: PerSecond calls on average, up to Burst at once (PerSecond 0 = unlimited)
+type RateLimit struct {
+	PerSecond float64
+	Burst     int
+}
+
+// RateLimits holds the limit of every call class. One client shares them between all its goroutines.
+type RateLimits struct {
+	Read, Write, Poll RateLimit
+}
+
+// DefaultRateLimits keeps parallel node creation below the API's 429 threshold
+func DefaultRateLimits() RateLimits {
+	return RateLimits{
+		Read:  RateLimit{PerSecond: 10, Burst: 20},
+		Write: RateLimit{PerSecond: 2, Burst: 4},
+		Poll:  RateLimit{PerSecond: 2, Burst: 5},
+	}
+}
+
+func (l RateLimits) of(cc CallClass) RateLimit {
+	switch cc {
+	case ClassWrite:
+		return l.Write
+	case ClassPoll:
+		return l.Poll
+	default:
+		return l.Read
+	}
+}
+
+// tokenBucket is a thread-safe token bucket
+type tokenBucket struct {
+	mu     sync.Mutex
+	limit  RateLimit
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(l RateLimit) *tokenBucket {
+	if l.Burst < 1 {
+		l.Burst = 1
+	}
+	return &tokenBucket{limit: l, tokens: float64(l.Burst), last: time.Now()}
+}
+
+// wait takes a token, sleeping until one is available, and returns the time spent waiting
+func (b *tokenBucket) wait(ctx context.Context) (time.Duration, error) {
+	if b == nil || b.limit.PerSecond <= 0 {
+		return 0, nil
+	}
+	b.mu.Lock()
+	now := time.Now()
+	b.tokens = math.Min(float64(b.limit.Burst), b.tokens+now.Sub(b.last).Seconds()*b.limit.PerSecond)
+	b.last = now
+	// The token is taken now; a negative balance is the queue of goroutines ahead of us
+	b.tokens--
+	delay := time.Duration(0)
+	if b.tokens < 0 {
+		delay = time.Duration(-b.tokens / b.limit.PerSecond * float64(time.Second))
+	}
+	b.mu.Unlock()
+
+	if delay == 0 {
+		return 0, nil
+	}
+	if err := sleepCtx(ctx, delay); err != nil {
+		b.mu.Lock()
+		b.tokens++ // give the unused token back
+		b.mu.Unlock()
+		return 0, err
+	}
+	return delay, nil
+}
+
+// CallStats are the counters of one call class
+type CallStats struct {
+	Calls       int64         // HTTP requests sent, retries included
+	Retries     int64         // requests that repeated a failed one
+	RateLimited int64         // 429 answers
+	Throttled   time.Duration // time spent waiting for the client's own limiter
+}
+
+// Stats are the API counters of a client, by call class
+type Stats [numClasses]CallStats
+
+// Total sums all classes
+func (s Stats) Total() CallStats {
+	var t CallStats
+	for _, c := range s {
+		t.Calls += c.Calls
+		t.Retries += c.Retries
+		t.RateLimited += c.RateLimited
+		t.Throttled += c.Throttled
+	}
+	return t
+}
+
+// String prints the counters as "read 12 (1 retried, 0s throttled), ..."
+func (s Stats) String() string {
+	var parts []string
+	for cc := CallClass(0); cc < numClasses; cc++ {
+		c := s[cc]
+		if c.Calls == 0 {
+			continue
+		}
+		p := fmt.Sprintf("%s %d (%d retried", cc, c.Calls, c.Retries)
+		if c.RateLimited > 0 {
+			p += fmt.Sprintf(", %d rate limited", c.RateLimited)
+		}
+		p += fmt.Sprintf(", %v throttled)", c.Throttled.Round(time.Millisecond))
+		parts = append(parts, p)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// limiter holds the buckets and counters of a client
+type limiter struct {
+	once    sync.Once
+	buckets [numClasses]*tokenBucket
+
+	calls, retries, rateLimited [numClasses]atomic.Int64
+	throttled                   [numClasses]atomic.Int64 // nanoseconds
+}
+
+func (c *Client) limiter() *limiter {
+	c.lim.once.Do(func() {
+		for cc := CallClass(0); cc < numClasses; cc++ {
+			c.lim.buckets[cc] = newTokenBucket(c.Limits.of(cc))
+		}
+	})
+	return &c.lim
+}
+
+// Stats returns the API counters of the client since it was created
+func (c *Client) Stats() Stats {
+	l := &c.lim
+	var s Stats
+	for cc := CallClass(0); cc < numClasses; cc++ {
+		s[cc] = CallStats{
+			Calls: l.calls[cc].Load(), Retries: l.retries[cc].Load(), RateLimited: l.rateLimited[cc].Load(),
+			Throttled: time.Duration(l.throttled[cc].Load()),
+		}
+	}
+	return s
+}
+
+type callClassKey struct{}
+
+// Polling marks the calls made with ctx as status polls, limited by RateLimits.Poll
+func Polling(ctx context.Context) context.Context {
+	return context.WithValue(ctx, callClassKey{}, ClassPoll)
+}
+
+// callClass is the class of a request: explicit in ctx, otherwise by method
+func callClass(ctx context.Context, method string) CallClass {
+	if cc, ok := ctx.Value(callClassKey{}).(CallClass); ok {
+		return cc
+	}
+	if method == "GET" {
+		return ClassRead
+	}
+	return ClassWrite
+}
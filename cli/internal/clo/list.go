@@ -0,0 +1,161 @@
+package clo
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultPageSize is the page size of list calls when Client.PageSize is not set
+const DefaultPageSize = 100
+
+// maxListPages bounds the calls of one list, against an API that never reports the end
+const maxListPages = 1000
+
+// listCacheTTL bounds how long a cached list is trusted even without writes
+const listCacheTTL = 30 * time.Second
+
+// listPage is one page of a project list endpoint
+type listPage[T any] struct {
+	Count  int `json:"count"`
+	Result []T `json:"result"`
+}
+
+// listAll reads every page of a list endpoint. The API returns at most `limit` items per call
+// and the total in "count"; pages are requested until all of them are read. A page that starts
+// with the same item as the previous one means the API ignores offset: the list ends there.
+// With a list cache in ctx (WithListCache) the assembled list is reused until the next write.
+func listAll[T any](ctx context.Context, c *Client, path string) ([]T, error) {
+	cache := listCacheFrom(ctx)
+	cached, gen, ok := cache.get(path)
+	if ok {
+		return append([]T(nil), cached.([]T)...), nil
+	}
+
+	limit := c.PageSize
+	if limit <= 0 {
+		limit = DefaultPageSize
+	}
+	sep := "?"
+	if strings.Contains(path, "?") {
+		sep = "&"
+	}
+	all := []T{}
+	var prevFirst json.RawMessage
+	for pages := 1; ; pages++ {
+		if pages > maxListPages {
+			return nil, fmt.Errorf("GET %s: no end of the list after %d pages", path, maxListPages)
+		}
+		pagePath := fmt.Sprintf("%s%slimit=%d&offset=%d", path, sep, limit, len(all))
+		body, status, err := c.sendRequest(ctx, "GET", pagePath, nil)
+		if err != nil {
+			return nil, err
+		}
+		if status != http.StatusOK {
+			return nil, newAPIError("GET", pagePath, status, nil, body)
+		}
+		var page listPage[json.RawMessage]
+		if err := json.Unmarshal(body, &page); err != nil {
+			return nil, err
+		}
+		if len(page.Result) > 0 && bytes.Equal(page.Result[0], prevFirst) {
+			break
+		}
+		for _, raw := range page.Result {
+			var item T
+			if err := json.Unmarshal(raw, &item); err != nil {
+				return nil, err
+			}
+			all = append(all, item)
+		}
+		// An empty page ends the list even if count says otherwise (items deleted while paging).
+		// Without a count the API is not paginating: a short page is the last one.
+		if len(page.Result) == 0 || len(all) >= page.Count && (page.Count > 0 || len(page.Result) < limit) {
+			break
+		}
+		prevFirst = page.Result[0]
+	}
+
+	cache.put(path, all, gen)
+	return append([]T(nil), all...), nil
+}
+
+// listCache keeps assembled lists for one run. Any write through a client that uses the
+// same context drops it, so a run sees its own changes.
+type listCache struct {
+	mu      sync.Mutex
+	gen     int // bumped by every write, so a list read across a write is not stored
+	entries map[string]cacheEntry
+}
+
+type cacheEntry struct {
+	items   any
+	fetched time.Time
+}
+
+type listCacheKey struct{}
+
+// WithListCache returns a context in which list calls are cached until the next write.
+// Use one per run: reconcile reads the same volume and address lists for every node.
+func WithListCache(ctx context.Context) context.Context {
+	if listCacheFrom(ctx) != nil {
+		return ctx
+	}
+	return context.WithValue(ctx, listCacheKey{}, &listCache{entries: make(map[string]cacheEntry)})
+}
+
+// InvalidateListCache drops the cached lists of ctx, for changes the cloud makes on its own
+// (a server build creating volumes, an attach settling)
+func InvalidateListCache(ctx context.Context) {
+	listCacheFrom(ctx).clear()
+}
+
+// withoutListCache returns a context whose list calls always read the API, for lookups
+// that concurrent callers must not answer from the same snapshot
+func withoutListCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, listCacheKey{}, (*listCache)(nil))
+}
+
+func listCacheFrom(ctx context.Context) *listCache {
+	cache, _ := ctx.Value(listCacheKey{}).(*listCache)
+	return cache
+}
+
+func (lc *listCache) get(path string) (any, int, bool) {
+	if lc == nil {
+		return nil, 0, false
+	}
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+	e, ok := lc.entries[path]
+	if !ok || time.Since(e.fetched) > listCacheTTL {
+		return nil, lc.gen, false
+	}
+	return e.items, lc.gen, true
+}
+
+func (lc *listCache) put(path string, items any, gen int) {
+	if lc == nil {
+		return
+	}
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+	if gen == lc.gen {
+		lc.entries[path] = cacheEntry{items: items, fetched: time.Now()}
+	}
+}
+
+func (lc *listCache) clear() {
+	if lc == nil {
+		return
+	}
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+	lc.gen++
+	clear(lc.entries)
+}
@@ -23,13 +23,16 @@ type AddressDetail struct {
 	LoadBalancerID string      `json:"loadbalancer_id"`
 }
 
+// ServerSummary is one server in the project list
+type ServerSummary struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
 // ServerListResponse ...
 type ServerListResponse struct {
-	Count  int `json:"count"`
-	Result []struct {
-		ID   string `json:"id"`
-		Name string `json:"name"`
-	} `json:"result"`
+	Count  int             `json:"count"`
+	Result []ServerSummary `json:"result"`
 }
 
 // ServerDetailResponse ...
@@ -40,12 +43,69 @@ type ServerDetailResponse struct {
 		Name      string   `json:"name"`
 		Created   string   `json:"created"`
 		Addresses []string `json:"addresses"`
-		Storages  []struct {
+		Flavor    struct {
+			RAM   int `json:"ram"`
+			VCPUs int `json:"vcpus"`
+		} `json:"flavor"`
+		Storages []struct {
 			ID string `json:"id"`
 		} `json:"storages"`
 	} `json:"result"`
 }
 
+// CPU types accepted by the API
+const (
+	CPUShared    = "SHARED"
+	CPUDedicated = "DEDICATED"
+)
+
+// CreateServerRequest is the body of a server creation call
+type CreateServerRequest struct {
+	Name      string          `json:"name"`
+	Flavor    ServerFlavor    `json:"flavor"`
+	Storages  []ServerStorage `json:"storages"`
+	Addresses []ServerAddress `json:"addresses"`
+	Image     string          `json:"image"`
+	Keypairs  []string        `json:"keypairs,omitempty"`
+	UserData  string          `json:"user_data,omitempty"` // cloud-init user-data, sent as is
+}
+
+// ServerFlavor describes the size of a server
+type ServerFlavor struct {
+	RAM     int    `json:"ram"`
+	VCPUs   int    `json:"vcpus"`
+	CPUType string `json:"cpu_type"`
+}
+
+// ServerStorage is a new volume created together with the server
+type ServerStorage struct {
+	Bootable    bool              `json:"bootable"`
+	StorageType string            `json:"storage_type"`
+	Size        int               `json:"size"`
+	Name        string            `json:"name,omitempty"`
+	Tags        map[string]string `json:"metadata,omitempty"`
+}
+
+// ServerAddress is either an existing address (AddressID) or a new one to allocate
+type ServerAddress struct {
+	AddressID      string `json:"address_id,omitempty"`
+	DDoSProtection *bool  `json:"ddos_protection,omitempty"`
+	External       *bool  `json:"external,omitempty"`
+	Version        int    `json:"version,omitempty"`
+	BandwidthMbps  int    `json:"bandwidth_max_mbps,omitempty"`
+}
+
+// ExistingAddress attaches an address the project already owns
+func ExistingAddress(id string) ServerAddress {
+	return ServerAddress{AddressID: id}
+}
+
+// NewAddress allocates a new IPv4 address, public or private
+func NewAddress(external bool) ServerAddress {
+	ddos := false
+	return ServerAddress{DDoSProtection: &ddos, External: &external, Version: 4, BandwidthMbps: 1024}
+}
+
 // CreateServerResponse ...
 type CreateServerResponse struct {
 	Result struct {
@@ -82,6 +142,7 @@ type DiskResult struct {
 	Bootable         bool              `json:"bootable"`
 	Created          string            `json:"created_in"`
 	AttachedToServer *VolumeAttachment `json:"attached_to_server"`
+	Tags             map[string]string `json:"metadata,omitempty"`
 }
 
 // DiskDetailResponse ...
@@ -101,6 +162,12 @@ type DeleteVolumePayload struct {
 	Force      bool `json:"force"`
 }
 
+// UpdateVolumeRequest renames a volume and replaces its metadata
+type UpdateVolumeRequest struct {
+	Name string            `json:"name"`
+	Tags map[string]string `json:"metadata"`
+}
+
 // AttachVolumeResponse ...
 type AttachVolumeResponse struct {
 	Result struct {
@@ -154,8 +221,162 @@ type LoadBalancerDetail struct {
 	Name string `json:"name"`
 }
 
+// LoadBalancerInfo is the full description of one load balancer
+type LoadBalancerInfo struct {
+	ID                 string          `json:"id"`
+	Name               string          `json:"name"`
+	Status             string          `json:"status"`
+	Algorithm          string          `json:"algorithm"`
+	AddressID          string          `json:"address_id"`
+	HealthMonitor      LBHealthMonitor `json:"healthmonitor"`
+	SessionPersistence bool            `json:"session_persistence"`
+	Rules              []LBRule        `json:"rules"`
+}
+
+// LoadBalancerInfoResponse describes the API response with one load balancer
+type LoadBalancerInfoResponse struct {
+	Result LoadBalancerInfo `json:"result"`
+}
+
+// UpdateLBRequest describes the request body for changing an LB; the rules replace the current ones
+type UpdateLBRequest struct {
+	Algorithm          string          `json:"algorithm"`
+	HealthMonitor      LBHealthMonitor `json:"healthmonitor"`
+	SessionPersistence bool            `json:"session_persistence"`
+	Rules              []LBRule        `json:"rules"`
+}
+
 // LoadBalancerListResponse describes the API response with a list of all load balancers
 type LoadBalancerListResponse struct {
 	Count  int                  `json:"count"`
 	Result []LoadBalancerDetail `json:"result"`
 }
+
+// --- STRUCTURES FOR IMAGES AND KEYPAIRS ---
+
+// Image is an OS image servers can be created from
+type Image struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	OSName  string `json:"operation_system"`
+	Version string `json:"version"`
+	Status  string `json:"status"`
+}
+
+// ImageListResponse describes the API response with the images of the project
+type ImageListResponse struct {
+	Count  int     `json:"count"`
+	Result []Image `json:"result"`
+}
+
+// Keypair is an SSH public key stored in the project
+type Keypair struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Fingerprint string `json:"fingerprint"`
+	PublicKey   string `json:"public_key"`
+	PrivateKey  string `json:"private_key,omitempty"` // only when the API generated the pair
+}
+
+// KeypairListResponse describes the API response with the keypairs of the project
+type KeypairListResponse struct {
+	Count  int       `json:"count"`
+	Result []Keypair `json:"result"`
+}
+
+// CreateKeypairRequest describes the request body for creating a keypair
+type CreateKeypairRequest struct {
+	Name      string `json:"name"`
+	PublicKey string `json:"public_key,omitempty"`
+}
+
+// CreateKeypairResponse describes the response when creating a keypair
+type CreateKeypairResponse struct {
+	Result Keypair `json:"result"`
+}
+
+// --- STRUCTURES FOR SNAPSHOTS ---
+
+// Snapshot is a point-in-time copy of a volume
+type Snapshot struct {
+	ID       string            `json:"id"`
+	Name     string            `json:"name"`
+	VolumeID string            `json:"volume_id"`
+	Size     int               `json:"size"`
+	Status   string            `json:"status"`
+	Created  string            `json:"created_in"`
+	Tags     map[string]string `json:"metadata,omitempty"`
+}
+
+// SnapshotListResponse describes the API response with the snapshots of the project
+type SnapshotListResponse struct {
+	Count  int        `json:"count"`
+	Result []Snapshot `json:"result"`
+}
+
+// CreateSnapshotRequest describes the request body for snapshotting a volume
+type CreateSnapshotRequest struct {
+	Name string            `json:"name"`
+	Tags map[string]string `json:"metadata,omitempty"`
+}
+
+// CreateSnapshotResponse describes the response when creating a snapshot
+type CreateSnapshotResponse struct {
+	Result struct {
+		ID string `json:"id"`
+	} `json:"result"`
+}
+
+// RestoreSnapshotRequest describes the request body for restoring a snapshot to a new volume
+type RestoreSnapshotRequest struct {
+	Name string            `json:"name"`
+	Size int               `json:"size,omitempty"` // 0 keeps the snapshot size
+	Tags map[string]string `json:"metadata,omitempty"`
+}
+
+// RestoreSnapshotResponse describes the response when restoring a snapshot: the new volume
+type RestoreSnapshotResponse struct {
+	Result struct {
+		ID string `json:"id"`
+	} `json:"result"`
+}
+
+// --- STRUCTURES FOR SECURITY GROUPS ---
+
+// SecurityGroupRule allows one kind of ingress traffic; an empty Protocol means any
+type SecurityGroupRule struct {
+	Direction string `json:"direction"`          // "ingress"
+	Protocol  string `json:"protocol,omitempty"` // "tcp", "udp", "icmp" or "" for any
+	PortMin   int    `json:"port_range_min,omitempty"`
+	PortMax   int    `json:"port_range_max,omitempty"`
+	CIDR      string `json:"remote_ip_prefix"`
+}
+
+// SecurityGroup is a set of rules applied to the servers attached to it
+type SecurityGroup struct {
+	ID          string              `json:"id"`
+	Name        string              `json:"name"`
+	Description string              `json:"description"`
+	Rules       []SecurityGroupRule `json:"rules"`
+	Servers     []string            `json:"servers"` // IDs of the attached servers
+}
+
+// SecurityGroupListResponse describes the API response with the security groups of the project
+type SecurityGroupListResponse struct {
+	Count  int             `json:"count"`
+	Result []SecurityGroup `json:"result"`
+}
+
+// CreateSecurityGroupRequest describes the request body for creating a security group
+type CreateSecurityGroupRequest struct {
+	Name        string              `json:"name"`
+	Description string              `json:"description,omitempty"`
+	Rules       []SecurityGroupRule `json:"rules"`
+}
+
+// CreateSecurityGroupResponse describes the response when creating a security group
+type CreateSecurityGroupResponse struct {
+	Result struct {
+		ID string `json:"id"`
+	} `json:"result"`
+}
@@ -1,16 +1,20 @@
 package clo
 
 import (
+	"context"
 	"crypto/rand"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"math/big"
 	"net/http"
+	"regexp"
 )
 
 // FindAvailableExternalIP searches for an existing external IP address that is not bound to a server or LB
-func (c *Client) FindAvailableExternalIP() (string, error) {
-	allAddrs, err := c.GetProjectAddressesMap()
+func (c *Client) FindAvailableExternalIP(ctx context.Context) (string, error) {
+	// Not cached: parallel creates would all pick the first address of the same stale list
+	allAddrs, err := c.GetProjectAddressesMap(withoutListCache(ctx))
 	if err != nil {
 		return "", fmt.Errorf("could not get project addresses: %w", err)
 	}
@@ -26,15 +30,66 @@ func (c *Client) FindAvailableExternalIP() (string, error) {
 	return "", nil
 }
 
-// CreateServer creates a server and returns its ID
-func (c *Client) CreateServer(payload map[string]interface{}) (string, error) {
+// Validate checks the request before it is sent, so a config mistake costs no API call
+func (r CreateServerRequest) Validate() error {
+	var errs []error
+	if r.Name == "" {
+		errs = append(errs, errors.New("name is empty"))
+	}
+	if r.Flavor.RAM <= 0 || r.Flavor.VCPUs <= 0 {
+		errs = append(errs, fmt.Errorf("flavor %dGB/%d vCPU is invalid", r.Flavor.RAM, r.Flavor.VCPUs))
+	}
+	if r.Flavor.CPUType != CPUShared && r.Flavor.CPUType != CPUDedicated {
+		errs = append(errs, fmt.Errorf("cpu_type %q must be %s or %s", r.Flavor.CPUType, CPUShared, CPUDedicated))
+	}
+	if !IsUUID(r.Image) {
+		errs = append(errs, fmt.Errorf("image %q is not an image ID", r.Image))
+	}
+	for _, k := range r.Keypairs {
+		if !IsUUID(k) {
+			errs = append(errs, fmt.Errorf("keypair %q is not a keypair ID", k))
+		}
+	}
+	boot := 0
+	for _, s := range r.Storages {
+		if s.Bootable {
+			boot++
+		}
+		if s.Size <= 0 || s.StorageType == "" {
+			errs = append(errs, fmt.Errorf("storage %dGB %q is invalid", s.Size, s.StorageType))
+		}
+	}
+	if boot != 1 {
+		errs = append(errs, fmt.Errorf("exactly one bootable storage required, got %d", boot))
+	}
+	if len(r.Addresses) == 0 {
+		errs = append(errs, errors.New("at least one address required"))
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("server %q: %w", r.Name, errors.Join(errs...))
+	}
+	return nil
+}
+
+// IsUUID reports whether s looks like an API object ID
+func IsUUID(s string) bool {
+	return uuidRe.MatchString(s)
+}
+
+var uuidRe = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// CreateServer validates the request, creates a server and returns its ID
+func (c *Client) CreateServer(ctx context.Context, req CreateServerRequest) (string, error) {
+	if err := req.Validate(); err != nil {
+		return "", err
+	}
 	path := fmt.Sprintf("/projects/%s/servers", c.ProjectID)
-	body, status, err := c.sendRequest("POST", path, payload)
+	body, status, err := c.sendRequest(ctx, "POST", path, req)
 	if err != nil {
 		return "", err
 	}
 	if status != http.StatusCreated && status != http.StatusOK {
-		return "", fmt.Errorf("API Error: %s Body: %s", http.StatusText(status), string(body))
+		return "", newAPIError("POST", path, status, nil, body)
 	}
 	var resp CreateServerResponse
 	if err := json.Unmarshal(body, &resp); err != nil {
@@ -47,33 +102,25 @@ func (c *Client) CreateServer(payload map[string]interface{}) (string, error) {
 }
 
 // DeleteServer deletes a server
-func (c *Client) DeleteServer(serverID string, payload DeleteServerPayload) error {
+func (c *Client) DeleteServer(ctx context.Context, serverID string, payload DeleteServerPayload) error {
 	path := fmt.Sprintf("/servers/%s", serverID)
-	_, status, err := c.sendRequest("DELETE", path, payload)
+	body, status, err := c.sendRequest(ctx, "DELETE", path, payload)
 	if err != nil {
 		return err
 	}
 	if status != http.StatusNoContent && status != http.StatusOK {
-		return fmt.Errorf("delete failed. Status: %d", status)
+		return newAPIError("DELETE", path, status, nil, body)
 	}
 	return nil
 }
 
 // GetServersList gets a list of all servers in the project
-func (c *Client) GetServersList() (*ServerListResponse, error) {
-	path := fmt.Sprintf("/projects/%s/servers", c.ProjectID)
-	body, status, err := c.sendRequest("GET", path, nil)
+func (c *Client) GetServersList(ctx context.Context) (*ServerListResponse, error) {
+	items, err := listAll[ServerSummary](ctx, c, fmt.Sprintf("/projects/%s/servers", c.ProjectID))
 	if err != nil {
 		return nil, err
 	}
-	if status != http.StatusOK {
-		return nil, fmt.Errorf("get servers list failed. Status: %d", status)
-	}
-	var resp ServerListResponse
-	if err := json.Unmarshal(body, &resp); err != nil {
-		return nil, err
-	}
-	return &resp, nil
+	return &ServerListResponse{Count: len(items), Result: items}, nil
 }
 
 // GenerateRandomPassword generates a cryptographically strong password
@@ -92,49 +139,41 @@ func GenerateRandomPassword() (string, error) {
 }
 
 // SetServerPassword sets the password on the server
-func (c *Client) SetServerPassword(serverID, password string) error {
+func (c *Client) SetServerPassword(ctx context.Context, serverID, password string) error {
 	path := fmt.Sprintf("/servers/%s/password", serverID)
 	payload := map[string]string{"password": password}
-	_, status, err := c.sendRequest("POST", path, payload)
+	body, status, err := c.sendRequest(ctx, "POST", path, payload)
 	if err != nil {
 		return err
 	}
 	if status != http.StatusAccepted && status != http.StatusNoContent {
-		return fmt.Errorf("password set failed. Status: %d", status)
+		return newAPIError("POST", path, status, nil, body)
 	}
 	return nil
 }
 
 // GetProjectAddressesMap gets a map of all addresses in the project
-func (c *Client) GetProjectAddressesMap() (map[string]AddressDetail, error) {
-	path := fmt.Sprintf("/projects/%s/addresses", c.ProjectID)
-	body, status, err := c.sendRequest("GET", path, nil)
+func (c *Client) GetProjectAddressesMap(ctx context.Context) (map[string]AddressDetail, error) {
+	items, err := listAll[AddressDetail](ctx, c, fmt.Sprintf("/projects/%s/addresses", c.ProjectID))
 	if err != nil {
 		return nil, err
 	}
-	if status != http.StatusOK {
-		return nil, fmt.Errorf("get addresses failed. Status: %d", status)
-	}
-	var resp ProjectAddressesResponse
-	if err := json.Unmarshal(body, &resp); err != nil {
-		return nil, err
-	}
 	addrMap := make(map[string]AddressDetail)
-	for _, addr := range resp.Result {
+	for _, addr := range items {
 		addrMap[addr.ID] = addr
 	}
 	return addrMap, nil
 }
 
 // GetServerDetail gets the details of a specific server
-func (c *Client) GetServerDetail(serverID string) (*ServerDetailResponse, error) {
+func (c *Client) GetServerDetail(ctx context.Context, serverID string) (*ServerDetailResponse, error) {
 	path := fmt.Sprintf("/servers/%s/detail", serverID)
-	body, status, err := c.sendRequest("GET", path, nil)
+	body, status, err := c.sendRequest(ctx, "GET", path, nil)
 	if err != nil {
 		return nil, err
 	}
 	if status != http.StatusOK {
-		return nil, fmt.Errorf("get detail failed. Status: %d", status)
+		return nil, newAPIError("GET", path, status, nil, body)
 	}
 	var resp ServerDetailResponse
 	if err := json.Unmarshal(body, &resp); err != nil {
@@ -142,3 +181,49 @@ func (c *Client) GetServerDetail(serverID string) (*ServerDetailResponse, error)
 	}
 	return &resp, nil
 }
+
+// StopServer shuts a server down; it goes to STOPPED
+func (c *Client) StopServer(ctx context.Context, serverID string) error {
+	return c.serverAction(ctx, serverID, "stop", nil)
+}
+
+// StartServer boots a stopped server
+func (c *Client) StartServer(ctx context.Context, serverID string) error {
+	return c.serverAction(ctx, serverID, "start", nil)
+}
+
+// ResizeServer changes the flavor of a stopped server.
+// Projects without the feature answer with an error for which IsUnsupported is true.
+func (c *Client) ResizeServer(ctx context.Context, serverID string, flavor ServerFlavor) error {
+	return c.serverAction(ctx, serverID, "resize", map[string]ServerFlavor{"flavor": flavor})
+}
+
+// CanResize reports whether the project supports resize, without touching the server. The API has
+// no read-only way to ask, so it requests a resize of the running server to its current flavor
+// (CPU type included), which a project with the feature refuses only because the server is not
+// stopped. An accepted request is an error: the API did not behave as this check expects.
+func (c *Client) CanResize(ctx context.Context, serverID string, current ServerFlavor) (bool, error) {
+	err := c.ResizeServer(ctx, serverID, current)
+	switch {
+	case IsUnsupported(err):
+		return false, nil
+	case IsConflict(err):
+		return true, nil
+	case err == nil:
+		return false, fmt.Errorf("resize check: the API resized running server %s instead of refusing", serverID)
+	}
+	return false, err
+}
+
+// serverAction posts an action such as stop or start to a server
+func (c *Client) serverAction(ctx context.Context, serverID, action string, payload interface{}) error {
+	path := fmt.Sprintf("/servers/%s/%s", serverID, action)
+	body, status, err := c.sendRequest(ctx, "POST", path, payload)
+	if err != nil {
+		return err
+	}
+	if status != http.StatusOK && status != http.StatusAccepted && status != http.StatusNoContent {
+		return newAPIError("POST", path, status, nil, body)
+	}
+	return nil
+}
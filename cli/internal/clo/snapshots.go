@@ -0,0 +1,95 @@
+package clo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// CreateSnapshot snapshots a volume and returns the snapshot ID. Tags are stored as snapshot metadata.
+func (c *Client) CreateSnapshot(ctx context.Context, volumeID, name string, tags map[string]string) (string, error) {
+	path := fmt.Sprintf("/volumes/%s/snapshots", volumeID)
+	body, status, err := c.sendRequest(ctx, "POST", path, CreateSnapshotRequest{Name: name, Tags: tags})
+	if err != nil {
+		return "", err
+	}
+	if status != http.StatusCreated && status != http.StatusOK && status != http.StatusAccepted {
+		return "", newAPIError("POST", path, status, nil, body)
+	}
+	var resp CreateSnapshotResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return "", err
+	}
+	if resp.Result.ID == "" {
+		return "", fmt.Errorf("no snapshot ID in response: %s", string(body))
+	}
+	return resp.Result.ID, nil
+}
+
+// GetProjectSnapshots gets the list of all volume snapshots in the project
+func (c *Client) GetProjectSnapshots(ctx context.Context) (*SnapshotListResponse, error) {
+	items, err := listAll[Snapshot](ctx, c, fmt.Sprintf("/projects/%s/snapshots", c.ProjectID))
+	if err != nil {
+		return nil, err
+	}
+	return &SnapshotListResponse{Count: len(items), Result: items}, nil
+}
+
+// DeleteSnapshot deletes a snapshot by ID
+func (c *Client) DeleteSnapshot(ctx context.Context, snapshotID string) error {
+	path := fmt.Sprintf("/snapshots/%s", snapshotID)
+	body, status, err := c.sendRequest(ctx, "DELETE", path, nil)
+	if err != nil {
+		return err
+	}
+	if status != http.StatusNoContent && status != http.StatusOK && status != http.StatusAccepted {
+		return newAPIError("DELETE", path, status, nil, body)
+	}
+	return nil
+}
+
+// RestoreSnapshot creates a new volume from a snapshot and returns its ID.
+// The volume is usable once it is AVAILABLE, see WaitForVolumeStatus.
+func (c *Client) RestoreSnapshot(ctx context.Context, snapshotID, name string, size int, tags map[string]string) (string, error) {
+	path := fmt.Sprintf("/snapshots/%s/restore", snapshotID)
+	body, status, err := c.sendRequest(ctx, "POST", path, RestoreSnapshotRequest{Name: name, Size: size, Tags: tags})
+	if err != nil {
+		return "", err
+	}
+	if status != http.StatusCreated && status != http.StatusOK && status != http.StatusAccepted {
+		return "", newAPIError("POST", path, status, nil, body)
+	}
+	var resp RestoreSnapshotResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return "", err
+	}
+	if resp.Result.ID == "" {
+		return "", fmt.Errorf("no volume ID in response: %s", string(body))
+	}
+	return resp.Result.ID, nil
+}
+
+// WaitForVolumeStatus polls a volume until it reaches target, fails with ERROR or attempts run out
+func (c *Client) WaitForVolumeStatus(ctx context.Context, volumeID, target string, maxAttempts int, interval time.Duration) error {
+	ctx = Polling(ctx)
+	for i := 0; i < maxAttempts; i++ {
+		vol, err := c.GetVolumeDetail(ctx, volumeID)
+		if IsNotFound(err) {
+			return fmt.Errorf("volume %s not found: %w", volumeID, err)
+		}
+		if err == nil {
+			if vol.Status == target {
+				return nil
+			}
+			if vol.Status == "ERROR" {
+				return fmt.Errorf("volume %s entered ERROR state", volumeID)
+			}
+		}
+		if err := sleepCtx(ctx, interval); err != nil {
+			return err
+		}
+	}
+	return fmt.Errorf("timeout: volume %s did not reach status %s", volumeID, target)
+}
@@ -1,27 +1,32 @@
 package clo
 
 import (
+	"context"
 	"fmt"
-	"strings"
 	"time"
 )
 
-// WaitForStatus waits for the target status.
+// WaitForStatus waits for the target status until ctx is done.
 // If it encounters ERROR or 404, it immediately returns an error to trigger recreation.
-func (c *Client) WaitForStatus(serverID string, targetStatuses []string, maxAttempts int, interval time.Duration) (string, []string, []string, error) {
-
+func (c *Client) WaitForStatus(ctx context.Context, serverID string, targetStatuses []string, maxAttempts int, interval time.Duration) (string, []string, []string, error) {
+	ctx = Polling(ctx)
 	for i := 0; i < maxAttempts; i++ {
-		detail, err := c.GetServerDetail(serverID)
+		detail, err := c.GetServerDetail(ctx, serverID)
 
 		// 1. Handle request error (including 404)
 		if err != nil {
 			// If the server is deleted (404), there's no point in waiting further
-			if strings.Contains(err.Error(), "Status: 404") {
-				return "DELETED", nil, nil, fmt.Errorf("server %s not found (404), aborting wait", serverID)
+			if IsNotFound(err) {
+				return "DELETED", nil, nil, fmt.Errorf("server %s not found, aborting wait: %w", serverID, err)
 			}
 			// Other network errors - log and try again (retry)
+			if ctx.Err() != nil {
+				return "", nil, nil, ctx.Err()
+			}
 			fmt.Printf("   [TIME] Attempt %d: API error: %v. Waiting...\n", i+1, err)
-			time.Sleep(interval)
+			if err := sleepCtx(ctx, interval); err != nil {
+				return "", nil, nil, err
+			}
 			continue
 		}
 
@@ -58,8 +63,34 @@ func (c *Client) WaitForStatus(serverID string, targetStatuses []string, maxAtte
 			return currentStatus, addrIDs, volIDs, nil
 		}
 
-		time.Sleep(interval)
+		if err := sleepCtx(ctx, interval); err != nil {
+			return currentStatus, addrIDs, volIDs, err
+		}
 	}
 
 	return "", nil, nil, fmt.Errorf("timeout: server did not reach status %v", targetStatuses)
 }
+
+// WaitForDeleted waits until the server detail returns 404, i.e. the delete has finished
+// and the server no longer holds its name, addresses and volumes.
+func (c *Client) WaitForDeleted(ctx context.Context, serverID string, maxAttempts int, interval time.Duration) error {
+	ctx = Polling(ctx)
+	for i := 0; i < maxAttempts; i++ {
+		detail, err := c.GetServerDetail(ctx, serverID)
+		if IsNotFound(err) {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			fmt.Printf("   [TIME] Attempt %d: API error: %v. Waiting...\n", i+1, err)
+		} else {
+			fmt.Printf("   ... [%s] Current status: %s\n", serverID, detail.Result.Status)
+		}
+		if err := sleepCtx(ctx, interval); err != nil {
+			return err
+		}
+	}
+	return fmt.Errorf("timeout: server %s was not deleted", serverID)
+}
@@ -0,0 +1,136 @@
+package state
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+)
+
+// ParseRecipients parses age public keys separated by commas, spaces or newlines
+func ParseRecipients(s string) ([]age.Recipient, error) {
+	var out []age.Recipient
+	for _, f := range strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ' ' || r == '\n' || r == '\t' }) {
+		r, err := age.ParseX25519Recipient(f)
+		if err != nil {
+			return nil, fmt.Errorf("recipient %q: %w", f, err)
+		}
+		out = append(out, r)
+	}
+	return out, nil
+}
+
+// ParseIdentities parses age secret keys in the SOPS_AGE_KEY / keys.txt format
+func ParseIdentities(s string) ([]age.Identity, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+	return age.ParseIdentities(strings.NewReader(s))
+}
+
+// IsEncrypted reports whether data is an age file (armored or binary)
+func IsEncrypted(data []byte) bool {
+	trimmed := bytes.TrimSpace(data)
+	return bytes.HasPrefix(trimmed, []byte(armor.Header)) || bytes.HasPrefix(trimmed, []byte("age-encryption.org/"))
+}
+
+// encode serializes the state and encrypts it when recipients are configured
+func (b *Backend) encode(st ClusterState) ([]byte, error) {
+	jsonData, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if len(b.Recipients) == 0 {
+		return jsonData, nil
+	}
+
+	var buf bytes.Buffer
+	aw := armor.NewWriter(&buf)
+	w, err := age.Encrypt(aw, b.Recipients...)
+	if err != nil {
+		return nil, fmt.Errorf("state encryption: %w", err)
+	}
+	if _, err := w.Write(jsonData); err != nil {
+		return nil, fmt.Errorf("state encryption: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return nil, fmt.Errorf("state encryption: %w", err)
+	}
+	if err := aw.Close(); err != nil {
+		return nil, fmt.Errorf("state encryption: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// decode parses a stored state, decrypting it first if it is an age file, and upgrades
+// it to CurrentVersion. Plaintext states written before encryption was enabled are read as is.
+func (b *Backend) decode(data []byte) (*ClusterState, error) {
+	if IsEncrypted(data) {
+		if len(b.Identities) == 0 {
+			return nil, fmt.Errorf("state is encrypted but no age identity is available (set SOPS_AGE_KEY)")
+		}
+		var src io.Reader = bytes.NewReader(data)
+		if bytes.HasPrefix(bytes.TrimSpace(data), []byte(armor.Header)) {
+			src = armor.NewReader(src)
+		}
+		r, err := age.Decrypt(src, b.Identities...)
+		if err != nil {
+			return nil, fmt.Errorf("state decryption: %w", err)
+		}
+		plain, err := io.ReadAll(r)
+		if err != nil {
+			return nil, fmt.Errorf("state decryption: %w", err)
+		}
+		data = plain
+	}
+
+	return upgrade(data)
+}
+
+// Rewrap re-encrypts the current state and all history revisions to the configured
+// recipients, e.g. after a key was added or revoked. With no recipients it decrypts them.
+func (b *Backend) Rewrap() error {
+	// History first: the save of the current state adds a revision that is
+	// already encrypted to the new recipients and may not be readable by us.
+	ctx := context.Background()
+	b.decrypting = len(b.Recipients) == 0
+	defer func() { b.decrypting = false }()
+	revs, err := b.ListHistory()
+	if err != nil {
+		return err
+	}
+	for _, r := range revs {
+		key := b.HistoryPrefix() + r.ID + ".json"
+		data, _, err := b.Store.Get(ctx, key)
+		if err != nil {
+			return fmt.Errorf("revision %s: %w", r.ID, err)
+		}
+		st, err := b.decode(data)
+		if err != nil {
+			return fmt.Errorf("revision %s: %w", r.ID, err)
+		}
+		out, err := b.encode(*st)
+		if err != nil {
+			return err
+		}
+		if _, err := b.Store.Put(ctx, key, out, PutOptions{ContentType: "application/json"}); err != nil {
+			return fmt.Errorf("revision %s: %w", r.ID, err)
+		}
+	}
+
+	err = b.UpdateState(func(st *ClusterState) error {
+		if st.LastUpdated.IsZero() && len(st.Nodes) == 0 {
+			return ErrNoChanges // nothing stored yet
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("current state: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package state
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"filippo.io/age"
+)
+
+func newIdentity(t *testing.T) *age.X25519Identity {
+	t.Helper()
+	id, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatalf("generate identity: %v", err)
+	}
+	return id
+}
+
+func TestEncryptedStateRoundTrip(t *testing.T) {
+	id := newIdentity(t)
+	store := NewMemoryStore()
+	b := NewStoreBackend(store, testKey)
+	b.Recipients = []age.Recipient{id.Recipient()}
+	b.Identities = []age.Identity{id}
+
+	if err := b.SaveState(ClusterState{SSHUser: "root", Nodes: []NodeState{{Name: "n1", IP: "10.20.30.40"}}}); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	raw, _, _ := store.Get(context.Background(), testKey)
+	if !IsEncrypted(raw) || bytes.Contains(raw, []byte("10.20.30.40")) {
+		t.Fatalf("stored state must be encrypted")
+	}
+
+	st, err := b.LoadState()
+	if err != nil || st == nil || st.Nodes[0].IP != "10.20.30.40" {
+		t.Fatalf("load encrypted state: %v", err)
+	}
+	revs, _ := b.ListHistory()
+	if rev, err := b.LoadRevision(revs[0].ID); err != nil || len(rev.Nodes) != 1 {
+		t.Fatalf("load encrypted revision: %v", err)
+	}
+
+	noKey := NewStoreBackend(store, testKey)
+	if _, err := noKey.LoadState(); err == nil || !strings.Contains(err.Error(), "SOPS_AGE_KEY") {
+		t.Fatalf("expected missing identity error, got %v", err)
+	}
+}
+
+func TestPlaintextStateStillReadable(t *testing.T) {
+	store := NewMemoryStore()
+	if err := NewStoreBackend(store, testKey).SaveState(ClusterState{SSHUser: "ubuntu"}); err != nil {
+		t.Fatalf("seed: %v", err)
+	}
+
+	id := newIdentity(t)
+	b := NewStoreBackend(store, testKey)
+	b.Recipients = []age.Recipient{id.Recipient()}
+	b.Identities = []age.Identity{id}
+	st, err := b.LoadState()
+	if err != nil || st.SSHUser != "ubuntu" {
+		t.Fatalf("plaintext state must load with identities set: %v", err)
+	}
+}
+
+func TestEncryptedStateIsNotSavedAsPlaintext(t *testing.T) {
+	id := newIdentity(t)
+	store := NewMemoryStore()
+	writer := NewStoreBackend(store, testKey)
+	writer.Recipients = []age.Recipient{id.Recipient()}
+	writer.SaveState(ClusterState{SSHUser: "ubuntu", Nodes: []NodeState{{Name: "n1"}}})
+
+	// CI: the key to read the state, but no recipients to write it
+	b := NewStoreBackend(store, testKey)
+	b.Identities = []age.Identity{id}
+	err := b.UpdateState(func(st *ClusterState) error {
+		st.SSHUser = "root"
+		return nil
+	})
+	if err == nil {
+		t.Fatal("saving an encrypted state without recipients must fail")
+	}
+	if data, _, _ := store.Get(t.Context(), testKey); !IsEncrypted(data) {
+		t.Fatal("state was rewritten as plaintext")
+	}
+
+	if err := b.Rewrap(); err != nil {
+		t.Fatalf("rewrap without recipients decrypts: %v", err)
+	}
+	if data, _, _ := store.Get(t.Context(), testKey); IsEncrypted(data) {
+		t.Fatal("-state-rewrap without recipients must write plaintext")
+	}
+}
+
+func TestRewrapToNewRecipient(t *testing.T) {
+	oldID, newID := newIdentity(t), newIdentity(t)
+	store := NewMemoryStore()
+	b := NewStoreBackend(store, testKey)
+	b.Recipients = []age.Recipient{oldID.Recipient()}
+	b.Identities = []age.Identity{oldID}
+	b.SaveState(ClusterState{Nodes: []NodeState{{Name: "n1"}}})
+
+	b.Recipients = []age.Recipient{newID.Recipient()}
+	if err := b.Rewrap(); err != nil {
+		t.Fatalf("rewrap: %v", err)
+	}
+
+	reader := NewStoreBackend(store, testKey)
+	reader.Identities = []age.Identity{newID}
+	if st, err := reader.LoadState(); err != nil || len(st.Nodes) != 1 {
+		t.Fatalf("new key must read the state: %v", err)
+	}
+	revs, _ := reader.ListHistory()
+	for _, r := range revs {
+		if _, err := reader.LoadRevision(r.ID); err != nil {
+			t.Fatalf("new key must read revision %s: %v", r.ID, err)
+		}
+	}
+
+	reader.Identities = []age.Identity{oldID}
+	if _, err := reader.LoadState(); err == nil {
+		t.Fatal("old key must no longer decrypt the state")
+	}
+}
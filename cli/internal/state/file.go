@@ -0,0 +1,134 @@
+package state
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// FileStore is an ObjectStore on the local filesystem, for offline and lab use.
+// Keys map to files under Root; conditional writes are serialized with a lock file.
+type FileStore struct {
+	Root string
+}
+
+func NewFileStore(root string) (*FileStore, error) {
+	if err := os.MkdirAll(root, 0700); err != nil {
+		return nil, err
+	}
+	return &FileStore{Root: root}, nil
+}
+
+func (f *FileStore) path(key string) string {
+	return filepath.Join(f.Root, filepath.FromSlash(key))
+}
+
+func (f *FileStore) Get(ctx context.Context, key string) ([]byte, string, error) {
+	data, err := os.ReadFile(f.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, "", ErrNotFound
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	return data, etagOf(data), nil
+}
+
+func (f *FileStore) Put(ctx context.Context, key string, data []byte, opts PutOptions) (string, error) {
+	target := f.path(key)
+	if err := os.MkdirAll(filepath.Dir(target), 0700); err != nil {
+		return "", err
+	}
+
+	unlock, err := f.lockFile(target)
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+
+	current, err := os.ReadFile(target)
+	exists := err == nil
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return "", err
+	}
+	if opts.IfAbsent && exists {
+		return "", ErrPreconditionFailed
+	}
+	if opts.IfMatch != "" && (!exists || etagOf(current) != opts.IfMatch) {
+		return "", ErrPreconditionFailed
+	}
+
+	// Write to a temp file and rename, so readers never see a half-written state
+	tmp := target + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp, target); err != nil {
+		os.Remove(tmp)
+		return "", err
+	}
+	return etagOf(data), nil
+}
+
+func (f *FileStore) Delete(ctx context.Context, key string) error {
+	err := os.Remove(f.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func (f *FileStore) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	var out []ObjectInfo
+	err := filepath.WalkDir(f.Root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || strings.HasSuffix(p, ".tmp") || strings.HasSuffix(p, ".lck") {
+			return nil
+		}
+		rel, err := filepath.Rel(f.Root, p)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		out = append(out, ObjectInfo{Key: key, Size: info.Size(), LastModified: info.ModTime()})
+		return nil
+	})
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	return out, err
+}
+
+// lockFile serializes read-check-write cycles on one file between processes
+func (f *FileStore) lockFile(target string) (func(), error) {
+	lck := target + ".lck"
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		fh, err := os.OpenFile(lck, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if err == nil {
+			fh.Close()
+			return func() { os.Remove(lck) }, nil
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return nil, err
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("timeout waiting for %s (remove it if no other run is active)", lck)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
@@ -0,0 +1,116 @@
+package state
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultHistoryLimit is how many state snapshots are kept when HistoryLimit is not set
+const DefaultHistoryLimit = 30
+
+// revisionFormat sorts lexicographically in time order
+const revisionFormat = "20060102T150405.000000000Z"
+
+// Revision is one snapshot of the state under clusters/<name>/history/
+type Revision struct {
+	ID    string    `json:"id"`
+	Saved time.Time `json:"saved_at"`
+	Size  int64     `json:"size"`
+}
+
+// HistoryPrefix returns the key prefix of state snapshots
+func (b *Backend) HistoryPrefix() string {
+	return path.Join(path.Dir(b.StateKey), "history") + "/"
+}
+
+func (b *Backend) historyLimit() int {
+	if b.HistoryLimit == 0 {
+		return DefaultHistoryLimit
+	}
+	return b.HistoryLimit
+}
+
+// snapshot stores a copy of a just-saved state and prunes old revisions
+func (b *Backend) snapshot(ctx context.Context, data []byte) error {
+	if b.historyLimit() < 0 {
+		return nil
+	}
+	rev := time.Now().UTC().Format(revisionFormat)
+	key := b.HistoryPrefix() + rev + ".json"
+	if _, err := b.Store.Put(ctx, key, data, PutOptions{ContentType: "application/json", IfAbsent: true}); err != nil {
+		return fmt.Errorf("snapshot %s: %w", rev, err)
+	}
+	return b.pruneHistory(ctx)
+}
+
+func (b *Backend) pruneHistory(ctx context.Context) error {
+	revs, err := b.ListHistory()
+	if err != nil {
+		return err
+	}
+	limit := b.historyLimit()
+	if len(revs) <= limit {
+		return nil
+	}
+	// ListHistory is newest first
+	for _, r := range revs[limit:] {
+		if err := b.Store.Delete(ctx, b.HistoryPrefix()+r.ID+".json"); err != nil {
+			return fmt.Errorf("prune %s: %w", r.ID, err)
+		}
+	}
+	return nil
+}
+
+// ListHistory returns all stored revisions, newest first
+func (b *Backend) ListHistory() ([]Revision, error) {
+	objs, err := b.Store.List(context.Background(), b.HistoryPrefix())
+	if err != nil {
+		return nil, err
+	}
+	var revs []Revision
+	for _, o := range objs {
+		id := strings.TrimSuffix(strings.TrimPrefix(o.Key, b.HistoryPrefix()), ".json")
+		saved, err := time.Parse(revisionFormat, id)
+		if err != nil {
+			continue
+		}
+		revs = append(revs, Revision{ID: id, Saved: saved, Size: o.Size})
+	}
+	sort.Slice(revs, func(i, j int) bool { return revs[i].ID > revs[j].ID })
+	return revs, nil
+}
+
+// LoadRevision reads a stored revision
+func (b *Backend) LoadRevision(id string) (*ClusterState, error) {
+	data, _, err := b.Store.Get(context.Background(), b.HistoryPrefix()+id+".json")
+	if errors.Is(err, ErrNotFound) {
+		return nil, fmt.Errorf("revision %s not found", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	s, err := b.decode(data)
+	if err != nil {
+		return nil, fmt.Errorf("revision %s: %w", id, err)
+	}
+	return s, nil
+}
+
+// Rollback makes a stored revision the current state. The rollback itself
+// goes through SaveState, so it is recorded as a new revision and can be undone.
+func (b *Backend) Rollback(id string) error {
+	rev, err := b.LoadRevision(id)
+	if err != nil {
+		return err
+	}
+	return b.UpdateState(func(st *ClusterState) error {
+		*st = *rev
+		st.LastUpdated = time.Now()
+		return nil
+	})
+}
@@ -0,0 +1,174 @@
+package state
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"path"
+	"time"
+)
+
+// LockInfo describes who holds the state lock and until when
+type LockInfo struct {
+	ID      string    `json:"id"`
+	Owner   string    `json:"owner"`
+	Command string    `json:"command"`
+	Host    string    `json:"host"`
+	Created time.Time `json:"created_at"`
+	Expires time.Time `json:"expires_at"`
+}
+
+// Expired reports whether the lock is past its expiry and can be taken over
+func (l *LockInfo) Expired() bool {
+	return time.Now().After(l.Expires)
+}
+
+// LockedError is returned when the state is held by another run
+type LockedError struct {
+	Info *LockInfo
+}
+
+func (e *LockedError) Error() string {
+	return fmt.Sprintf("state is locked by %s@%s since %s (command: %s, lock ID: %s, expires: %s)",
+		e.Info.Owner, e.Info.Host, e.Info.Created.Format(time.RFC3339), e.Info.Command, e.Info.ID, e.Info.Expires.Format(time.RFC3339))
+}
+
+// NewLockInfo describes the current process as a lock owner
+func NewLockInfo(command string, ttl time.Duration) *LockInfo {
+	idBytes := make([]byte, 8)
+	rand.Read(idBytes)
+
+	owner := os.Getenv("GITHUB_ACTOR")
+	if owner == "" {
+		if u, err := user.Current(); err == nil {
+			owner = u.Username
+		} else {
+			owner = "unknown"
+		}
+	}
+	host, _ := os.Hostname()
+
+	now := time.Now()
+	return &LockInfo{
+		ID:      hex.EncodeToString(idBytes),
+		Owner:   owner,
+		Command: command,
+		Host:    host,
+		Created: now,
+		Expires: now.Add(ttl),
+	}
+}
+
+// LockKey returns the object key of the lock that guards StateKey
+func (b *Backend) LockKey() string {
+	return path.Join(path.Dir(b.StateKey), "state.lock")
+}
+
+// AcquireLock takes the state lock. An expired lock left by a crashed run is taken over.
+func (b *Backend) AcquireLock(info *LockInfo) error {
+	ctx := context.Background()
+	current, etag, err := b.readLock(ctx)
+	if err != nil {
+		return err
+	}
+
+	opts := PutOptions{ContentType: "application/json"}
+	if current == nil {
+		opts.IfAbsent = true
+	} else if current.Expired() {
+		fmt.Printf("[WARNING] Taking over expired lock %s (%s@%s, expired %s)\n", current.ID, current.Owner, current.Host, current.Expires.Format(time.RFC3339))
+		opts.IfMatch = etag
+	} else {
+		return &LockedError{Info: current}
+	}
+
+	if err := b.writeLock(ctx, info, opts); err != nil {
+		if errors.Is(err, ErrPreconditionFailed) {
+			// Somebody was faster between our read and write
+			if other, _, rErr := b.readLock(ctx); rErr == nil && other != nil {
+				return &LockedError{Info: other}
+			}
+		}
+		return fmt.Errorf("lock write: %w", err)
+	}
+	return nil
+}
+
+// RenewLock pushes the expiry of a lock we hold further into the future
+func (b *Backend) RenewLock(info *LockInfo, ttl time.Duration) error {
+	ctx := context.Background()
+	current, etag, err := b.readLock(ctx)
+	if err != nil {
+		return err
+	}
+	if current == nil || current.ID != info.ID {
+		return fmt.Errorf("lock %s is no longer held by this run", info.ID)
+	}
+
+	info.Expires = time.Now().Add(ttl)
+	return b.writeLock(ctx, info, PutOptions{ContentType: "application/json", IfMatch: etag})
+}
+
+// ReleaseLock removes the lock if it is still ours
+func (b *Backend) ReleaseLock(info *LockInfo) error {
+	ctx := context.Background()
+	current, _, err := b.readLock(ctx)
+	if err != nil {
+		return err
+	}
+	if current == nil {
+		return nil
+	}
+	if current.ID != info.ID {
+		return fmt.Errorf("lock is held by %s (ID %s), not releasing", current.Owner, current.ID)
+	}
+	return b.Store.Delete(ctx, b.LockKey())
+}
+
+// GetLockInfo returns the current lock or nil when the state is unlocked
+func (b *Backend) GetLockInfo() (*LockInfo, error) {
+	info, _, err := b.readLock(context.Background())
+	return info, err
+}
+
+// ForceUnlock removes the lock regardless of its owner. The ID must match the current lock.
+func (b *Backend) ForceUnlock(lockID string) error {
+	ctx := context.Background()
+	current, _, err := b.readLock(ctx)
+	if err != nil {
+		return err
+	}
+	if current == nil {
+		return fmt.Errorf("state is not locked")
+	}
+	if current.ID != lockID {
+		return fmt.Errorf("lock ID mismatch: current lock is %s", current.ID)
+	}
+	return b.Store.Delete(ctx, b.LockKey())
+}
+
+func (b *Backend) readLock(ctx context.Context) (*LockInfo, string, error) {
+	data, etag, err := b.Store.Get(ctx, b.LockKey())
+	if errors.Is(err, ErrNotFound) {
+		return nil, "", nil
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("lock read: %w", err)
+	}
+	var info LockInfo
+	if err := json.Unmarshal(data, &info); err != nil {
+		return nil, "", fmt.Errorf("lock decode: %w", err)
+	}
+	return &info, etag, nil
+}
+
+func (b *Backend) writeLock(ctx context.Context, info *LockInfo, opts PutOptions) error {
+	data, _ := json.MarshalIndent(info, "", "  ")
+	_, err := b.Store.Put(ctx, b.LockKey(), data, opts)
+	return err
+}
@@ -0,0 +1,79 @@
+package state
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryStore is an in-memory ObjectStore with S3-like ETag semantics
+type MemoryStore struct {
+	mu      sync.Mutex
+	objects map[string]memObject
+}
+
+type memObject struct {
+	data     []byte
+	modified time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{objects: make(map[string]memObject)}
+}
+
+func (m *MemoryStore) Get(ctx context.Context, key string) ([]byte, string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	obj, ok := m.objects[key]
+	if !ok {
+		return nil, "", ErrNotFound
+	}
+	out := make([]byte, len(obj.data))
+	copy(out, obj.data)
+	return out, etagOf(obj.data), nil
+}
+
+func (m *MemoryStore) Put(ctx context.Context, key string, data []byte, opts PutOptions) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	current, exists := m.objects[key]
+	if opts.IfAbsent && exists {
+		return "", ErrPreconditionFailed
+	}
+	if opts.IfMatch != "" && (!exists || etagOf(current.data) != opts.IfMatch) {
+		return "", ErrPreconditionFailed
+	}
+	stored := make([]byte, len(data))
+	copy(stored, data)
+	m.objects[key] = memObject{data: stored, modified: time.Now()}
+	return etagOf(stored), nil
+}
+
+func (m *MemoryStore) Delete(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.objects, key)
+	return nil
+}
+
+func (m *MemoryStore) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []ObjectInfo
+	for key, obj := range m.objects {
+		if strings.HasPrefix(key, prefix) {
+			out = append(out, ObjectInfo{Key: key, Size: int64(len(obj.data)), LastModified: obj.modified})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Key < out[j].Key })
+	return out, nil
+}
+
+func etagOf(data []byte) string {
+	sum := md5.Sum(data)
+	return hex.EncodeToString(sum[:])
+}
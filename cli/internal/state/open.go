@@ -0,0 +1,81 @@
+package state
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// StateStore is what commands need from a state backend: load, save and lock the state of one
+// cluster and list the others. *Backend implements it on S3, file and memory object stores;
+// history and transfer commands take *Backend.
+type StateStore interface {
+	LoadState() (*ClusterState, error)
+	SaveState(data ClusterState) error
+	UpdateState(apply func(st *ClusterState) error) error
+
+	AcquireLock(info *LockInfo) error
+	RenewLock(info *LockInfo, ttl time.Duration) error
+	ReleaseLock(info *LockInfo) error
+	GetLockInfo() (*LockInfo, error)
+	ForceUnlock(lockID string) error
+
+	ListClusters() ([]ClusterSummary, error)
+}
+
+var _ StateStore = (*Backend)(nil)
+
+// S3Options holds connection settings for s3:// state URLs
+type S3Options struct {
+	Endpoint  string
+	AccessKey string
+	SecretKey string
+	UseSSL    bool
+}
+
+// StateKeyFor returns the state object key of a cluster below an optional prefix
+func StateKeyFor(prefix, clusterName string) string {
+	return path.Join(prefix, "clusters", clusterName, "state.json")
+}
+
+// Open creates a backend from a URL-style setting:
+//
+//	s3://bucket/prefix   - S3 bucket (endpoint and credentials from opts)
+//	file:///path/to/dir  - local directory
+//	mem://prefix         - in-memory, lost on exit (tests and dry runs)
+func Open(rawURL, clusterName string, opts S3Options) (*Backend, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid state URL %q: %w", rawURL, err)
+	}
+	prefix := strings.Trim(u.Path, "/")
+
+	switch u.Scheme {
+	case "s3":
+		if u.Host == "" {
+			return nil, fmt.Errorf("state URL %q: bucket is missing", rawURL)
+		}
+		if opts.Endpoint == "" {
+			return nil, fmt.Errorf("state URL %q: S3 endpoint is not set", rawURL)
+		}
+		return NewBackend(opts.Endpoint, opts.AccessKey, opts.SecretKey, u.Host, StateKeyFor(prefix, clusterName), opts.UseSSL)
+	case "file":
+		// file:///abs/path or file://relative/path
+		dir := filepath.FromSlash(u.Host + u.Path)
+		if dir == "" {
+			return nil, fmt.Errorf("state URL %q: path is missing", rawURL)
+		}
+		store, err := NewFileStore(dir)
+		if err != nil {
+			return nil, err
+		}
+		return NewStoreBackend(store, StateKeyFor("", clusterName)), nil
+	case "mem":
+		return NewStoreBackend(NewMemoryStore(), StateKeyFor(strings.Trim(u.Host+u.Path, "/"), clusterName)), nil
+	default:
+		return nil, fmt.Errorf("unsupported state URL scheme %q (use s3://, file:// or mem://)", u.Scheme)
+	}
+}
@@ -0,0 +1,84 @@
+package state
+
+import (
+	"context"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ClusterSummary is one entry of the cluster registry
+type ClusterSummary struct {
+	Name        string         `json:"name"`
+	Nodes       int            `json:"nodes"`
+	Roles       map[string]int `json:"roles"`
+	LastUpdated time.Time      `json:"last_updated"`
+	Lock        *LockInfo      `json:"lock,omitempty"`
+	DiskGB      int            `json:"disk_gb"`
+	Error       string         `json:"error,omitempty"`
+}
+
+// Locked reports whether a live (not expired) lock is held on the cluster
+func (c ClusterSummary) Locked() bool {
+	return c.Lock != nil && !c.Lock.Expired()
+}
+
+// ClustersPrefix returns the key prefix all cluster states live under, e.g. "team/clusters/"
+func (b *Backend) ClustersPrefix() string {
+	return path.Dir(path.Dir(b.StateKey)) + "/"
+}
+
+// ForCluster returns a backend for another cluster in the same store, sharing its settings
+func (b *Backend) ForCluster(name string) *Backend {
+	return &Backend{
+		Client:       b.Client,
+		BucketName:   b.BucketName,
+		StateKey:     path.Join(b.ClustersPrefix(), name, "state.json"),
+		Store:        b.Store,
+		Recipients:   b.Recipients,
+		Identities:   b.Identities,
+		HistoryLimit: b.HistoryLimit,
+	}
+}
+
+// ListClusters walks the clusters/ prefix and summarizes every stored state, sorted by name.
+// A state that cannot be read (encrypted to another key, newer schema) is listed with Error set.
+func (b *Backend) ListClusters() ([]ClusterSummary, error) {
+	prefix := b.ClustersPrefix()
+	objs, err := b.Store.List(context.Background(), prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	var out []ClusterSummary
+	for _, o := range objs {
+		name, ok := strings.CutSuffix(strings.TrimPrefix(o.Key, prefix), "/state.json")
+		if !ok || name == "" || strings.Contains(name, "/") {
+			continue
+		}
+		cb := b.ForCluster(name)
+		sum := ClusterSummary{Name: name, Roles: map[string]int{}, LastUpdated: o.LastModified}
+
+		if st, err := cb.LoadState(); err != nil {
+			sum.Error = err.Error()
+		} else if st != nil {
+			sum.Nodes = len(st.Nodes)
+			if !st.LastUpdated.IsZero() {
+				sum.LastUpdated = st.LastUpdated
+			}
+			for _, n := range st.Nodes {
+				sum.Roles[n.Role]++
+				for _, d := range n.Disks {
+					sum.DiskGB += d.Size
+				}
+			}
+		}
+		if info, err := cb.GetLockInfo(); err == nil {
+			sum.Lock = info
+		}
+		out = append(out, sum)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out, nil
+}
@@ -0,0 +1,37 @@
+package state
+
+import (
+	"testing"
+	"time"
+)
+
+func TestListClusters(t *testing.T) {
+	store := NewMemoryStore()
+	prod := NewStoreBackend(store, StateKeyFor("team", "prod"))
+	prod.SaveState(ClusterState{Nodes: []NodeState{
+		{Name: "prod-master-1", Role: "master", Disks: []DiskState{{Size: 20}, {Size: 100}}},
+		{Name: "prod-worker-1", Role: "worker", Disks: []DiskState{{Size: 20}}},
+		{Name: "prod-worker-2", Role: "worker"},
+	}})
+	if err := prod.AcquireLock(NewLockInfo("-cluster", time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	NewStoreBackend(store, StateKeyFor("team", "dev")).SaveState(ClusterState{})
+	// Another prefix is not part of this registry
+	NewStoreBackend(store, StateKeyFor("other", "x")).SaveState(ClusterState{})
+
+	clusters, err := NewStoreBackend(store, StateKeyFor("team", "new")).ListClusters()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(clusters) != 2 || clusters[0].Name != "dev" || clusters[1].Name != "prod" {
+		t.Fatalf("unexpected clusters: %+v", clusters)
+	}
+	p := clusters[1]
+	if p.Nodes != 3 || p.Roles["worker"] != 2 || p.Roles["master"] != 1 || p.DiskGB != 140 || !p.Locked() {
+		t.Fatalf("unexpected summary: %+v", p)
+	}
+	if clusters[0].Locked() {
+		t.Fatal("dev must not be locked")
+	}
+}
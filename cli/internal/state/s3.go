@@ -3,17 +3,30 @@ package state
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/url"
+	"strings"
 	"time"
 
+	"filippo.io/age"
 	"github.com/minio/minio-go/v7"
 	"github.com/minio/minio-go/v7/pkg/credentials"
 )
 
+// MaxUpdateAttempts limits how often UpdateState re-applies a change after a conflict
+const MaxUpdateAttempts = 5
+
+// ErrNoChanges can be returned from an UpdateState callback to skip saving
+var ErrNoChanges = errors.New("no state changes")
+
 type DiskState struct {
 	ID         string `json:"id"`
+	Name       string `json:"name,omitempty"` // config disk name, empty for disks from before names
 	Size       int    `json:"size"`
 	Type       string `json:"type"`
 	Bootable   bool   `json:"bootable"`
@@ -48,10 +61,38 @@ type ClusterState struct {
 	Nodes       []NodeState `json:"nodes"`
 }
 
+// Fingerprint identifies a state's content, e.g. to detect that it changed since a plan was made.
+// A missing state has an empty fingerprint.
+func Fingerprint(st *ClusterState) string {
+	if st == nil {
+		return ""
+	}
+	data, _ := json.Marshal(st)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
 type Backend struct {
 	Client     *minio.Client
 	BucketName string
 	StateKey   string
+	Store      ObjectStore
+
+	// Recipients enable client-side age encryption on save, Identities decrypt on load
+	Recipients []age.Recipient
+	Identities []age.Identity
+
+	// HistoryLimit is how many snapshots to keep under history/ (0 = DefaultHistoryLimit, <0 = disabled)
+	HistoryLimit int
+
+	// ETag of the state seen by the last LoadState/SaveState, used to detect concurrent writes
+	etag   string
+	loaded bool
+	// newer is set when the stored state has a newer schema; such a state is never overwritten
+	newer error
+	// encrypted is set when the loaded state was an age file: it is not saved as plaintext,
+	// except by Rewrap (decrypt) which sets decrypting
+	encrypted, decrypting bool
 }
 
 func NewBackend(endpoint, accessKey, secretKey, bucket, key string, useSSL bool) (*Backend, error) {
@@ -62,38 +103,112 @@ func NewBackend(endpoint, accessKey, secretKey, bucket, key string, useSSL bool)
 	if err != nil {
 		return nil, err
 	}
-	return &Backend{Client: minioClient, BucketName: bucket, StateKey: key}, nil
+	return &Backend{
+		Client:     minioClient,
+		BucketName: bucket,
+		StateKey:   key,
+		Store:      &s3Store{client: minioClient, bucket: bucket},
+	}, nil
+}
+
+// NewStoreBackend creates a backend on top of an arbitrary ObjectStore
+func NewStoreBackend(store ObjectStore, key string) *Backend {
+	return &Backend{Store: store, StateKey: key}
 }
 
+// SaveState writes the state stamped with CurrentVersion and records a snapshot in the history. If the state was loaded by
+// this backend, the write only succeeds when the remote object is unchanged since then,
+// otherwise a *ConflictError is returned.
 func (b *Backend) SaveState(data ClusterState) error {
+	if b.newer != nil {
+		return b.newer
+	}
+	if b.encrypted && len(b.Recipients) == 0 && !b.decrypting {
+		return fmt.Errorf("state %s is encrypted: set STATE_AGE_RECIPIENTS to save it (-state-rewrap without recipients decrypts it)", b.StateKey)
+	}
+	data.Version = CurrentVersion
+	jsonData, err := b.encode(data)
+	if err != nil {
+		return err
+	}
+
+	opts := PutOptions{ContentType: "application/json"}
+	if b.loaded {
+		if b.etag != "" {
+			opts.IfMatch = b.etag
+		} else {
+			opts.IfAbsent = true
+		}
+	}
+
 	ctx := context.Background()
-	exists, err := b.Client.BucketExists(ctx, b.BucketName)
+	etag, err := b.Store.Put(ctx, b.StateKey, jsonData, opts)
 	if err != nil {
-		return fmt.Errorf("bucket check: %w", err)
+		if errors.Is(err, ErrPreconditionFailed) {
+			return &ConflictError{Key: b.StateKey, Expected: b.etag}
+		}
+		return err
 	}
-	if !exists {
-		b.Client.MakeBucket(ctx, b.BucketName, minio.MakeBucketOptions{})
+	b.etag = etag
+	b.loaded = true
+
+	// The state itself is saved at this point, a failed snapshot is not fatal
+	if err := b.snapshot(ctx, jsonData); err != nil {
+		fmt.Printf("[WARNING] State history: %v\n", err)
 	}
-	jsonData, _ := json.MarshalIndent(data, "", "  ")
-	_, err = b.Client.PutObject(ctx, b.BucketName, b.StateKey, bytes.NewReader(jsonData), int64(len(jsonData)), minio.PutObjectOptions{ContentType: "application/json"})
-	return err
+	return nil
 }
 
+// LoadState reads the state and remembers its ETag. Returns nil without error if there is no state yet.
 func (b *Backend) LoadState() (*ClusterState, error) {
-	ctx := context.Background()
-	obj, err := b.Client.GetObject(ctx, b.BucketName, b.StateKey, minio.GetObjectOptions{})
+	data, etag, err := b.Store.Get(context.Background(), b.StateKey)
+	if errors.Is(err, ErrNotFound) {
+		b.etag = ""
+		b.loaded = true
+		b.encrypted = false
+		return nil, nil
+	}
 	if err != nil {
 		return nil, err
 	}
-	defer obj.Close()
-	if _, err := obj.Stat(); err != nil {
-		return nil, nil
-	}
-	var s ClusterState
-	if err := json.NewDecoder(obj).Decode(&s); err != nil {
+	s, err := b.decode(data)
+	if err != nil {
+		var se *SchemaError
+		if errors.As(err, &se) {
+			b.newer = err
+		}
 		return nil, err
 	}
-	return &s, nil
+	b.etag = etag
+	b.loaded = true
+	b.encrypted = IsEncrypted(data)
+	return s, nil
+}
+
+// UpdateState runs a load-modify-save cycle and, when another run saved the state in between,
+// reloads it and applies the change again. apply may return ErrNoChanges to skip the save.
+func (b *Backend) UpdateState(apply func(st *ClusterState) error) error {
+	for attempt := 1; attempt <= MaxUpdateAttempts; attempt++ {
+		st, err := b.LoadState()
+		if err != nil {
+			return err
+		}
+		if st == nil {
+			st = &ClusterState{}
+		}
+		if err := apply(st); err != nil {
+			if errors.Is(err, ErrNoChanges) {
+				return nil
+			}
+			return err
+		}
+		err = b.SaveState(*st)
+		if err == nil || !IsConflict(err) {
+			return err
+		}
+		fmt.Printf("[WARNING] State changed concurrently (attempt %d/%d). Reloading and re-applying...\n", attempt, MaxUpdateAttempts)
+	}
+	return &ConflictError{Key: b.StateKey, Expected: b.etag}
 }
 
 func (b *Backend) GetPresignedURL(objectKey string, expiry time.Duration) (string, error) {
@@ -133,3 +248,76 @@ func (b *Backend) UploadFile(objectKey, filePath string) error {
 	fmt.Printf("      Successfully uploaded %s of size %d\n", objectKey, info.Size)
 	return nil
 }
+
+// s3Store is the ObjectStore implementation backed by an S3 bucket
+type s3Store struct {
+	client *minio.Client
+	bucket string
+}
+
+func (s *s3Store) Get(ctx context.Context, key string) ([]byte, string, error) {
+	obj, err := s.client.GetObject(ctx, s.bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, "", err
+	}
+	defer obj.Close()
+	stat, err := obj.Stat()
+	if err != nil {
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			return nil, "", ErrNotFound
+		}
+		return nil, "", err
+	}
+	data, err := io.ReadAll(obj)
+	if err != nil {
+		return nil, "", err
+	}
+	return data, strings.Trim(stat.ETag, "\""), nil
+}
+
+func (s *s3Store) Put(ctx context.Context, key string, data []byte, opts PutOptions) (string, error) {
+	exists, err := s.client.BucketExists(ctx, s.bucket)
+	if err != nil {
+		return "", fmt.Errorf("bucket check: %w", err)
+	}
+	if !exists {
+		if err := s.client.MakeBucket(ctx, s.bucket, minio.MakeBucketOptions{}); err != nil {
+			return "", fmt.Errorf("bucket create: %w", err)
+		}
+	}
+
+	putOpts := minio.PutObjectOptions{ContentType: opts.ContentType}
+	if opts.IfMatch != "" {
+		putOpts.SetMatchETag(opts.IfMatch)
+	}
+	if opts.IfAbsent {
+		putOpts.SetMatchETagExcept("*")
+	}
+	info, err := s.client.PutObject(ctx, s.bucket, key, bytes.NewReader(data), int64(len(data)), putOpts)
+	if err != nil {
+		resp := minio.ToErrorResponse(err)
+		if resp.Code == "PreconditionFailed" || resp.StatusCode == 412 {
+			return "", ErrPreconditionFailed
+		}
+		return "", err
+	}
+	return strings.Trim(info.ETag, "\""), nil
+}
+
+func (s *s3Store) Delete(ctx context.Context, key string) error {
+	return s.client.RemoveObject(ctx, s.bucket, key, minio.RemoveObjectOptions{})
+}
+
+func (s *s3Store) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	var out []ObjectInfo
+	for obj := range s.client.ListObjects(ctx, s.bucket, minio.ListObjectsOptions{Prefix: prefix, Recursive: true}) {
+		if obj.Err != nil {
+			if minio.ToErrorResponse(obj.Err).Code == "NoSuchBucket" {
+				return nil, nil
+			}
+			return nil, obj.Err
+		}
+		out = append(out, ObjectInfo{Key: obj.Key, Size: obj.Size, LastModified: obj.LastModified})
+	}
+	return out, nil
+}
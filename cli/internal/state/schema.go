@@ -0,0 +1,142 @@
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// CurrentVersion is the state schema written by this binary
+const CurrentVersion = "1.10"
+
+// migration upgrades a raw state document from one schema version to the next.
+// Migrations work on the decoded JSON object so renamed or removed fields can be handled
+// before the document is unmarshalled into the current structs.
+type migration struct {
+	From, To string
+	Apply    func(doc map[string]any) error
+}
+
+// migrations is the ordered upgrade path; the last To must be CurrentVersion.
+// Before 1.10 the CLI only ever wrote unversioned documents or "1.9", so no other old
+// version exists in the field; anything else is refused as "no migration path".
+var migrations = []migration{
+	{From: "", To: "1.9", Apply: migrateUnversioned},
+	{From: "1.9", To: "1.10", Apply: migrateDiskNames},
+}
+
+// SchemaError is returned for a state written by a newer binary
+type SchemaError struct {
+	Version string
+}
+
+func (e *SchemaError) Error() string {
+	return fmt.Sprintf("state schema %s is newer than supported %s, upgrade the CLI", e.Version, CurrentVersion)
+}
+
+// migrateUnversioned upgrades documents from before the version field. They have no ssh_user
+// (the nodes were always provisioned as root) and may store "nodes": null. Later optional
+// fields (ssh_port, critical, owner/group/mode) are absent, and their zero values already
+// mean "default", so they need no rewrite.
+func migrateUnversioned(doc map[string]any) error {
+	if u, _ := doc["ssh_user"].(string); u == "" {
+		doc["ssh_user"] = "root"
+	}
+	if doc["nodes"] == nil {
+		doc["nodes"] = []any{}
+	}
+	return nil
+}
+
+// migrateDiskNames upgrades 1.9 documents, whose disks carry no config name. The name stays
+// empty: plan matches such disks to the config by mount point or size and records the name
+// on the next write. The version bump keeps 1.9 binaries from dropping names they don't know.
+func migrateDiskNames(doc map[string]any) error {
+	return nil
+}
+
+// upgrade decodes a state document and runs all migrations needed to reach CurrentVersion
+func upgrade(data []byte) (*ClusterState, error) {
+	var doc map[string]any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	version, _ := doc["version"].(string)
+
+	cmp, err := compareVersions(version, CurrentVersion)
+	if err != nil {
+		return nil, err
+	}
+	if cmp > 0 {
+		return nil, &SchemaError{Version: version}
+	}
+
+	if cmp < 0 {
+		for _, m := range migrations {
+			if c, _ := compareVersions(version, m.From); c != 0 {
+				continue
+			}
+			if err := m.Apply(doc); err != nil {
+				return nil, fmt.Errorf("migrate state %q -> %s: %w", m.From, m.To, err)
+			}
+			version = m.To
+		}
+		if version != CurrentVersion {
+			return nil, fmt.Errorf("no migration path from state schema %q", doc["version"])
+		}
+		doc["version"] = CurrentVersion
+		if data, err = json.Marshal(doc); err != nil {
+			return nil, err
+		}
+	}
+
+	var s ClusterState
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// compareVersions compares dotted numeric versions; "" sorts before everything
+func compareVersions(a, b string) (int, error) {
+	pa, err := parseVersion(a)
+	if err != nil {
+		return 0, err
+	}
+	pb, err := parseVersion(b)
+	if err != nil {
+		return 0, err
+	}
+	for i := 0; i < len(pa) || i < len(pb); i++ {
+		var x, y int
+		if i < len(pa) {
+			x = pa[i]
+		}
+		if i < len(pb) {
+			y = pb[i]
+		}
+		if x != y {
+			if x < y {
+				return -1, nil
+			}
+			return 1, nil
+		}
+	}
+	return 0, nil
+}
+
+func parseVersion(v string) ([]int, error) {
+	if v == "" {
+		return nil, nil
+	}
+	var out []int
+	for _, p := range strings.Split(v, ".") {
+		n, err := strconv.Atoi(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid state schema version %q", v)
+		}
+		out = append(out, n)
+	}
+	return out, nil
+}
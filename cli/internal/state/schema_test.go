@@ -0,0 +1,108 @@
+package state
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite testdata/schema/*.golden files")
+
+// TestMigrationsGolden upgrades every historical document in testdata/schema and compares
+// the result with its .golden file. Add a new input file whenever CurrentVersion changes.
+func TestMigrationsGolden(t *testing.T) {
+	inputs, err := filepath.Glob("testdata/schema/*.json")
+	if err != nil || len(inputs) == 0 {
+		t.Fatalf("no schema fixtures: %v", err)
+	}
+	for _, in := range inputs {
+		t.Run(filepath.Base(in), func(t *testing.T) {
+			raw, err := os.ReadFile(in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			st, err := upgrade(raw)
+			if err != nil {
+				t.Fatalf("upgrade: %v", err)
+			}
+			if st.Version != CurrentVersion {
+				t.Fatalf("version %q, want %q", st.Version, CurrentVersion)
+			}
+			got, _ := json.MarshalIndent(st, "", "  ")
+			got = append(got, '\n')
+
+			golden := strings.TrimSuffix(in, ".json") + ".golden"
+			if *updateGolden {
+				if err := os.WriteFile(golden, got, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("read golden (run with -update to create): %v", err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Fatalf("migrated state differs from %s:\n%s", golden, got)
+			}
+		})
+	}
+}
+
+func TestMigrationChainEndsAtCurrent(t *testing.T) {
+	if last := migrations[len(migrations)-1].To; last != CurrentVersion {
+		t.Fatalf("last migration ends at %s, CurrentVersion is %s", last, CurrentVersion)
+	}
+}
+
+func TestNewerSchemaIsNeverOverwritten(t *testing.T) {
+	store := NewMemoryStore()
+	newer := []byte(`{"version": "99.0", "ssh_user": "root", "nodes": []}`)
+	store.Put(t.Context(), testKey, newer, PutOptions{})
+
+	b := NewStoreBackend(store, testKey)
+	_, err := b.LoadState()
+	if _, ok := err.(*SchemaError); !ok {
+		t.Fatalf("expected *SchemaError, got %v", err)
+	}
+	if err := b.SaveState(ClusterState{SSHUser: "root"}); err == nil {
+		t.Fatal("save over a newer schema must be refused")
+	}
+	if err := b.UpdateState(func(st *ClusterState) error { return nil }); err == nil {
+		t.Fatal("update of a newer schema must be refused")
+	}
+	if data, _, _ := store.Get(t.Context(), testKey); !bytes.Equal(data, newer) {
+		t.Fatal("newer state was modified")
+	}
+}
+
+func TestSaveStampsCurrentVersion(t *testing.T) {
+	b := NewStoreBackend(NewMemoryStore(), testKey)
+	if err := b.SaveState(ClusterState{Version: "1.0"}); err != nil {
+		t.Fatal(err)
+	}
+	st, _ := b.LoadState()
+	if st.Version != CurrentVersion {
+		t.Fatalf("saved version %q, want %q", st.Version, CurrentVersion)
+	}
+}
+
+func TestUpgradeOldVersions(t *testing.T) {
+	st, err := upgrade([]byte(`{"nodes": [{"name": "m1", "disks": [{"id": "d1", "size": 50}]}]}`))
+	if err != nil {
+		t.Fatalf("unversioned: %v", err)
+	}
+	if st.Version != CurrentVersion || st.SSHUser != "root" || st.Nodes[0].Disks[0].Name != "" {
+		t.Fatalf("unversioned upgraded to %+v", st)
+	}
+
+	for _, v := range []string{"1.0", "1.5", "1.8.2"} {
+		_, err := upgrade([]byte(`{"version": "` + v + `", "nodes": []}`))
+		if err == nil || !strings.Contains(err.Error(), "no migration path") {
+			t.Fatalf("version %s: expected no migration path, got %v", v, err)
+		}
+	}
+}
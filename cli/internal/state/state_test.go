@@ -0,0 +1,268 @@
+package state
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+const testKey = "clusters/test/state.json"
+
+func TestSaveStateDetectsConcurrentWrite(t *testing.T) {
+	store := NewMemoryStore()
+	seed := NewStoreBackend(store, testKey)
+	if err := seed.SaveState(ClusterState{Version: "1.9", Nodes: []NodeState{{Name: "n1"}}}); err != nil {
+		t.Fatalf("seed save: %v", err)
+	}
+
+	a := NewStoreBackend(store, testKey)
+	b := NewStoreBackend(store, testKey)
+	stA, err := a.LoadState()
+	if err != nil || stA == nil {
+		t.Fatalf("load a: %v", err)
+	}
+	stB, err := b.LoadState()
+	if err != nil || stB == nil {
+		t.Fatalf("load b: %v", err)
+	}
+
+	stB.Nodes = append(stB.Nodes, NodeState{Name: "n2"})
+	if err := b.SaveState(*stB); err != nil {
+		t.Fatalf("save b: %v", err)
+	}
+
+	stA.SSHUser = "root"
+	err = a.SaveState(*stA)
+	if !IsConflict(err) {
+		t.Fatalf("expected conflict, got %v", err)
+	}
+
+	// After a reload the write goes through
+	stA, err = a.LoadState()
+	if err != nil {
+		t.Fatalf("reload a: %v", err)
+	}
+	stA.SSHUser = "root"
+	if err := a.SaveState(*stA); err != nil {
+		t.Fatalf("save after reload: %v", err)
+	}
+	// Consecutive saves by the same backend keep working
+	if err := a.SaveState(*stA); err != nil {
+		t.Fatalf("second save: %v", err)
+	}
+}
+
+func TestSaveStateDetectsConcurrentCreate(t *testing.T) {
+	store := NewMemoryStore()
+	a := NewStoreBackend(store, testKey)
+	b := NewStoreBackend(store, testKey)
+
+	if st, err := a.LoadState(); err != nil || st != nil {
+		t.Fatalf("expected empty state, got %v, %v", st, err)
+	}
+	if _, err := b.LoadState(); err != nil {
+		t.Fatalf("load b: %v", err)
+	}
+	if err := b.SaveState(ClusterState{Version: "1.9"}); err != nil {
+		t.Fatalf("save b: %v", err)
+	}
+	if err := a.SaveState(ClusterState{Version: "1.9"}); !IsConflict(err) {
+		t.Fatalf("expected conflict, got %v", err)
+	}
+}
+
+func TestUpdateStateReappliesAfterConflict(t *testing.T) {
+	store := NewMemoryStore()
+	seed := NewStoreBackend(store, testKey)
+	if err := seed.SaveState(ClusterState{Nodes: []NodeState{{Name: "n1"}}}); err != nil {
+		t.Fatalf("seed save: %v", err)
+	}
+
+	a := NewStoreBackend(store, testKey)
+	other := NewStoreBackend(store, testKey)
+	calls := 0
+	err := a.UpdateState(func(st *ClusterState) error {
+		calls++
+		if calls == 1 {
+			// Another run sneaks in between our load and save
+			otherSt, _ := other.LoadState()
+			otherSt.Nodes = append(otherSt.Nodes, NodeState{Name: "n2"})
+			if err := other.SaveState(*otherSt); err != nil {
+				t.Fatalf("concurrent save: %v", err)
+			}
+		}
+		st.Nodes = append(st.Nodes, NodeState{Name: "n3"})
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if calls != 2 {
+		t.Fatalf("expected 2 apply calls, got %d", calls)
+	}
+
+	final, _ := NewStoreBackend(store, testKey).LoadState()
+	var names []string
+	for _, n := range final.Nodes {
+		names = append(names, n.Name)
+	}
+	if len(names) != 3 || names[1] != "n2" || names[2] != "n3" {
+		t.Fatalf("unexpected nodes after update: %v", names)
+	}
+}
+
+func TestUpdateStateNoChanges(t *testing.T) {
+	store := NewMemoryStore()
+	b := NewStoreBackend(store, testKey)
+	if err := b.UpdateState(func(st *ClusterState) error { return ErrNoChanges }); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if _, _, err := store.Get(context.Background(), testKey); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("state should not be written, got %v", err)
+	}
+}
+
+func TestLockLifecycle(t *testing.T) {
+	store := NewMemoryStore()
+	a := NewStoreBackend(store, testKey)
+	b := NewStoreBackend(store, testKey)
+
+	lockA := NewLockInfo("ops-cli -cluster", time.Minute)
+	if err := a.AcquireLock(lockA); err != nil {
+		t.Fatalf("acquire a: %v", err)
+	}
+
+	var locked *LockedError
+	if err := b.AcquireLock(NewLockInfo("ops-cli -sync", time.Minute)); !errors.As(err, &locked) {
+		t.Fatalf("expected LockedError, got %v", err)
+	}
+	if locked.Info.ID != lockA.ID {
+		t.Fatalf("lock holder mismatch: %s != %s", locked.Info.ID, lockA.ID)
+	}
+
+	if err := a.RenewLock(lockA, time.Minute); err != nil {
+		t.Fatalf("renew: %v", err)
+	}
+	if err := a.ReleaseLock(lockA); err != nil {
+		t.Fatalf("release: %v", err)
+	}
+	if info, err := b.GetLockInfo(); err != nil || info != nil {
+		t.Fatalf("expected no lock, got %v, %v", info, err)
+	}
+}
+
+func TestLockTakeoverAndForceUnlock(t *testing.T) {
+	store := NewMemoryStore()
+	a := NewStoreBackend(store, testKey)
+	b := NewStoreBackend(store, testKey)
+
+	stale := NewLockInfo("ops-cli -cluster", -time.Minute)
+	if err := a.AcquireLock(stale); err != nil {
+		t.Fatalf("acquire stale: %v", err)
+	}
+	fresh := NewLockInfo("ops-cli -sync", time.Minute)
+	if err := b.AcquireLock(fresh); err != nil {
+		t.Fatalf("expired lock should be taken over: %v", err)
+	}
+	if err := a.ReleaseLock(stale); err == nil {
+		t.Fatal("releasing a lock we no longer hold must fail")
+	}
+
+	if err := a.ForceUnlock("wrong-id"); err == nil {
+		t.Fatal("force unlock with wrong ID must fail")
+	}
+	if err := a.ForceUnlock(fresh.ID); err != nil {
+		t.Fatalf("force unlock: %v", err)
+	}
+	if info, _ := a.GetLockInfo(); info != nil {
+		t.Fatalf("lock still present: %+v", info)
+	}
+}
+
+func TestHistoryRetentionAndRollback(t *testing.T) {
+	store := NewMemoryStore()
+	b := NewStoreBackend(store, testKey)
+	b.HistoryLimit = 3
+
+	for i := 1; i <= 4; i++ {
+		if err := b.SaveState(ClusterState{SSHUser: "root", Nodes: make([]NodeState, i)}); err != nil {
+			t.Fatalf("save %d: %v", i, err)
+		}
+	}
+	revs, err := b.ListHistory()
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(revs) != 3 {
+		t.Fatalf("expected 3 revisions after pruning, got %d", len(revs))
+	}
+	oldest, err := b.LoadRevision(revs[2].ID)
+	if err != nil {
+		t.Fatalf("load revision: %v", err)
+	}
+	if len(oldest.Nodes) != 2 {
+		t.Fatalf("oldest kept revision should have 2 nodes, got %d", len(oldest.Nodes))
+	}
+
+	if err := b.Rollback(revs[2].ID); err != nil {
+		t.Fatalf("rollback: %v", err)
+	}
+	current, _ := b.LoadState()
+	if len(current.Nodes) != 2 {
+		t.Fatalf("rolled back state should have 2 nodes, got %d", len(current.Nodes))
+	}
+	revs, _ = b.ListHistory()
+	latest, _ := b.LoadRevision(revs[0].ID)
+	if len(revs) != 3 || len(latest.Nodes) != 2 {
+		t.Fatalf("rollback must be recorded as the newest revision")
+	}
+}
+
+func TestOpenBackends(t *testing.T) {
+	dir := t.TempDir()
+	a, err := Open("file://"+dir, "lab", S3Options{})
+	if err != nil {
+		t.Fatalf("open file backend: %v", err)
+	}
+	b, _ := Open("file://"+dir, "lab", S3Options{})
+	if a.StateKey != "clusters/lab/state.json" {
+		t.Fatalf("unexpected key %s", a.StateKey)
+	}
+
+	a.LoadState()
+	b.LoadState()
+	if err := a.SaveState(ClusterState{SSHUser: "root"}); err != nil {
+		t.Fatalf("save a: %v", err)
+	}
+	if err := b.SaveState(ClusterState{SSHUser: "ubuntu"}); !IsConflict(err) {
+		t.Fatalf("file backend must detect concurrent writes, got %v", err)
+	}
+	revs, err := a.ListHistory()
+	if err != nil || len(revs) != 1 {
+		t.Fatalf("expected 1 revision on disk, got %d (%v)", len(revs), err)
+	}
+
+	var sa, sb StateStore = a, b
+	lock := NewLockInfo("ops-cli -cluster", time.Minute)
+	if err := sa.AcquireLock(lock); err != nil {
+		t.Fatalf("lock file backend: %v", err)
+	}
+	var locked *LockedError
+	if err := sb.AcquireLock(NewLockInfo("ops-cli -sync", time.Minute)); !errors.As(err, &locked) {
+		t.Fatalf("file backend lock must be exclusive, got %v", err)
+	}
+	if err := sa.ReleaseLock(lock); err != nil {
+		t.Fatalf("unlock file backend: %v", err)
+	}
+
+	if m, err := Open("mem://team", "x", S3Options{}); err != nil || m.StateKey != "team/clusters/x/state.json" {
+		t.Fatalf("open mem backend: %v", err)
+	}
+	if _, err := Open("s3://bucket", "x", S3Options{}); err == nil {
+		t.Fatal("s3 backend without endpoint must fail")
+	}
+	if _, err := Open("ftp://host", "x", S3Options{}); err == nil {
+		t.Fatal("unknown scheme must fail")
+	}
+}
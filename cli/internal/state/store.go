@@ -0,0 +1,59 @@
+package state
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	// ErrNotFound is returned by ObjectStore.Get when the key does not exist
+	ErrNotFound = errors.New("object not found")
+	// ErrPreconditionFailed is returned by ObjectStore.Put when IfMatch/IfAbsent does not hold
+	ErrPreconditionFailed = errors.New("precondition failed")
+)
+
+// PutOptions controls conditional writes
+type PutOptions struct {
+	ContentType string
+	IfMatch     string // write only if the current ETag equals this value
+	IfAbsent    bool   // write only if the object does not exist yet
+}
+
+// ObjectInfo describes a stored object in a listing
+type ObjectInfo struct {
+	Key          string
+	Size         int64
+	LastModified time.Time
+}
+
+// ObjectStore is the minimal blob API the state backend is built on
+type ObjectStore interface {
+	// Get returns the object body and its ETag
+	Get(ctx context.Context, key string) ([]byte, string, error)
+	// Put writes the object and returns its new ETag
+	Put(ctx context.Context, key string, data []byte, opts PutOptions) (string, error)
+	Delete(ctx context.Context, key string) error
+	// List returns all objects whose key starts with prefix
+	List(ctx context.Context, prefix string) ([]ObjectInfo, error)
+}
+
+// ConflictError is returned by SaveState when the remote state changed since LoadState
+type ConflictError struct {
+	Key      string
+	Expected string // ETag seen by LoadState, empty if the state did not exist
+}
+
+func (e *ConflictError) Error() string {
+	if e.Expected == "" {
+		return fmt.Sprintf("state %s was created by another run since it was loaded", e.Key)
+	}
+	return fmt.Sprintf("state %s was modified by another run since it was loaded (expected ETag %s)", e.Key, e.Expected)
+}
+
+// IsConflict reports whether err is a state write conflict
+func IsConflict(err error) bool {
+	var c *ConflictError
+	return errors.As(err, &c)
+}
@@ -0,0 +1,87 @@
+package state
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// ExportState returns the current state as plaintext JSON, decrypted if needed
+func (b *Backend) ExportState() ([]byte, error) {
+	st, err := b.LoadState()
+	if err != nil {
+		return nil, err
+	}
+	if st == nil {
+		return nil, fmt.Errorf("no state stored at %s", b.StateKey)
+	}
+	return json.MarshalIndent(st, "", "  ")
+}
+
+// ImportState replaces the current state with an exported document. Plaintext and
+// age-encrypted documents are accepted, older schemas are migrated. The import is
+// saved as a new revision, so it can be rolled back.
+func (b *Backend) ImportState(data []byte) error {
+	imported, err := b.decode(data)
+	if err != nil {
+		return fmt.Errorf("import: %w", err)
+	}
+	return b.UpdateState(func(st *ClusterState) error {
+		*st = *imported
+		return nil
+	})
+}
+
+// CopyTo copies the state and its history to stateKey in another store as stored (encrypted
+// objects stay encrypted). The lock is not copied. It fails if the destination already has a state.
+func (b *Backend) CopyTo(dst ObjectStore, stateKey string) error {
+	ctx := context.Background()
+	if _, _, err := dst.Get(ctx, stateKey); err == nil {
+		return fmt.Errorf("destination already has a state at %s", stateKey)
+	} else if !errors.Is(err, ErrNotFound) {
+		return fmt.Errorf("destination: %w", err)
+	}
+
+	current, _, err := b.Store.Get(ctx, b.StateKey)
+	if errors.Is(err, ErrNotFound) {
+		return fmt.Errorf("no state stored at %s", b.StateKey)
+	}
+	if err != nil {
+		return err
+	}
+
+	srcDir := path.Dir(b.StateKey) + "/"
+	dstDir := path.Dir(stateKey) + "/"
+	objs, err := b.Store.List(ctx, srcDir)
+	if err != nil {
+		return err
+	}
+
+	// History first and the state last, so an interrupted copy leaves no current state behind
+	var keys []string
+	for _, o := range objs {
+		if o.Key != b.StateKey && o.Key != b.LockKey() {
+			keys = append(keys, o.Key)
+		}
+	}
+	for _, key := range keys {
+		data, _, err := b.Store.Get(ctx, key)
+		if err != nil {
+			return fmt.Errorf("read %s: %w", key, err)
+		}
+		if err := putCopy(ctx, dst, dstDir+strings.TrimPrefix(key, srcDir), data); err != nil {
+			return err
+		}
+	}
+	return putCopy(ctx, dst, stateKey, current)
+}
+
+func putCopy(ctx context.Context, store ObjectStore, key string, data []byte) error {
+	if _, err := store.Put(ctx, key, data, PutOptions{ContentType: "application/json", IfAbsent: true}); err != nil {
+		return fmt.Errorf("write %s: %w", key, err)
+	}
+	return nil
+}
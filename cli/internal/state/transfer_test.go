@@ -0,0 +1,53 @@
+package state
+
+import (
+	"testing"
+	"time"
+)
+
+func TestExportImportRoundTrip(t *testing.T) {
+	src := NewStoreBackend(NewMemoryStore(), testKey)
+	src.SaveState(ClusterState{SSHUser: "root", Nodes: []NodeState{{Name: "test-web-1"}}})
+	data, err := src.ExportState()
+	if err != nil {
+		t.Fatalf("export: %v", err)
+	}
+
+	dst := NewStoreBackend(NewMemoryStore(), testKey)
+	if err := dst.ImportState(data); err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	st, _ := dst.LoadState()
+	if st == nil || len(st.Nodes) != 1 || st.SSHUser != "root" {
+		t.Fatalf("unexpected imported state: %+v", st)
+	}
+
+	if _, err := NewStoreBackend(NewMemoryStore(), testKey).ExportState(); err == nil {
+		t.Fatal("export of a missing state must fail")
+	}
+}
+
+func TestCopyToOtherStore(t *testing.T) {
+	src := NewStoreBackend(NewMemoryStore(), testKey)
+	src.SaveState(ClusterState{Nodes: []NodeState{{Name: "a"}}})
+	src.SaveState(ClusterState{Nodes: []NodeState{{Name: "a"}, {Name: "b"}}})
+	src.AcquireLock(NewLockInfo("-state-migrate", time.Minute))
+
+	dst := NewStoreBackend(NewMemoryStore(), StateKeyFor("moved", "test"))
+	if err := src.CopyTo(dst.Store, dst.StateKey); err != nil {
+		t.Fatalf("copy: %v", err)
+	}
+	st, _ := dst.LoadState()
+	if st == nil || len(st.Nodes) != 2 {
+		t.Fatalf("state not copied: %+v", st)
+	}
+	if revs, _ := dst.ListHistory(); len(revs) != 2 {
+		t.Fatalf("expected 2 revisions, got %d", len(revs))
+	}
+	if info, _ := dst.GetLockInfo(); info != nil {
+		t.Fatal("lock must not be copied")
+	}
+	if err := src.CopyTo(dst.Store, dst.StateKey); err == nil {
+		t.Fatal("copy over an existing state must fail")
+	}
+}